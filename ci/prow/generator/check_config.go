@@ -0,0 +1,345 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// drift detection between the generated configs and the committed ones
+
+package generator
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around each change.
+	diffContext = 3
+)
+
+var (
+	// copyrightRegexp matches the copyright line of the generated files, whose year is the current one.
+	copyrightRegexp = regexp.MustCompile(`^# Copyright [0-9]{4} `)
+)
+
+// configEntry is a piece of a generated config file, like a job, a test group or a general config section.
+type configEntry struct {
+	Name  string
+	Lines []string
+}
+
+// CheckGeneratedConfig compares the generated config with the content of the given file.
+// A unified diff of each entry that changed is written to out. Returns true if they are identical.
+func CheckGeneratedConfig(fileName string, generated string, out io.Writer) (bool, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return false, fmt.Errorf("cannot read %q: %v", fileName, err)
+	}
+	diffs := diffConfigs(string(content), generated)
+	for _, diff := range diffs {
+		fmt.Fprintf(out, "--- %s (%s)\n+++ generated (%s)\n%s", fileName, diff.Name, diff.Name, strings.Join(diff.Lines, "\n"))
+	}
+	return len(diffs) == 0, nil
+}
+
+// diffConfigs returns the unified diffs between the entries of the committed and the generated configs.
+// If all entries are identical but the configs still differ (e.g., entries were reordered), the diff of the whole config is returned.
+func diffConfigs(committed, generated string) []configEntry {
+	committedEntries := splitConfigEntries(committed)
+	generatedEntries := splitConfigEntries(generated)
+	var diffs []configEntry
+	for _, name := range entryNames(generatedEntries, committedEntries) {
+		a := findConfigEntry(committedEntries, name)
+		b := findConfigEntry(generatedEntries, name)
+		if diff := unifiedDiff(a, b); len(diff) > 0 {
+			diffs = append(diffs, configEntry{Name: name, Lines: diff})
+		}
+	}
+	if len(diffs) == 0 {
+		a := normalizeLines(strings.Split(committed, "\n"))
+		b := normalizeLines(strings.Split(generated, "\n"))
+		if diff := unifiedDiff(a, b); len(diff) > 0 {
+			diffs = append(diffs, configEntry{Name: "entries order", Lines: diff})
+		}
+	}
+	return diffs
+}
+
+// splitConfigEntries splits the given config into its entries.
+// Each top-level section is an entry, and each element of a list of jobs, test groups or dashboards in a section is an entry on its own.
+// Entries are named after their section and their "name" key, or their first key or value when they have no name,
+// and duplicated names are suffixed with their occurrence.
+func splitConfigEntries(content string) []configEntry {
+	entries := []configEntry{{Name: "header"}}
+	sections := []string{""}
+	firstKeys := []string{""}
+	section := ""
+	itemIndent := -1
+	lines := normalizeLines(strings.Split(content, "\n"))
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		isComment := trimmed == "" || strings.HasPrefix(trimmed, "#")
+		isItem := strings.HasPrefix(trimmed, "- ")
+		newEntry := ""
+		switch {
+		case indent == 0 && !isComment && !isItem:
+			// Top-level section, e.g. "presubmits:".
+			section = strings.SplitN(trimmed, ":", 2)[0]
+			itemIndent = -1
+			newEntry = section
+		case isItem && (itemIndent == -1 || indent == itemIndent):
+			// New element in the section list, its name is set when the "name" key is found.
+			itemIndent = indent
+			newEntry = " "
+		case !isComment && !isItem && indent > 0 && ((itemIndent == -1 && startsList(lines[i+1:], indent)) || indent <= itemIndent):
+			// Grouping key inside a section, e.g. a repository in presubmits.
+			newEntry = section + "/" + strings.TrimSuffix(trimmed, ":")
+		}
+		if newEntry != "" {
+			entries = append(entries, configEntry{Name: strings.TrimSpace(newEntry)})
+			sections = append(sections, section)
+			firstKeys = append(firstKeys, "")
+			if isItem {
+				firstKeys[len(firstKeys)-1] = itemKey(trimmed)
+			}
+		}
+		last := &entries[len(entries)-1]
+		if itemIndent != -1 && last.Name == "" {
+			key := strings.TrimPrefix(trimmed, "- ")
+			if strings.HasPrefix(key, "name: ") && indent <= itemIndent+2 {
+				last.Name = section + "/" + strings.TrimPrefix(key, "name: ")
+			}
+		}
+		last.Lines = append(last.Lines, line)
+	}
+	// Ensure names are set and unique.
+	seen := make(map[string]int)
+	for i := range entries {
+		if entries[i].Name == "" {
+			entries[i].Name = sections[i] + "/" + firstKeys[i]
+		}
+		seen[entries[i].Name]++
+		if seen[entries[i].Name] > 1 {
+			entries[i].Name = fmt.Sprintf("%s #%d", entries[i].Name, seen[entries[i].Name])
+		}
+	}
+	return entries
+}
+
+// itemKey returns the first key of the given list element, or its value if it isn't a map, e.g. "cron" for
+// `- cron: "0 * * * *"` or "metadata" for `- "metadata"`.
+func itemKey(item string) string {
+	key := strings.TrimPrefix(item, "- ")
+	if i := strings.Index(key, ": "); i != -1 {
+		key = key[:i]
+	}
+	return strings.Trim(strings.TrimSuffix(key, ":"), `"'`)
+}
+
+// startsList returns true if the first of the given lines that isn't a comment is a list element
+// indented at least by the given indent, i.e. if the key before these lines holds a list.
+func startsList(lines []string, indent int) bool {
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		return strings.HasPrefix(trimmed, "- ") && len(line)-len(trimmed) >= indent
+	}
+	return false
+}
+
+// normalizeLines removes the differences that are not considered drift, like the copyright year.
+func normalizeLines(lines []string) []string {
+	res := make([]string, len(lines))
+	for i, line := range lines {
+		res[i] = copyrightRegexp.ReplaceAllString(line, "# Copyright YEAR ")
+	}
+	return res
+}
+
+// entryNames returns the names of all given entries, without duplicates and keeping their order.
+func entryNames(entries ...[]configEntry) []string {
+	var names []string
+	for _, list := range entries {
+		for _, entry := range list {
+			if !strExists(names, entry.Name) {
+				names = append(names, entry.Name)
+			}
+		}
+	}
+	return names
+}
+
+// findConfigEntry returns the lines of the entry with the given name, or nil if not found.
+func findConfigEntry(entries []configEntry, name string) []string {
+	for _, entry := range entries {
+		if entry.Name == name {
+			return entry.Lines
+		}
+	}
+	return nil
+}
+
+// unifiedDiff returns the hunks of the unified diff between a and b, or nil if they're identical.
+func unifiedDiff(a, b []string) []string {
+	ops := diffLines(a, b)
+	// Group the changes into hunks with some context around them.
+	var res []string
+	aLine, bLine := 1, 1
+	for start := 0; start < len(ops); {
+		if ops[start][0] == ' ' {
+			start++
+			aLine++
+			bLine++
+			continue
+		}
+		hunkStart := start - diffContext
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		end, unchanged := start, 0
+		for end < len(ops) && unchanged <= 2*diffContext {
+			if ops[end][0] == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+		if unchanged > diffContext {
+			end -= unchanged - diffContext
+		}
+		hunk := ops[hunkStart:end]
+		aStart, bStart := aLine-(start-hunkStart), bLine-(start-hunkStart)
+		aCount, bCount := 0, 0
+		for _, op := range hunk {
+			if op[0] != '+' {
+				aCount++
+			}
+			if op[0] != '-' {
+				bCount++
+			}
+		}
+		res = append(res, fmt.Sprintf("@@ -%s +%s @@", hunkRange(aStart, aCount), hunkRange(bStart, bCount)))
+		res = append(res, hunk...)
+		aLine, bLine = aStart+aCount, bStart+bCount
+		start = end
+	}
+	if len(res) > 0 {
+		res = append(res, "")
+	}
+	return res
+}
+
+// diffLines returns the edit script from a to b, each line prefixed by " ", "-" or "+".
+// It finds a longest common subsequence with Hirschberg's algorithm, so that diffing whole configs only needs linear space.
+func diffLines(a, b []string) []string {
+	var ops []string
+	// Unchanged lines at the start and the end don't need to be compared further.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ops = appendOps(ops, " ", a[:prefix])
+	unchangedEnd := a[len(a)-suffix:]
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	switch {
+	case len(a) == 0:
+		ops = appendOps(ops, "+", b)
+	case len(b) == 0:
+		ops = appendOps(ops, "-", a)
+	case len(a) == 1:
+		j := 0
+		for j < len(b) && b[j] != a[0] {
+			j++
+		}
+		if j < len(b) {
+			ops = appendOps(ops, "+", b[:j])
+			ops = appendOps(ops, " ", a)
+			ops = appendOps(ops, "+", b[j+1:])
+		} else {
+			ops = appendOps(ops, "-", a)
+			ops = appendOps(ops, "+", b)
+		}
+	default:
+		// Split b where the halves of a have the longest common subsequences with it, and diff both sides.
+		mid := len(a) / 2
+		forward := lcsLengths(a[:mid], b, false)
+		backward := lcsLengths(a[mid:], b, true)
+		split, best := 0, -1
+		for j := 0; j <= len(b); j++ {
+			if l := forward[j] + backward[len(b)-j]; l > best {
+				split, best = j, l
+			}
+		}
+		ops = append(ops, diffLines(a[:mid], b[:split])...)
+		ops = append(ops, diffLines(a[mid:], b[split:])...)
+	}
+	return appendOps(ops, " ", unchangedEnd)
+}
+
+// lcsLengths returns the lengths of the longest common subsequences of a and each prefix of b,
+// or of each suffix of b (indexed by its length) when comparing the lines in reverse.
+func lcsLengths(a, b []string, reverse bool) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		x := a[i]
+		if reverse {
+			x = a[len(a)-1-i]
+		}
+		for j := 1; j <= len(b); j++ {
+			y := b[j-1]
+			if reverse {
+				y = b[len(b)-j]
+			}
+			switch {
+			case x == y:
+				cur[j] = prev[j-1] + 1
+			case prev[j] >= cur[j-1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j-1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// appendOps appends the given lines to the edit script, prefixed by op.
+func appendOps(ops []string, op string, lines []string) []string {
+	for _, line := range lines {
+		ops = append(ops, op+line)
+	}
+	return ops
+}
+
+// hunkRange returns the range of lines of a hunk, as used in its header.
+// An empty range refers to the line before it, as in diff.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
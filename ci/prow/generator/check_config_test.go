@@ -0,0 +1,146 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// check_config_test.go contains unit tests for the drift detection of the generated configs
+
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const committedConfig = `# Copyright 2018 The Knative Authors
+plank:
+  pod_pending_timeout: 60m
+branch-protection:
+  orgs:
+    knative:
+      required_status_checks:
+        contexts:
+        - "pull-knative-serving-unit-tests"
+        - "pull-knative-build-unit-tests"
+presubmits:
+  knative/serving:
+  - name: pull-knative-serving-unit-tests
+    always_run: true
+  - name: pull-knative-serving-unit-tests
+    always_run: false
+  knative/build:
+  - name: pull-knative-build-unit-tests
+    always_run: true
+periodics:
+- cron: "0 * * * *"
+  name: ci-knative-serving-continuous
+  spec:
+    containers:
+    - name: test
+`
+
+func TestSplitConfigEntries(t *testing.T) {
+	var names []string
+	for _, entry := range splitConfigEntries(committedConfig) {
+		names = append(names, entry.Name)
+	}
+	expected := []string{
+		"header",
+		"plank",
+		"branch-protection",
+		"branch-protection/contexts",
+		"branch-protection/pull-knative-serving-unit-tests",
+		"branch-protection/pull-knative-build-unit-tests",
+		"presubmits",
+		"presubmits/knative/serving",
+		"presubmits/pull-knative-serving-unit-tests",
+		"presubmits/pull-knative-serving-unit-tests #2",
+		"presubmits/knative/build",
+		"presubmits/pull-knative-build-unit-tests",
+		"periodics",
+		"periodics/ci-knative-serving-continuous",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected entries %v, got %v", expected, names)
+	}
+}
+
+func TestDiffConfigs(t *testing.T) {
+	if diffs := diffConfigs(committedConfig, strings.Replace(committedConfig, "2018", "2019", 1)); len(diffs) != 0 {
+		t.Errorf("Expected no drift when only the copyright year changes, got %v", diffs)
+	}
+
+	generated := strings.Replace(committedConfig, "  - name: pull-knative-build-unit-tests\n    always_run: true\n", "", 1)
+	generated = strings.Replace(generated, `- cron: "0 * * * *"`, `- cron: "5 * * * *"`, 1)
+	diffs := diffConfigs(committedConfig, generated)
+	expected := []configEntry{
+		{
+			Name: "periodics/ci-knative-serving-continuous",
+			Lines: []string{
+				"@@ -1,4 +1,4 @@",
+				`-- cron: "0 * * * *"`,
+				`+- cron: "5 * * * *"`,
+				"   name: ci-knative-serving-continuous",
+				"   spec:",
+				"     containers:",
+				"",
+			},
+		},
+		{
+			Name: "presubmits/pull-knative-build-unit-tests",
+			Lines: []string{
+				"@@ -1,2 +0,0 @@",
+				"-  - name: pull-knative-build-unit-tests",
+				"-    always_run: true",
+				"",
+			},
+		},
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("Expected diffs %v, got %v", expected, diffs)
+	}
+
+	reordered := strings.Replace(committedConfig, "  pod_pending_timeout: 60m\n", "", 1)
+	reordered = strings.Replace(reordered, "periodics:\n", "  pod_pending_timeout: 60m\nperiodics:\n", 1)
+	if diffs := diffConfigs(committedConfig, reordered); len(diffs) == 0 {
+		t.Error("Expected drift when lines move between entries, got none")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}
+	b := []string{"1", "two", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13"}
+	expected := []string{
+		"@@ -1,5 +1,5 @@",
+		" 1",
+		"-2",
+		"+two",
+		" 3",
+		" 4",
+		" 5",
+		"@@ -10,3 +10,4 @@",
+		" 10",
+		" 11",
+		" 12",
+		"+13",
+		"",
+	}
+	if diff := unifiedDiff(a, b); !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Expected diff %q, got %q", expected, diff)
+	}
+	if diff := unifiedDiff(a, a); diff != nil {
+		t.Errorf("Expected no diff, got %q", diff)
+	}
+}
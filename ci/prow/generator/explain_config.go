@@ -0,0 +1,296 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// explanation of the generated Prow jobs, tracing the source of the value of each field
+
+package generator
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	// defaultSource is the source of the values built into the generator.
+	defaultSource = "default"
+)
+
+// FieldSource is a field of a generated job, with its value and where the value comes from.
+type FieldSource struct {
+	Field string
+	Value string
+	// Source is where the value comes from, like "default", "flag --extra-env",
+	// "repo-settings entry (config_knative.yaml:12)" or "unit-tests entry (config_knative.yaml:34)".
+	Source string
+}
+
+// WriteExplanation writes the fields of the job with their values and sources.
+func (j Job) WriteExplanation(out io.Writer) {
+	fmt.Fprintf(out, "%s (%s for %s)\n", j.Name, j.Kind, j.RepoName)
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, field := range j.Fields {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", field.Field, field.Value, field.Source)
+	}
+	w.Flush()
+}
+
+// setSource records where the value of the given field of the job comes from.
+// Fields without a recorded source have a default value.
+func setSource(data *baseProwJobTemplateData, field, source string) {
+	if source != "" {
+		data.Sources[field] = source
+	}
+}
+
+// flagSource returns the source of a value given by the command-line flag with the given name.
+func flagSource(name string) string {
+	return "flag --" + name
+}
+
+// settingSource returns the source of the given setting (a field of orgSettings, like "TestAccount") for the jobs of the given org:
+// the org settings of the input config if they override it, the command-line flag otherwise.
+func (g *generator) settingSource(orgName, setting string) string {
+	field, _ := reflect.TypeOf(orgSettings{}).FieldByName(setting)
+	if reflect.ValueOf(g.config.Settings[orgName]).FieldByName(setting).String() != "" {
+		if g.config.FileName == "" {
+			return "settings of " + orgName
+		}
+		return fmt.Sprintf("settings of %s (%s)", orgName, g.config.FileName)
+	}
+	return flagSource(field.Tag.Get("yaml"))
+}
+
+// location returns the position of the given line of the input config.
+func (g *generator) location(line int) string {
+	if g.config.FileName == "" {
+		return fmt.Sprintf("line %d", line)
+	}
+	return fmt.Sprintf("%s:%d", g.config.FileName, line)
+}
+
+// describeEntry returns the source of the settings given in an entry of the input config: a job entry, or the template with the given name.
+func (g *generator) describeEntry(config jobConfig, templateName string) string {
+	if config.Line == 0 {
+		// Entries generated by ExpandReleaseBranches without a model.
+		return fmt.Sprintf("%s entry generated from the release branches", config.Type)
+	}
+	if templateName != "" {
+		return fmt.Sprintf("template %s (%s)", templateName, g.location(config.Line))
+	}
+	return fmt.Sprintf("%s entry (%s)", config.Type, g.location(config.Line))
+}
+
+// entrySource returns the source of the entry of the input config (a job entry or one of the templates it extends)
+// where the setting checked by isSet is given, or an empty string if none gives it.
+// As settings of the job override the ones of its templates, the job is checked first.
+func (g *generator) entrySource(config jobConfig, isSet func(jobConfig) bool) string {
+	seen := make(map[string]bool)
+	for name := ""; ; name = config.Extends {
+		if name != "" {
+			if seen[name] {
+				return ""
+			}
+			seen[name] = true
+			config = g.config.Templates[name].jobConfig
+		}
+		if isSet(config) {
+			return g.describeEntry(config, name)
+		}
+		if config.Extends == "" {
+			return ""
+		}
+	}
+}
+
+// argsSource returns the sources of the args of the given entry. As args are appended to the ones of the templates,
+// all entries giving args are listed, up to the first one setting a full command.
+func (g *generator) argsSource(config jobConfig) string {
+	var sources []string
+	seen := make(map[string]bool)
+	for name := ""; ; name = config.Extends {
+		if name != "" {
+			if seen[name] {
+				break
+			}
+			seen[name] = true
+			config = g.config.Templates[name].jobConfig
+		}
+		if config.Args != nil || config.FullCommand != "" {
+			sources = append([]string{g.describeEntry(config, name)}, sources...)
+		}
+		if config.FullCommand != "" || config.Extends == "" {
+			break
+		}
+	}
+	return strings.Join(sources, " + ")
+}
+
+// repoSettingSource returns the source of the setting checked by isSet in the repo-settings entries of the given repository,
+// or an empty string if none gives it. The last entry giving the setting wins.
+func (g *generator) repoSettingSource(repo repositoryData, isSet func(jobConfig) bool) string {
+	for i := len(repo.Settings) - 1; i >= 0; i-- {
+		if source := g.entrySource(repo.Settings[i], isSet); source != "" {
+			return source
+		}
+	}
+	return ""
+}
+
+// podFields returns the settings given in the pod config, keyed by the name of the job field they set.
+func podFields(pod podConfig) map[string]string {
+	fields := make(map[string]string)
+	for _, q := range []struct{ field, value string }{
+		{"resources.requests.cpu", pod.Resources.Requests.CPU},
+		{"resources.requests.memory", pod.Resources.Requests.Memory},
+		{"resources.limits.cpu", pod.Resources.Limits.CPU},
+		{"resources.limits.memory", pod.Resources.Limits.Memory},
+	} {
+		if q.value != "" {
+			fields[q.field] = q.value
+		}
+	}
+	for key, value := range pod.NodeSelector {
+		fields["node_selector."+key] = value
+	}
+	if len(pod.Tolerations) > 0 {
+		fields["tolerations"] = fmt.Sprint(pod.Tolerations)
+	}
+	return fields
+}
+
+// setPodSources records the sources of the pod settings of the job defined by the given (not expanded) entry, in the given repository.
+// The entry and its templates override the repo-settings, which override the job-types.
+func (g *generator) setPodSources(data *baseProwJobTemplateData, config jobConfig, repo *repositoryData) {
+	isSet := func(field string) func(jobConfig) bool {
+		return func(c jobConfig) bool {
+			_, exists := podFields(c.podConfig)[field]
+			return exists
+		}
+	}
+	for field := range podFields(g.config.JobTypes[config.Type]) {
+		setSource(data, field, "job-types of "+config.Type)
+	}
+	if repo != nil {
+		for field := range podFields(repo.Pod) {
+			setSource(data, field, g.repoSettingSource(*repo, isSet(field)))
+		}
+	}
+	for field := range podFields(expandJobConfig(config, g.config.Templates).podConfig) {
+		setSource(data, field, g.entrySource(config, isSet(field)))
+	}
+}
+
+// sourceOf returns the source of the value of the given field of the job.
+func sourceOf(data baseProwJobTemplateData, field string) string {
+	if source, exists := data.Sources[field]; exists {
+		return source
+	}
+	return defaultSource
+}
+
+// explainJob returns the fields emitted for the job with the given data, with their sources.
+func (g *generator) explainJob(kind string, data baseProwJobTemplateData, cron string) []FieldSource {
+	var fields []FieldSource
+	add := func(field, value string) {
+		fields = append(fields, FieldSource{Field: field, Value: value, Source: sourceOf(data, field)})
+	}
+	add("image", data.Image)
+	if g.options.PreCommand != "" {
+		fields = append(fields, FieldSource{Field: "pre-command", Value: g.options.PreCommand, Source: flagSource("pre-command")})
+	}
+	add("command", data.Command)
+	if len(data.Args) > 0 {
+		add("args", strings.Join(data.Args, " "))
+	}
+	add("timeout", fmt.Sprintf("%dm", data.Timeout))
+	if kind == "periodic" {
+		add("cron", cron)
+	}
+	if kind == "presubmit" {
+		add("always_run", strconv.FormatBool(data.AlwaysRun))
+		if data.Optional {
+			add("optional", "true")
+		}
+		if data.RunIfChanged != "" {
+			add("run_if_changed", data.RunIfChanged)
+		}
+	}
+	if len(data.Branches) > 0 {
+		add("branches", strings.Join(data.Branches, " "))
+	}
+	if len(data.SkipBranches) > 0 {
+		add("skip_branches", strings.Join(data.SkipBranches, " "))
+	}
+	if data.PathAlias != "" {
+		add("path_alias", strings.TrimPrefix(data.PathAlias, "path_alias: "))
+	}
+	if data.ServiceAccount != "" {
+		add("service_account", data.ServiceAccount)
+	}
+	for i := 0; i+1 < len(data.Env); i += 2 {
+		add("env."+strings.TrimPrefix(data.Env[i], "- name: "), strings.TrimPrefix(data.Env[i+1], "  value: "))
+	}
+	for _, line := range data.VolumeMounts {
+		if strings.HasPrefix(line, "- name: ") {
+			name := strings.TrimPrefix(line, "- name: ")
+			add("volume."+name, volumeMountPath(data.VolumeMounts, name))
+		}
+	}
+	var section string
+	for _, line := range data.Resources {
+		if !strings.HasPrefix(line, " ") {
+			section = strings.TrimSuffix(line, ":")
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSpace(line), ": ", 2)
+		add("resources."+section+"."+parts[0], parts[1])
+	}
+	for _, line := range data.NodeSelector {
+		parts := strings.SplitN(line, ": ", 2)
+		add("node_selector."+parts[0], parts[1])
+	}
+	if len(data.Tolerations) > 0 {
+		var tolerations []string
+		for _, line := range data.Tolerations {
+			tolerations = append(tolerations, strings.TrimSpace(strings.TrimPrefix(line, "- ")))
+		}
+		add("tolerations", strings.Join(tolerations, " "))
+	}
+	if len(data.SecurityContext) > 0 {
+		add("security_context", strings.Join(data.SecurityContext, " "))
+	}
+	if len(data.Labels) > 0 {
+		labels := append([]string(nil), data.Labels...)
+		sort.Strings(labels)
+		add("labels", strings.Join(labels, " "))
+	}
+	return fields
+}
+
+// volumeMountPath returns the mount path of the given volume, found in the volume mounts of a job.
+func volumeMountPath(mounts []string, name string) string {
+	for i, line := range mounts {
+		if line == "- name: "+name && i+1 < len(mounts) {
+			return strings.TrimPrefix(mounts[i+1], "  mountPath: ")
+		}
+	}
+	return ""
+}
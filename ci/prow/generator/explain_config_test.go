@@ -0,0 +1,168 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// explain_config_test.go contains unit tests for tracing the source of the fields of the generated jobs
+
+package generator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const explainInputConfig = `
+settings:
+  knative:
+    test-account: /etc/knative-account/service-account.json
+
+templates:
+  e2e:
+    timeout: 120
+    args:
+    - --run-tests
+
+job-types:
+  continuous:
+    resources:
+      requests:
+        cpu: 2
+
+presubmits:
+  knative/serving:
+  - repo-settings:
+    node-selector:
+      pool: big
+  - integration-tests: true
+    extends: e2e
+    args:
+    - --gcp-project=foo
+
+periodics:
+  knative/serving:
+  - continuous: true
+    cron: "0 * * * *"
+`
+
+func explainedFields(t *testing.T, jobName string, options Options) map[string]FieldSource {
+	config, err := ParseInputConfig("test.yaml", []byte(explainInputConfig))
+	if err != nil {
+		t.Fatalf("Cannot parse test config: %v", err)
+	}
+	res, err := GenerateProwConfig(config, options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, job := range res.Jobs {
+		if job.Name == jobName {
+			fields := make(map[string]FieldSource)
+			for _, field := range job.Fields {
+				fields[field.Field] = field
+			}
+			return fields
+		}
+	}
+	t.Fatalf("Job %q not generated", jobName)
+	return nil
+}
+
+func TestExplainJob(t *testing.T) {
+	options := testOptions()
+	options.ExtraEnvVars = []string{"FOO=bar"}
+	var tests = []struct {
+		job    string
+		field  string
+		value  string
+		source string
+	}{
+		{"pull-knative-serving-integration-tests", "image", "gcr.io/knative-tests/test-infra/prow-tests:stable", "flag --prow-tests-docker"},
+		{"pull-knative-serving-integration-tests", "command", "./test/presubmit-tests.sh", "flag --presubmit-script"},
+		{"pull-knative-serving-integration-tests", "args", "--run-tests --gcp-project=foo", "template e2e (test.yaml:8) + integration-tests entry (test.yaml:23)"},
+		{"pull-knative-serving-integration-tests", "timeout", "120m", "template e2e (test.yaml:8)"},
+		{"pull-knative-serving-integration-tests", "always_run", "true", "default"},
+		{"pull-knative-serving-integration-tests", "service_account", "/etc/knative-account/service-account.json", "settings of knative (test.yaml)"},
+		{"pull-knative-serving-integration-tests", "node_selector.pool", `"big"`, "repo-settings entry (test.yaml:20)"},
+		{"pull-knative-serving-integration-tests", "env.FOO", "bar", "flag --extra-env"},
+		{"ci-knative-serving-continuous", "cron", "0 * * * *", "continuous entry (test.yaml:30)"},
+		{"ci-knative-serving-continuous", "resources.requests.cpu", "2", "job-types of continuous"},
+	}
+	for _, test := range tests {
+		fields := explainedFields(t, test.job, options)
+		field, exists := fields[test.field]
+		if !exists {
+			t.Errorf("%s: expected field %q, got %v", test.job, test.field, fields)
+			continue
+		}
+		if field.Value != test.value || field.Source != test.source {
+			t.Errorf("%s: expected %s to be %q from %q, got %q from %q", test.job, test.field, test.value, test.source, field.Value, field.Source)
+		}
+	}
+}
+
+func TestExplainLegacyBranches(t *testing.T) {
+	config, err := ParseInputConfig("test.yaml", []byte(`
+presubmits:
+  knative/serving:
+  - repo-settings:
+    legacy-branches:
+    - release-0.1
+  - unit-tests: true
+    skip_branches:
+    - release-0.2
+`))
+	if err != nil {
+		t.Fatalf("Cannot parse test config: %v", err)
+	}
+	res, err := GenerateProwConfig(config, testOptions())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Both the skip_branches of the job and the legacy branches of the repository give the branches of its two variants
+	expected := "unit-tests entry (test.yaml:7) + repo-settings entry (test.yaml:4)"
+	var variants int
+	for _, job := range res.Jobs {
+		if job.Name != "pull-knative-serving-unit-tests" {
+			continue
+		}
+		variants++
+		for _, field := range job.Fields {
+			if (field.Field == "branches" || field.Field == "skip_branches") && field.Source != expected {
+				t.Errorf("Expected %s %q to come from %q, got %q", field.Field, field.Value, expected, field.Source)
+			}
+		}
+	}
+	if variants != 2 {
+		t.Errorf("Expected 2 variants of the job for the legacy branches, got %d", variants)
+	}
+}
+
+func TestWriteExplanation(t *testing.T) {
+	job := Job{Kind: "presubmit", Name: "pull-foo", RepoName: "knative/foo", Fields: []FieldSource{
+		{"image", "foo:latest", "flag --prow-tests-docker"},
+		{"timeout", "50m", "default"},
+	}}
+	var out bytes.Buffer
+	job.WriteExplanation(&out)
+	expected := strings.Join([]string{
+		"pull-foo (presubmit for knative/foo)",
+		"  image    foo:latest  flag --prow-tests-docker",
+		"  timeout  50m         default",
+		"",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("Expected explanation:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
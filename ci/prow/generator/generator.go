@@ -0,0 +1,345 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package generator generates the Prow and Testgrid configs of the Knative project
+// from an input config with key definitions (e.g., config_knative.yaml).
+// The generation runs in memory, so it can be called by other tools and tested without touching any file.
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Options contains the values used in the jobs and the behavior changes of the generation,
+// which the make_config tool sets through command-line flags.
+type Options struct {
+	// TemplateDir is the dir containing the templates of the generated configs.
+	TemplateDir string
+
+	// Values used in the jobs, unless overridden for an organization in the input config.
+	GcsBucket                    string
+	LogsDir                      string
+	PresubmitLogsDir             string
+	TestAccount                  string
+	NightlyAccount               string
+	ReleaseAccount               string
+	FlakesReporterDockerImage    string
+	ProwVersionBumperDockerImage string
+	CoverageDockerImage          string
+	ClearAlertsDockerImage       string
+	ProwTestsDockerImage         string
+	MetricsDockerImage           string
+	ReleaseGcs                   string
+	ReleaseGcr                   string
+	GitHubTokenSecret            string
+	CoverageTokenSecret          string
+	PresubmitScript              string
+	ReleaseScript                string
+	PerformanceScript            string
+	WebhookAPICoverageScript     string
+	CleanupScript                string
+
+	// Overrides and behavior changes.
+	RepositoryOverride string
+	JobNameFilter      string
+	PreCommand         string
+	ExtraEnvVars       []string
+	TimeoutOverride    int
+	// IncludeConfig adds the general configuration (e.g., plank) to the generated configs.
+	IncludeConfig bool
+	// SplitJobs generates the jobs in one file per repository and job type, instead of in the Prow config.
+	SplitJobs bool
+}
+
+// DefaultOptions returns the options used for the Knative Prow instance.
+func DefaultOptions() Options {
+	return Options{
+		TemplateDir:                  "templates",
+		GcsBucket:                    "knative-prow",
+		LogsDir:                      "logs",
+		PresubmitLogsDir:             "pr-logs",
+		TestAccount:                  "/etc/test-account/service-account.json",
+		NightlyAccount:               "/etc/nightly-account/service-account.json",
+		ReleaseAccount:               "/etc/release-account/service-account.json",
+		FlakesReporterDockerImage:    "gcr.io/knative-tests/test-infra/flaky-test-reporter:latest",
+		ProwVersionBumperDockerImage: "gcr.io/knative-tests/test-infra/prow-auto-bumper:latest",
+		CoverageDockerImage:          "gcr.io/knative-tests/test-infra/coverage:latest",
+		ClearAlertsDockerImage:       "gcr.io/knative-tests/test-infra/monitoring/clear-alerts:latest",
+		ProwTestsDockerImage:         "gcr.io/knative-tests/test-infra/prow-tests:stable",
+		MetricsDockerImage:           "gcr.io/knative-tests/test-infra/metrics:latest",
+		ReleaseGcs:                   "knative-releases",
+		ReleaseGcr:                   "gcr.io/knative-releases",
+		GitHubTokenSecret:            "hub-token",
+		CoverageTokenSecret:          "covbot-token",
+		PresubmitScript:              "./test/presubmit-tests.sh",
+		ReleaseScript:                "./hack/release.sh",
+		PerformanceScript:            "./test/performance-tests.sh",
+		WebhookAPICoverageScript:     "./test/apicoverage.sh",
+		CleanupScript:                "./tools/cleanup/cleanup.sh",
+		IncludeConfig:                true,
+	}
+}
+
+// Section is a top-level section of a generated config (like "presubmits" or "test_groups"), rendered as YAML.
+type Section struct {
+	Name string
+	YAML string
+}
+
+// Job is a generated Prow job.
+type Job struct {
+	// Kind is "presubmit", "postsubmit" or "periodic".
+	Kind string
+	// RepoName is the repository (like "knative/serving") the job belongs to.
+	RepoName string
+	Name     string
+	// Cron is the schedule of a periodic job.
+	Cron         string
+	Branches     []string
+	SkipBranches []string
+	AlwaysRun    bool
+	Optional     bool
+	RunIfChanged string
+	Image        string
+	Command      string
+	Args         []string
+	// Timeout is the timeout of the job, in minutes.
+	Timeout int
+	// Fields are the fields of the job with the source of their value, see WriteExplanation.
+	Fields []FieldSource
+}
+
+// ProwConfig is a generated Prow config.
+type ProwConfig struct {
+	// Sections are the sections of the config, in order: "general" and "tide" if Options.IncludeConfig is set,
+	// then the "presubmits", "periodics" and "postsubmits" jobs unless Options.SplitJobs is set.
+	Sections []Section
+	// JobFiles are the job configs generated when Options.SplitJobs is set, keyed by their path relative to the
+	// jobs dir, like "knative/serving/serving-presubmits.yaml".
+	JobFiles map[string]string
+	// Jobs are all generated jobs, including the ones not output because of Options.JobNameFilter.
+	Jobs []Job
+
+	scheduler *scheduler
+}
+
+// TestgridConfig is a generated Testgrid config.
+type TestgridConfig struct {
+	// Sections are the sections of the config, in order: "general" if Options.IncludeConfig is set,
+	// then "test_groups", "dashboards" and "dashboard_groups".
+	Sections []Section
+}
+
+// YAML returns the content of the Prow config file.
+func (c *ProwConfig) YAML() string {
+	return joinSections(c.Sections)
+}
+
+// PrintScheduleLoad writes a histogram of the highest number of periodic jobs running at once for each hour of the day, in UTC.
+func (c *ProwConfig) PrintScheduleLoad(out io.Writer) {
+	c.scheduler.printLoad(out)
+}
+
+// YAML returns the content of the Testgrid config file.
+func (c *TestgridConfig) YAML() string {
+	return joinSections(c.Sections)
+}
+
+// GenerateProwConfig generates the Prow config from the given input config.
+func GenerateProwConfig(config InputConfig, options Options) (res *ProwConfig, err error) {
+	defer recoverError(&err)
+	// Generate the jobs a first time to collect the periodics to schedule, and the load of the ones with a fixed cron.
+	s := newScheduler(config.Schedule)
+	g := newGenerator(config, options, s)
+	g.generateProwJobs()
+	if err := s.schedule(g.jobs); err != nil {
+		return nil, fmt.Errorf("cannot schedule the periodic jobs: %v", err)
+	}
+	tideData := newTideTemplateData(g.jobs, config.Tide.Repos, config.Tide.ProtectedOrgs)
+
+	g = newGenerator(config, options, s)
+	if options.IncludeConfig {
+		g.setSection("general")
+		g.executeTemplate("general config", g.readTemplate(generalProwConfig), g.newbaseProwJobTemplateData(""))
+		g.setSection("tide")
+		g.executeTemplate("tide config", g.readTemplate(tideMergeConfig), tideData)
+	}
+	g.generateProwJobs()
+	if problems := lintJobs(g.jobs); len(problems) > 0 {
+		return nil, errors.New(lintReport(problems))
+	}
+	res = &ProwConfig{Sections: g.renderedSections(), Jobs: g.jobs, scheduler: s}
+	if options.SplitJobs {
+		res.JobFiles = make(map[string]string)
+		for name, buf := range g.jobConfigFiles {
+			res.JobFiles[name] = buf.String()
+		}
+	}
+	return res, nil
+}
+
+// GenerateTestgridConfig generates the Testgrid config from the given input config.
+func GenerateTestgridConfig(config InputConfig, options Options) (res *TestgridConfig, err error) {
+	defer recoverError(&err)
+	g := newGenerator(config, options, nil)
+	if options.IncludeConfig {
+		g.setSection("general")
+		g.executeTemplate("general config", g.readTemplate(generalTestgridConfig), newBaseTestgridTemplateData(""))
+	}
+
+	g.goCoverageMap = parseGoCoverageMap(config.Presubmits)
+	g.repoAlerts = g.parseRepoAlerts(config.Presubmits)
+	g.collectMetaData(config.Periodics)
+	g.collectPresubmitMetaData(config.Presubmits)
+
+	g.setSection("test_groups")
+	g.generateTestGridSection("test_groups", g.generateTestGroup, false)
+	g.generatePresubmitTestGroups()
+	g.setSection("dashboards")
+	g.generateTestGridSection("dashboards", g.generateDashboard, true)
+	g.generatePresubmitDashboards()
+	g.generateDashboardsForReleases()
+	g.setSection("dashboard_groups")
+	g.generateDashboardGroups()
+	return &TestgridConfig{Sections: g.renderedSections()}, nil
+}
+
+// generator contains the state of a config generation.
+type generator struct {
+	config    InputConfig
+	options   Options
+	scheduler *scheduler
+
+	// templates caches the templates in memory to avoid I/O.
+	templates map[string]string
+	// output is where the generated config is currently written.
+	output io.Writer
+	// sections contains the generated sections, in order.
+	sections []*sectionOutput
+	// sectionMap tracks which sections of the config were started.
+	sectionMap map[string]bool
+	// jobConfigFiles contains the generated job configs when splitting them, keyed by their path relative to the jobs dir.
+	jobConfigFiles map[string]*bytes.Buffer
+
+	// repositories contains the data of the repositories with presubmit jobs.
+	repositories []repositoryData
+	// jobs contains all jobs generated so far, to be validated and scheduled once the generation is done.
+	jobs []Job
+
+	// goCoverageMap keeps track of which repo has go code coverage.
+	goCoverageMap map[string]bool
+	// projNames saves the project names, for the purpose of maintaining the output sequence.
+	projNames []string
+	// repoNames saves the repo names, for the purpose of maintaining the output sequence.
+	repoNames []string
+	// metaData saves the meta data needed to generate the Testgrid config.
+	// key is the main project version, value is another map containing job details
+	//     for the job detail map, key is the repo name, value is the list of job types, like continuous, latency, nightly, and etc.
+	metaData map[string]map[string][]string
+	// presubmitMetaData saves the presubmit jobs needed to generate the presubmit test groups and dashboards.
+	// key is the project name, value is another map from the repo name to the list of job types, like unit-tests, go-coverage, and etc.
+	presubmitMetaData map[string]map[string][]string
+	// repoAlerts contains the default alerting settings of the repositories (in the org/repo form), set in their repo-settings.
+	repoAlerts map[string]alertConfig
+	// testGroupAlerts contains the alerting settings of the test groups, keyed by test group name.
+	testGroupAlerts map[string]alertConfig
+	// dashboards contains the names of the generated dashboards, mapped to what they show, to catch clashes between orgs.
+	dashboards map[string]string
+}
+
+// sectionOutput is a section being generated.
+type sectionOutput struct {
+	name string
+	buf  bytes.Buffer
+}
+
+// newGenerator returns a generator for the given input config and options, using the given scheduler for the periodic jobs.
+func newGenerator(config InputConfig, options Options, s *scheduler) *generator {
+	return &generator{
+		config:            config,
+		options:           options,
+		scheduler:         s,
+		templates:         make(map[string]string),
+		output:            &bytes.Buffer{},
+		sectionMap:        make(map[string]bool),
+		jobConfigFiles:    make(map[string]*bytes.Buffer),
+		goCoverageMap:     make(map[string]bool),
+		metaData:          make(map[string]map[string][]string),
+		presubmitMetaData: make(map[string]map[string][]string),
+		repoAlerts:        make(map[string]alertConfig),
+		testGroupAlerts:   make(map[string]alertConfig),
+		dashboards:        make(map[string]string),
+	}
+}
+
+// setSection directs the output to the section with the given name, starting it if it's new.
+func (g *generator) setSection(name string) {
+	for _, section := range g.sections {
+		if section.name == name {
+			g.output = &section.buf
+			return
+		}
+	}
+	section := &sectionOutput{name: name}
+	g.sections = append(g.sections, section)
+	g.output = &section.buf
+}
+
+// renderedSections returns the generated sections that aren't empty.
+func (g *generator) renderedSections() []Section {
+	var res []Section
+	for _, section := range g.sections {
+		if section.buf.Len() > 0 {
+			res = append(res, Section{Name: section.name, YAML: section.buf.String()})
+		}
+	}
+	return res
+}
+
+// joinSections returns the content of a config file with the given sections.
+func joinSections(sections []Section) string {
+	var parts []string
+	for _, section := range sections {
+		parts = append(parts, section.YAML)
+	}
+	return strings.Join(parts, "")
+}
+
+// generationError is an error that stops the generation.
+// It's raised as a panic deep in the generation, and returned by the generation functions.
+type generationError struct {
+	error
+}
+
+// fatalf stops the generation with the given error.
+func fatalf(format string, args ...interface{}) {
+	panic(generationError{fmt.Errorf(format, args...)})
+}
+
+// recoverError sets err to the error that stopped the generation, if any.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(generationError)
+		if !ok {
+			panic(r)
+		}
+		*err = e.error
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testInputConfig = `
+presubmits:
+  knative/serving:
+    - build-tests: true
+    - unit-tests: true
+
+periodics:
+  knative/serving:
+    - continuous: true
+      cron: "0 * * * *"
+`
+
+func testOptions() Options {
+	options := DefaultOptions()
+	options.TemplateDir = "../templates"
+	return options
+}
+
+func sectionNames(sections []Section) []string {
+	var names []string
+	for _, section := range sections {
+		names = append(names, section.Name)
+	}
+	return names
+}
+
+func TestGenerateProwConfig(t *testing.T) {
+	config, err := ParseInputConfig("test.yaml", []byte(testInputConfig))
+	if err != nil {
+		t.Fatalf("Cannot parse test config: %v", err)
+	}
+	var tests = []struct {
+		name          string
+		includeConfig bool
+		splitJobs     bool
+		sections      []string
+		jobFiles      []string
+	}{
+		{"full config", true, false, []string{"general", "tide", "presubmits", "periodics"}, []string{}},
+		{"jobs only", false, false, []string{"presubmits", "periodics"}, []string{}},
+		{"split jobs", true, true, []string{"general", "tide"}, []string{
+			"knative/serving/serving-periodics.yaml",
+			"knative/serving/serving-presubmits.yaml",
+			"knative/test-infra/test-infra-periodics.yaml",
+		}},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.IncludeConfig, options.SplitJobs = test.includeConfig, test.splitJobs
+		res, err := GenerateProwConfig(config, options)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if names := sectionNames(res.Sections); !reflect.DeepEqual(names, test.sections) {
+			t.Errorf("%s: expected sections %v, got %v", test.name, test.sections, names)
+		}
+		if files := sortedJobConfigFileNames(res.JobFiles); !reflect.DeepEqual(files, test.jobFiles) {
+			t.Errorf("%s: expected job files %v, got %v", test.name, test.jobFiles, files)
+		}
+		var jobNames []string
+		for _, job := range res.Jobs[:3] {
+			jobNames = append(jobNames, job.Kind+" "+job.Name)
+		}
+		expected := []string{
+			"presubmit pull-knative-serving-build-tests",
+			"presubmit pull-knative-serving-unit-tests",
+			"periodic ci-knative-serving-continuous",
+		}
+		if !reflect.DeepEqual(jobNames, expected) {
+			t.Errorf("%s: expected jobs %v first, got %v", test.name, expected, jobNames)
+		}
+	}
+}
+
+func TestGenerateProwConfigErrors(t *testing.T) {
+	config, err := ParseInputConfig("test.yaml", []byte(`
+periodics:
+  knative/serving:
+    - custom-job: foo_
+      command: ./foo.sh
+`))
+	if err != nil {
+		t.Fatalf("Cannot parse test config: %v", err)
+	}
+	_, err = GenerateProwConfig(config, testOptions())
+	if err == nil || !strings.Contains(err.Error(), "ci-knative-serving-foo_ (periodic for knative/serving): job name must contain only alphanumeric characters") {
+		t.Errorf("Expected an error about the job name, got %v", err)
+	}
+
+	options := testOptions()
+	options.TemplateDir = "does-not-exist"
+	if _, err := GenerateProwConfig(config, options); err == nil || !strings.Contains(err.Error(), "failed to read file") {
+		t.Errorf("Expected an error about the missing templates, got %v", err)
+	}
+}
+
+func TestGenerateTestgridConfig(t *testing.T) {
+	config, err := ParseInputConfig("test.yaml", []byte(testInputConfig))
+	if err != nil {
+		t.Fatalf("Cannot parse test config: %v", err)
+	}
+	res, err := GenerateTestgridConfig(config, testOptions())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"general", "test_groups", "dashboards", "dashboard_groups"}
+	if names := sectionNames(res.Sections); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected sections %v, got %v", expected, names)
+	}
+	for _, name := range []string{"ci-knative-serving-continuous", "pull-knative-serving-unit-tests", "serving-presubmits"} {
+		if !strings.Contains(res.YAML(), "- name: "+name+"\n") {
+			t.Errorf("Expected %q in the Testgrid config, got:\n%s", name, res.YAML())
+		}
+	}
+}
+
+func TestGenerateTestgridConfigDashboardClashes(t *testing.T) {
+	input := `
+presubmits:
+  knative/serving:
+    - unit-tests: true
+  myorg/serving:
+    - unit-tests: true
+`
+	config, err := ParseInputConfig("test.yaml", []byte(input))
+	if err != nil {
+		t.Fatalf("Cannot parse test config: %v", err)
+	}
+	_, err = GenerateTestgridConfig(config, testOptions())
+	if err == nil || !strings.Contains(err.Error(), `dashboard "serving-presubmits" of presubmits of myorg/serving clashes with the one of presubmits of knative/serving`) {
+		t.Errorf("Expected an error about the dashboard names, got %v", err)
+	}
+
+	config, err = ParseInputConfig("test.yaml", []byte(input+`
+settings:
+  myorg:
+    testgrid-dashboard-prefix: myorg-
+`))
+	if err != nil {
+		t.Fatalf("Cannot parse test config: %v", err)
+	}
+	if _, err := GenerateTestgridConfig(config, testOptions()); err != nil {
+		t.Errorf("Unexpected error with a dashboard prefix: %v", err)
+	}
+}
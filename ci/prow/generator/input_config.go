@@ -0,0 +1,820 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// schema of the input yaml file used for generating the Prow and Testgrid configs
+
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	// presubmitJobTypes are the keys that define the type of a presubmit job.
+	presubmitJobTypes = []string{"build-tests", "unit-tests", "integration-tests", "go-coverage", "custom-test", "repo-settings"}
+
+	// periodicJobTypes are the keys that define the type of a periodic job.
+	periodicJobTypes = []string{"continuous", "nightly", "branch-ci", "dot-release", "auto-release", "performance",
+		"performance-mesh", "latency", "webhook-apicoverage", "custom-job"}
+
+	// quantityRegexp matches the Kubernetes quantities used for CPU and memory, like "500m" or "2Gi".
+	quantityRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(m|k|M|G|T|Ki|Mi|Gi|Ti)?$`)
+
+	// emailRegexp matches the email addresses receiving the Testgrid alerts.
+	emailRegexp = regexp.MustCompile(`^[^@\s,]+@[^@\s,]+\.[^@\s,]+$`)
+
+	// unknownFieldRegexp matches the error message yaml returns for unknown keys in strict mode.
+	unknownFieldRegexp = regexp.MustCompile(`^field (.+) not found in type \S+$`)
+)
+
+// InputConfig is the content of the input yaml file (e.g., config_knative.yaml).
+type InputConfig struct {
+	Settings   map[string]orgSettings `yaml:"settings"`
+	Templates  map[string]jobTemplate `yaml:"templates"`
+	Schedule   scheduleConfig         `yaml:"schedule"`
+	Tide       tideConfig             `yaml:"tide"`
+	Releases   releasesConfig         `yaml:"releases"`
+	JobTypes   map[string]podConfig   `yaml:"job-types"`
+	Presubmits presubmitRepos         `yaml:"presubmits"`
+	Periodics  periodicRepos          `yaml:"periodics"`
+
+	// FileName is the name of the input file, locating the entries the generated jobs come from when explaining them.
+	FileName string `yaml:"-"`
+}
+
+// orgSettings contains the defaults for the jobs of the repositories in a GitHub organization.
+// Any setting not given falls back to the value set through the command-line flags.
+type orgSettings struct {
+	GcsBucket               string `yaml:"gcs-bucket"`
+	LogsDir                 string `yaml:"logs-dir"`
+	PresubmitLogsDir        string `yaml:"presubmit-logs-dir"`
+	ProwTestsDockerImage    string `yaml:"prow-tests-docker"`
+	CoverageDockerImage     string `yaml:"coverage-docker"`
+	MetricsDockerImage      string `yaml:"metrics-docker"`
+	TestAccount             string `yaml:"test-account"`
+	NightlyAccount          string `yaml:"nightly-account"`
+	ReleaseAccount          string `yaml:"release-account"`
+	ReleaseGcs              string `yaml:"release-gcs"`
+	ReleaseGcr              string `yaml:"release-gcr"`
+	GitHubTokenSecret       string `yaml:"github-token-secret"`
+	CoverageTokenSecret     string `yaml:"coverage-token-secret"`
+	TestgridDashboardPrefix string `yaml:"testgrid-dashboard-prefix"`
+	// DotDevDomain is the vanity import domain of the repositories with dot-dev set, "<org>.dev" by default.
+	DotDevDomain string `yaml:"dot-dev-domain"`
+}
+
+// tideConfig contains the settings of the merge automation not derived from the presubmit jobs.
+type tideConfig struct {
+	// Repos are the repositories (like "knative/website") merged by Tide without having presubmit jobs.
+	Repos []string `yaml:"repos"`
+	// ProtectedOrgs are the GitHub organizations whose branches are protected by the branch protector,
+	// the required contexts of their repositories are derived from the presubmit jobs.
+	ProtectedOrgs []string `yaml:"protected-orgs"`
+}
+
+// releasesConfig lists the repositories whose release branch jobs are generated from their branches on GitHub,
+// instead of being kept by hand in the input config. See ExpandReleaseBranches.
+type releasesConfig struct {
+	// Supported is the number of latest releases with jobs.
+	Supported int `yaml:"supported"`
+	// Repos are the repositories (like "knative/serving") whose release branches are listed.
+	Repos []string `yaml:"repos"`
+}
+
+// scheduleConfig contains the policy for scheduling the periodic jobs without an explicit cron.
+type scheduleConfig struct {
+	// MaxConcurrentJobs is the maximum number of periodic jobs running at once, no limit if 0.
+	MaxConcurrentJobs int `yaml:"max-concurrent-jobs"`
+	// TimeZone is the default time zone of the windows, like "America/Los_Angeles".
+	TimeZone string `yaml:"time-zone"`
+	// JobTypes overrides the default scheduling of the given periodic job types.
+	JobTypes map[string]jobTypeSchedule `yaml:"job-types"`
+}
+
+// jobTypeSchedule is the scheduling policy of a periodic job type.
+type jobTypeSchedule struct {
+	// Interval is "hourly" (every few hours, depending on the job timeout), "daily" or "weekly".
+	Interval string `yaml:"interval"`
+	// Window is the local time range the daily and weekly jobs start in, like "01:00-03:00".
+	Window string `yaml:"window"`
+	// Weekday is the local day the weekly jobs start on, like "Tuesday".
+	Weekday string `yaml:"weekday"`
+	// TimeZone is the time zone of the window, the default time zone if empty.
+	TimeZone string `yaml:"time-zone"`
+}
+
+// presubmitRepo contains the presubmit jobs of a repository.
+type presubmitRepo struct {
+	Name string
+	Jobs []presubmitJobConfig
+}
+
+// periodicRepo contains the periodic jobs of a repository.
+type periodicRepo struct {
+	Name string
+	Jobs []periodicJobConfig
+}
+
+// presubmitRepos is the list of repositories with presubmit jobs, in the order they appear in the input file.
+type presubmitRepos []presubmitRepo
+
+// periodicRepos is the list of repositories with periodic jobs, in the order they appear in the input file.
+type periodicRepos []periodicRepo
+
+// jobConfig contains the settings that can be used by any job.
+type jobConfig struct {
+	SkipBranches   []string     `yaml:"skip_branches"`
+	Branches       []string     `yaml:"branches"`
+	Args           []string     `yaml:"args"`
+	Timeout        int          `yaml:"timeout"`
+	Command        singleString `yaml:"command"`
+	FullCommand    string       `yaml:"full-command"`
+	NeedsDind      bool         `yaml:"needs-dind"`
+	AlwaysRun      *bool        `yaml:"always_run"`
+	DotDev         bool         `yaml:"dot-dev"`
+	LegacyBranches []string     `yaml:"legacy-branches"`
+	Env            []string     `yaml:"env"`
+	Volumes        []volume     `yaml:"volumes"`
+	Extends        string       `yaml:"extends"`
+	Alert          alertConfig  `yaml:"alert"`
+	podConfig      `yaml:",inline"`
+
+	// Type is the key defining the type of the job (e.g., "unit-tests"), empty if none.
+	Type string `yaml:"-"`
+	// Line is the line of the job in the input file.
+	Line int `yaml:"-"`
+}
+
+// volume is a volume mounted in the job container, an empty dir unless it's a secret.
+type volume struct {
+	Name        string `yaml:"name"`
+	MountPath   string `yaml:"mount-path"`
+	Secret      bool   `yaml:"secret"`
+	DefaultMode string `yaml:"default-mode"`
+}
+
+// alertConfig contains the alerting settings of the Testgrid tab of a periodic job, zero values being unset.
+type alertConfig struct {
+	// FailuresToAlert is the number of consecutive failed runs before alerting.
+	FailuresToAlert int `yaml:"failures-to-alert"`
+	// StaleResultsHours is the number of hours without new results before alerting.
+	StaleResultsHours int `yaml:"stale-results-hours"`
+	// Emails are the addresses the alerts are sent to.
+	Emails []string `yaml:"emails"`
+}
+
+// podConfig contains the settings of the pod running a job on the build cluster.
+type podConfig struct {
+	Resources    resourcesConfig   `yaml:"resources"`
+	NodeSelector map[string]string `yaml:"node-selector"`
+	Tolerations  []toleration      `yaml:"tolerations"`
+}
+
+// resourcesConfig contains the resources requested by the job container, and its limits.
+type resourcesConfig struct {
+	Requests resourceList `yaml:"requests"`
+	Limits   resourceList `yaml:"limits"`
+}
+
+// resourceList contains the amounts of CPU and memory, as Kubernetes quantities.
+type resourceList struct {
+	CPU    string `yaml:"cpu"`
+	Memory string `yaml:"memory"`
+}
+
+// toleration allows a job to run on the nodes with a matching taint.
+type toleration struct {
+	Key      string `yaml:"key"`
+	Operator string `yaml:"operator"`
+	Value    string `yaml:"value"`
+	Effect   string `yaml:"effect"`
+}
+
+// jobTemplate is an entry of the templates section, containing settings that jobs can reuse through "extends".
+type jobTemplate struct {
+	jobConfig `yaml:",inline"`
+}
+
+// presubmitJobConfig is an entry of the presubmits section.
+type presubmitJobConfig struct {
+	jobConfig `yaml:",inline"`
+
+	BuildTests          bool   `yaml:"build-tests"`
+	UnitTests           bool   `yaml:"unit-tests"`
+	IntegrationTests    bool   `yaml:"integration-tests"`
+	GoCoverage          bool   `yaml:"go-coverage"`
+	CustomTest          string `yaml:"custom-test"`
+	GoCoverageThreshold int    `yaml:"go-coverage-threshold"`
+	RunIfChanged        string `yaml:"run_if_changed"`
+	// RepoSettings has no value, it marks the entry as containing repository-wide settings.
+	RepoSettings interface{} `yaml:"repo-settings"`
+}
+
+// periodicJobConfig is an entry of the periodics section.
+type periodicJobConfig struct {
+	jobConfig `yaml:",inline"`
+
+	Continuous         bool   `yaml:"continuous"`
+	Nightly            bool   `yaml:"nightly"`
+	BranchCI           bool   `yaml:"branch-ci"`
+	DotRelease         bool   `yaml:"dot-release"`
+	AutoRelease        bool   `yaml:"auto-release"`
+	Performance        bool   `yaml:"performance"`
+	PerformanceMesh    bool   `yaml:"performance-mesh"`
+	Latency            bool   `yaml:"latency"`
+	WebhookAPICoverage bool   `yaml:"webhook-apicoverage"`
+	CustomJob          string `yaml:"custom-job"`
+	Cron               string `yaml:"cron"`
+	Release            string `yaml:"release"`
+}
+
+// singleString is a string that can also be written as an array with a single element.
+type singleString string
+
+// ParseInputConfig strictly parses the given input file content.
+// Any unknown key or value of the wrong type is reported as an error with its file and line.
+func ParseInputConfig(fileName string, content []byte) (InputConfig, error) {
+	config := InputConfig{FileName: fileName}
+	err := yaml.UnmarshalStrict(content, &config)
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		errs := make([]string, len(typeErr.Errors))
+		for i, e := range typeErr.Errors {
+			errs[i] = strings.Replace(e, "line ", fileName+":", 1)
+		}
+		return config, fmt.Errorf("invalid config:\n%s", strings.Join(errs, "\n"))
+	}
+	if err != nil {
+		return config, fmt.Errorf("%s: %v", fileName, err)
+	}
+	for orgName := range config.Settings {
+		if orgName == "" || strings.Contains(orgName, "/") {
+			return config, fmt.Errorf("%s: settings are given per GitHub organization, got %q", fileName, orgName)
+		}
+	}
+	for _, repoName := range config.Tide.Repos {
+		if parts := strings.Split(repoName, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return config, fmt.Errorf("%s: tide repositories must be like \"org/repo\", got %q", fileName, repoName)
+		}
+	}
+	for _, orgName := range config.Tide.ProtectedOrgs {
+		if orgName == "" || strings.Contains(orgName, "/") {
+			return config, fmt.Errorf("%s: protected orgs must be GitHub organizations, got %q", fileName, orgName)
+		}
+	}
+	if err := checkReleases(config.Releases); err != nil {
+		return config, fmt.Errorf("%s: %v", fileName, err)
+	}
+	if err := checkJobTypes(config.JobTypes); err != nil {
+		return config, fmt.Errorf("%s: %v", fileName, err)
+	}
+	if err := checkSchedule(config.Schedule); err != nil {
+		return config, fmt.Errorf("%s: %v", fileName, err)
+	}
+	if errs := checkTemplates(config); len(errs) > 0 {
+		return config, fmt.Errorf("invalid config:\n%s:%s", fileName, strings.Join(errs, "\n"+fileName+":"))
+	}
+	return config, nil
+}
+
+// checkJobTypes validates the pod settings given per job type.
+func checkJobTypes(jobTypes map[string]podConfig) error {
+	var names []string
+	for name := range jobTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "repo-settings" || (!strExists(presubmitJobTypes, name) && !strExists(periodicJobTypes, name)) {
+			return fmt.Errorf("unknown job type %q in job-types", name)
+		}
+		if errs := jobTypes[name].check(); len(errs) > 0 {
+			return fmt.Errorf("job type %q: %s", name, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// check returns the problems found in the pod settings.
+func (p podConfig) check() []string {
+	var errs []string
+	for _, quantity := range []string{p.Resources.Requests.CPU, p.Resources.Requests.Memory, p.Resources.Limits.CPU, p.Resources.Limits.Memory} {
+		if quantity != "" && !quantityRegexp.MatchString(quantity) {
+			errs = append(errs, fmt.Sprintf("invalid resource quantity %q", quantity))
+		}
+	}
+	for _, t := range p.Tolerations {
+		switch {
+		case t.Operator != "" && t.Operator != "Equal" && t.Operator != "Exists":
+			errs = append(errs, fmt.Sprintf("toleration operator must be Equal or Exists, got %q", t.Operator))
+		case t.Operator == "Exists" && t.Value != "":
+			errs = append(errs, fmt.Sprintf("toleration of %q with operator Exists cannot have a value", t.Key))
+		case t.Operator != "Exists" && t.Key == "":
+			errs = append(errs, "toleration without a key requires operator Exists")
+		}
+		if t.Effect != "" && t.Effect != "NoSchedule" && t.Effect != "PreferNoSchedule" && t.Effect != "NoExecute" {
+			errs = append(errs, fmt.Sprintf("toleration effect must be NoSchedule, PreferNoSchedule or NoExecute, got %q", t.Effect))
+		}
+	}
+	return errs
+}
+
+// checkReleases validates the releases section.
+func checkReleases(releases releasesConfig) error {
+	if len(releases.Repos) > 0 && releases.Supported <= 0 {
+		return fmt.Errorf("the number of supported releases must be positive, got %d", releases.Supported)
+	}
+	for _, repoName := range releases.Repos {
+		if parts := strings.Split(repoName, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("release repositories must be like \"org/repo\", got %q", repoName)
+		}
+	}
+	return nil
+}
+
+// checkSchedule validates the settings of the schedule section not already validated when decoding it.
+func checkSchedule(schedule scheduleConfig) error {
+	if schedule.MaxConcurrentJobs < 0 {
+		return fmt.Errorf("max-concurrent-jobs must be positive, got %d", schedule.MaxConcurrentJobs)
+	}
+	if _, err := time.LoadLocation(schedule.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q: %v", schedule.TimeZone, err)
+	}
+	for jobType := range schedule.JobTypes {
+		if !strExists(periodicJobTypes, jobType) {
+			return fmt.Errorf("cannot schedule unknown job type %q, expected one of %s", jobType, strings.Join(periodicJobTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// checkTemplates returns the errors (prefixed by their line) of the templates extended by the jobs and other templates.
+func checkTemplates(config InputConfig) []string {
+	var errs []string
+	check := func(job jobConfig) {
+		seen := make(map[string]bool)
+		for name := job.Extends; name != ""; name = config.Templates[name].Extends {
+			if seen[name] {
+				errs = append(errs, fmt.Sprintf("%d: templates extend each other in a loop through %q", job.Line, name))
+				return
+			}
+			seen[name] = true
+			if _, exists := config.Templates[name]; !exists {
+				errs = append(errs, fmt.Sprintf("%d: unknown template %q", job.Line, name))
+				return
+			}
+		}
+	}
+	// Sort the templates so errors are reported in the same order.
+	names := make([]string, 0, len(config.Templates))
+	for name := range config.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check(config.Templates[name].jobConfig)
+	}
+	for _, repo := range config.Presubmits {
+		for _, job := range repo.Jobs {
+			check(job.jobConfig)
+		}
+	}
+	for _, repo := range config.Periodics {
+		for _, job := range repo.Jobs {
+			check(job.jobConfig)
+		}
+	}
+	return errs
+}
+
+// expandJobConfig returns the given job config merged with the templates it extends.
+// Settings of the job override the ones of its templates, except for:
+// - args, appended to the template args (unless the job sets full-command),
+// - env, merged by variable name,
+// - volumes, merged by volume name,
+// - needs-dind and dot-dev, enabled if set by either.
+func expandJobConfig(config jobConfig, templates map[string]jobTemplate) jobConfig {
+	if config.Extends == "" {
+		return config
+	}
+	base := expandJobConfig(templates[config.Extends].jobConfig, templates)
+	res := config
+	res.Extends = ""
+	if res.SkipBranches == nil {
+		res.SkipBranches = base.SkipBranches
+	}
+	if res.Branches == nil {
+		res.Branches = base.Branches
+	}
+	if res.Timeout == 0 {
+		res.Timeout = base.Timeout
+	}
+	if res.FullCommand == "" {
+		// Use the command and args of the template, converting its full command if needed.
+		if base.FullCommand != "" {
+			parts := strings.Split(base.FullCommand, " ")
+			base.Command, base.Args = singleString(parts[0]), parts[1:]
+		}
+		if res.Command == "" {
+			res.Command = base.Command
+		}
+		if base.Args != nil || res.Args != nil {
+			res.Args = append(append([]string{}, base.Args...), config.Args...)
+		}
+	}
+	res.NeedsDind = base.NeedsDind || res.NeedsDind
+	if res.AlwaysRun == nil {
+		res.AlwaysRun = base.AlwaysRun
+	}
+	res.DotDev = base.DotDev || res.DotDev
+	if res.LegacyBranches == nil {
+		res.LegacyBranches = base.LegacyBranches
+	}
+	res.Env = mergeEnv(base.Env, config.Env)
+	res.Volumes = mergeVolumes(base.Volumes, config.Volumes)
+	res.Alert = mergeAlertConfig(base.Alert, config.Alert)
+	res.podConfig = mergePodConfig(base.podConfig, config.podConfig)
+	return res
+}
+
+// mergeAlertConfig returns the base alerting settings overridden by the given ones.
+func mergeAlertConfig(base, overrides alertConfig) alertConfig {
+	res := base
+	if overrides.FailuresToAlert != 0 {
+		res.FailuresToAlert = overrides.FailuresToAlert
+	}
+	if overrides.StaleResultsHours != 0 {
+		res.StaleResultsHours = overrides.StaleResultsHours
+	}
+	if overrides.Emails != nil {
+		res.Emails = overrides.Emails
+	}
+	return res
+}
+
+// check returns the problems found in the alerting settings.
+func (a alertConfig) check() []string {
+	var errs []string
+	if a.FailuresToAlert < 0 {
+		errs = append(errs, fmt.Sprintf("failures-to-alert must be positive, got %d", a.FailuresToAlert))
+	}
+	if a.StaleResultsHours < 0 {
+		errs = append(errs, fmt.Sprintf("stale-results-hours must be positive, got %d", a.StaleResultsHours))
+	}
+	for _, email := range a.Emails {
+		if !emailRegexp.MatchString(email) {
+			errs = append(errs, fmt.Sprintf("invalid alert email %q", email))
+		}
+	}
+	return errs
+}
+
+// mergePodConfig returns the base pod settings overridden by the given ones.
+// Resources and node selectors are overridden one by one, tolerations as a whole.
+func mergePodConfig(base, overrides podConfig) podConfig {
+	res := base
+	for _, q := range []struct{ base, override *string }{
+		{&res.Resources.Requests.CPU, &overrides.Resources.Requests.CPU},
+		{&res.Resources.Requests.Memory, &overrides.Resources.Requests.Memory},
+		{&res.Resources.Limits.CPU, &overrides.Resources.Limits.CPU},
+		{&res.Resources.Limits.Memory, &overrides.Resources.Limits.Memory},
+	} {
+		if *q.override != "" {
+			*q.base = *q.override
+		}
+	}
+	if len(overrides.NodeSelector) > 0 {
+		res.NodeSelector = make(map[string]string)
+		for k, v := range base.NodeSelector {
+			res.NodeSelector[k] = v
+		}
+		for k, v := range overrides.NodeSelector {
+			res.NodeSelector[k] = v
+		}
+	}
+	if overrides.Tolerations != nil {
+		res.Tolerations = overrides.Tolerations
+	}
+	return res
+}
+
+// mergeEnv returns the base environment variables overridden by the given ones, keeping their order.
+func mergeEnv(base, overrides []string) []string {
+	res := append([]string(nil), base...)
+	for _, env := range overrides {
+		name := strings.SplitN(env, "=", 2)[0]
+		found := false
+		for i := range res {
+			if strings.SplitN(res[i], "=", 2)[0] == name {
+				res[i] = env
+				found = true
+			}
+		}
+		if !found {
+			res = append(res, env)
+		}
+	}
+	return res
+}
+
+// mergeVolumes returns the base volumes overridden by the given ones, keeping their order.
+func mergeVolumes(base, overrides []volume) []volume {
+	res := append([]volume(nil), base...)
+	for _, v := range overrides {
+		found := false
+		for i := range res {
+			if res[i].Name == v.Name {
+				res[i] = v
+				found = true
+			}
+		}
+		if !found {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// UnmarshalYAML keeps the repositories in the same order as in the input file.
+func (r *presubmitRepos) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	names, err := orderedKeys(unmarshal)
+	if err != nil {
+		return err
+	}
+	jobs := make(map[string][]presubmitJobConfig)
+	if err := unmarshal(&jobs); err != nil {
+		return err
+	}
+	for _, name := range names {
+		*r = append(*r, presubmitRepo{Name: name, Jobs: jobs[name]})
+	}
+	return nil
+}
+
+// UnmarshalYAML keeps the repositories in the same order as in the input file.
+func (r *periodicRepos) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	names, err := orderedKeys(unmarshal)
+	if err != nil {
+		return err
+	}
+	jobs := make(map[string][]periodicJobConfig)
+	if err := unmarshal(&jobs); err != nil {
+		return err
+	}
+	for _, name := range names {
+		*r = append(*r, periodicRepo{Name: name, Jobs: jobs[name]})
+	}
+	return nil
+}
+
+// UnmarshalYAML decodes and validates a presubmit job entry.
+func (j *presubmitJobConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain presubmitJobConfig
+	j.GoCoverageThreshold = 50
+	return unmarshalJob(unmarshal, (*plain)(j), &j.jobConfig, presubmitJobTypes, true)
+}
+
+// UnmarshalYAML decodes and validates a periodic job entry.
+func (j *periodicJobConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain periodicJobConfig
+	return unmarshalJob(unmarshal, (*plain)(j), &j.jobConfig, periodicJobTypes, false)
+}
+
+// UnmarshalYAML decodes and validates a job template.
+func (j *jobTemplate) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain jobTemplate
+	return unmarshalJob(unmarshal, (*plain)(j), &j.jobConfig, nil, false)
+}
+
+// UnmarshalYAML decodes and validates the scheduling policy of a periodic job type.
+func (s *jobTypeSchedule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain jobTypeSchedule
+	line := nodeLine(unmarshal)
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	var errs []string
+	switch s.Interval {
+	case "hourly", "daily", "weekly":
+	default:
+		errs = append(errs, fmt.Sprintf("line %d: interval must be hourly, daily or weekly, got %q", line, s.Interval))
+	}
+	if s.Interval != "hourly" {
+		if _, _, err := parseWindow(s.Window); err != nil {
+			errs = append(errs, fmt.Sprintf("line %d: %v", line, err))
+		}
+	}
+	if _, err := parseWeekday(s.Weekday); (s.Interval == "weekly" || s.Weekday != "") && err != nil {
+		errs = append(errs, fmt.Sprintf("line %d: %v", line, err))
+	}
+	if _, err := time.LoadLocation(s.TimeZone); err != nil {
+		errs = append(errs, fmt.Sprintf("line %d: invalid time zone %q: %v", line, s.TimeZone, err))
+	}
+	if len(errs) > 0 {
+		return &yaml.TypeError{Errors: errs}
+	}
+	return nil
+}
+
+// UnmarshalYAML accepts either a string or an array with a single string.
+func (s *singleString) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err == nil {
+		*s = singleString(str)
+		return nil
+	}
+	var arr []string
+	if err := unmarshal(&arr); err != nil {
+		return err
+	}
+	if len(arr) != 1 {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: expected a string or an array with a single string, got %d strings", nodeLine(unmarshal), len(arr))}}
+	}
+	*s = singleString(arr[0])
+	return nil
+}
+
+// unmarshalJob decodes a job entry into out, recording its type and line in base.
+// Errors are annotated with the job they refer to.
+func unmarshalJob(unmarshal func(interface{}) error, out interface{}, base *jobConfig, jobTypes []string, typeRequired bool) error {
+	base.Line = nodeLine(unmarshal)
+	var raw yaml.MapSlice
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	job := describeJob(raw)
+	if err := unmarshal(out); err != nil {
+		return annotateErrors(err, job)
+	}
+
+	var errs []string
+	for _, item := range raw {
+		key := fmt.Sprint(item.Key)
+		if !strExists(jobTypes, key) {
+			continue
+		}
+		if base.Type != "" {
+			errs = append(errs, fmt.Sprintf("line %d: job %q: conflicting job types %q and %q", base.Line, job, base.Type, key))
+			continue
+		}
+		base.Type = key
+	}
+	if base.Type == "" && typeRequired {
+		errs = append(errs, fmt.Sprintf("line %d: job %q: missing job type, expected one of %s", base.Line, job, strings.Join(jobTypes, ", ")))
+	}
+	if base.FullCommand != "" && (base.Command != "" || len(base.Args) > 0) {
+		errs = append(errs, fmt.Sprintf("line %d: job %q: full-command cannot be used with command or args", base.Line, job))
+	}
+	if base.Timeout < 0 {
+		errs = append(errs, fmt.Sprintf("line %d: job %q: timeout must be positive", base.Line, job))
+	}
+	for _, env := range base.Env {
+		if !strings.Contains(env, "=") || strings.HasPrefix(env, "=") {
+			errs = append(errs, fmt.Sprintf("line %d: job %q: environment variable %q is expected to be \"key=value\"", base.Line, job, env))
+		}
+	}
+	for _, v := range base.Volumes {
+		if v.Name == "" || v.MountPath == "" {
+			errs = append(errs, fmt.Sprintf("line %d: job %q: volumes require a name and a mount-path", base.Line, job))
+		}
+	}
+	for _, e := range base.podConfig.check() {
+		errs = append(errs, fmt.Sprintf("line %d: job %q: %s", base.Line, job, e))
+	}
+	for _, e := range base.Alert.check() {
+		errs = append(errs, fmt.Sprintf("line %d: job %q: %s", base.Line, job, e))
+	}
+	// Only periodic jobs have a Testgrid tab, the alerts of repo-settings are the defaults of the repository ones.
+	hasAlert := !reflect.DeepEqual(base.Alert, alertConfig{})
+	if hasAlert && (base.Type == "webhook-apicoverage" || (strExists(presubmitJobTypes, base.Type) && base.Type != "repo-settings")) {
+		errs = append(errs, fmt.Sprintf("line %d: job %q: alert is only supported by repo-settings and periodic jobs with a Testgrid tab", base.Line, job))
+	}
+	if len(errs) > 0 {
+		return &yaml.TypeError{Errors: errs}
+	}
+	return nil
+}
+
+// orderedKeys returns the keys of the mapping being decoded, in the order they appear.
+func orderedKeys(unmarshal func(interface{}) error) ([]string, error) {
+	var raw yaml.MapSlice
+	if err := unmarshal(&raw); err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(raw))
+	for i, item := range raw {
+		keys[i] = fmt.Sprint(item.Key)
+	}
+	return keys, nil
+}
+
+// nodeLine returns the line of the node being decoded.
+// yaml doesn't expose it, so it's extracted from the error of decoding the node into an incompatible type.
+func nodeLine(unmarshal func(interface{}) error) int {
+	var probe int
+	err := unmarshal(&probe)
+	if err == nil {
+		var arr []int
+		err = unmarshal(&arr)
+	}
+	if typeErr, ok := err.(*yaml.TypeError); ok && len(typeErr.Errors) > 0 {
+		var line int
+		if _, err := fmt.Sscanf(typeErr.Errors[0], "line %d:", &line); err == nil {
+			return line
+		}
+	}
+	return 0
+}
+
+// describeJob returns a short description of the job, based on its first key (e.g., "custom-test: upgrade-tests").
+func describeJob(raw yaml.MapSlice) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	if raw[0].Value == nil {
+		return fmt.Sprint(raw[0].Key)
+	}
+	return fmt.Sprintf("%v: %v", raw[0].Key, raw[0].Value)
+}
+
+// annotateErrors adds the job description to each decoding error, and makes unknown key errors more readable.
+func annotateErrors(err error, job string) error {
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return err
+	}
+	errs := make([]string, len(typeErr.Errors))
+	for i, e := range typeErr.Errors {
+		parts := strings.SplitN(e, ": ", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "line ") {
+			errs[i] = fmt.Sprintf("job %q: %s", job, e)
+			continue
+		}
+		msg := parts[1]
+		if m := unknownFieldRegexp.FindStringSubmatch(msg); m != nil {
+			msg = "unknown key " + strconv.Quote(m[1])
+		}
+		errs[i] = fmt.Sprintf("%s: job %q: %s", parts[0], job, msg)
+	}
+	return &yaml.TypeError{Errors: errs}
+}
+
+// enabled returns whether the job defined by the entry must be generated.
+func (j presubmitJobConfig) enabled() bool {
+	switch j.Type {
+	case "build-tests":
+		return j.BuildTests
+	case "unit-tests":
+		return j.UnitTests
+	case "integration-tests":
+		return j.IntegrationTests
+	case "go-coverage":
+		return j.GoCoverage
+	}
+	return true
+}
+
+// enabled returns whether the job defined by the entry must be generated.
+func (j periodicJobConfig) enabled() bool {
+	switch j.Type {
+	case "continuous":
+		return j.Continuous
+	case "nightly":
+		return j.Nightly
+	case "branch-ci":
+		return j.BranchCI
+	case "dot-release":
+		return j.DotRelease
+	case "auto-release":
+		return j.AutoRelease
+	case "performance":
+		return j.Performance
+	case "performance-mesh":
+		return j.PerformanceMesh
+	case "latency":
+		return j.Latency
+	case "webhook-apicoverage":
+		return j.WebhookAPICoverage
+	}
+	return true
+}
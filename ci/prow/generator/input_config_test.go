@@ -0,0 +1,486 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// input_config_test.go contains unit tests for parsing the input config
+
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const validInputConfig = `
+settings:
+  google:
+    gcs-bucket: google-prow
+    testgrid-dashboard-prefix: google-
+
+presubmits:
+  knative/serving:
+    - repo-settings:
+      legacy-branches:
+      - release-0.4
+    - unit-tests: true
+    - custom-test: perf-tests
+      always_run: false
+      command:
+      - "./test/performance-tests.sh"
+    - go-coverage: true
+  knative/build:
+    - build-tests: false
+
+periodics:
+  knative/serving:
+    - branch-ci: true
+      release: "0.4"
+    - custom-job: istio-1.0-mesh
+      full-command: "./test/e2e-tests.sh --mesh"
+      cron: "0 * * * *"
+`
+
+func TestParseInputConfig(t *testing.T) {
+	config, err := ParseInputConfig("test.yaml", []byte(validInputConfig))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(config.Presubmits) != 2 || config.Presubmits[0].Name != "knative/serving" || config.Presubmits[1].Name != "knative/build" {
+		t.Fatalf("Expected presubmits for knative/serving and knative/build, got %+v", config.Presubmits)
+	}
+	jobs := config.Presubmits[0].Jobs
+	if len(jobs) != 4 {
+		t.Fatalf("Expected 4 presubmit jobs, got %d", len(jobs))
+	}
+	if jobs[0].Type != "repo-settings" || len(jobs[0].LegacyBranches) != 1 {
+		t.Errorf("Expected repo-settings with 1 legacy branch, got %+v", jobs[0])
+	}
+	if jobs[1].Type != "unit-tests" || !jobs[1].enabled() || jobs[1].Line != 12 {
+		t.Errorf("Expected enabled unit-tests at line 12, got %+v", jobs[1])
+	}
+	if jobs[2].Command != "./test/performance-tests.sh" || jobs[2].AlwaysRun == nil || *jobs[2].AlwaysRun {
+		t.Errorf("Expected custom-test with command and always_run false, got %+v", jobs[2])
+	}
+	if jobs[3].GoCoverageThreshold != 50 {
+		t.Errorf("Expected default go coverage threshold 50, got %d", jobs[3].GoCoverageThreshold)
+	}
+	if config.Presubmits[1].Jobs[0].enabled() {
+		t.Error("Expected build-tests to be disabled")
+	}
+
+	if settings := config.Settings["google"]; settings.GcsBucket != "google-prow" || settings.TestgridDashboardPrefix != "google-" {
+		t.Errorf("Expected settings for the google org, got %+v", config.Settings)
+	}
+
+	periodics := config.Periodics[0].Jobs
+	if periodics[0].Type != "branch-ci" || periodics[0].Release != "0.4" {
+		t.Errorf("Expected branch-ci for release 0.4, got %+v", periodics[0])
+	}
+	if periodics[1].CustomJob != "istio-1.0-mesh" || periodics[1].Cron != "0 * * * *" {
+		t.Errorf("Expected custom job with cron, got %+v", periodics[1])
+	}
+}
+
+var invalidInputConfigTests = []struct {
+	name   string
+	config string
+	errs   []string
+}{
+	{
+		name: "unknown key",
+		config: `
+presubmits:
+  knative/serving:
+    - custom-test: perf-tests
+      alway_run: false
+`,
+		errs: []string{`test.yaml:5: job "custom-test: perf-tests": unknown key "alway_run"`},
+	},
+	{
+		name: "wrong type",
+		config: `
+periodics:
+  knative/serving:
+    - continuous: true
+      timeout: abc
+`,
+		errs: []string{`test.yaml:5: job "continuous: true": cannot unmarshal !!str ` + "`abc`" + ` into int`},
+	},
+	{
+		name: "all errors reported",
+		config: `
+presubmits:
+  knative/serving:
+    - go-coverage: true
+      go-coverage-treshold: 80
+    - always_run: false
+    - unit-tests: true
+      integration-tests: true
+`,
+		errs: []string{
+			`test.yaml:5: job "go-coverage: true": unknown key "go-coverage-treshold"`,
+			`test.yaml:6: job "always_run: false": missing job type`,
+			`test.yaml:7: job "unit-tests: true": conflicting job types "unit-tests" and "integration-tests"`,
+		},
+	},
+	{
+		name: "command array",
+		config: `
+presubmits:
+  knative/serving:
+    - custom-test: foo
+      command: ["a", "b"]
+`,
+		errs: []string{`test.yaml:5: job "custom-test: foo": expected a string or an array with a single string, got 2 strings`},
+	},
+	{
+		name: "full-command and args",
+		config: `
+periodics:
+  knative/serving:
+    - custom-job: foo
+      full-command: "./foo.sh --bar"
+      args: ["--baz"]
+`,
+		errs: []string{`test.yaml:4: job "custom-job: foo": full-command cannot be used with command or args`},
+	},
+	{
+		name: "settings for a repo",
+		config: `
+settings:
+  knative/serving:
+    gcs-bucket: foo
+`,
+		errs: []string{`test.yaml: settings are given per GitHub organization, got "knative/serving"`},
+	},
+	{
+		name: "unknown setting",
+		config: `
+settings:
+  knative:
+    gcs-buckets: foo
+`,
+		errs: []string{`test.yaml:4: field gcs-buckets not found in type generator.orgSettings`},
+	},
+	{
+		name: "unknown template",
+		config: `
+presubmits:
+  knative/serving:
+    - custom-test: foo
+      extends: run-tests
+`,
+		errs: []string{`test.yaml:4: unknown template "run-tests"`},
+	},
+	{
+		name: "templates loop",
+		config: `
+templates:
+  a:
+    extends: b
+  b:
+    extends: a
+`,
+		errs: []string{`test.yaml:4: templates extend each other in a loop through "b"`},
+	},
+	{
+		name: "invalid env and volume",
+		config: `
+templates:
+  a:
+    env: ["FOO"]
+    volumes:
+    - name: foo
+`,
+		errs: []string{
+			`test.yaml:4: job "env: [FOO]": environment variable "FOO" is expected to be "key=value"`,
+			`test.yaml:4: job "env: [FOO]": volumes require a name and a mount-path`,
+		},
+	},
+	{
+		name: "invalid job type schedule",
+		config: `
+schedule:
+  job-types:
+    nightly:
+      interval: monthly
+      window: 1:00-25:00
+      weekday: Funday
+      time-zone: Mars/Olympus
+`,
+		errs: []string{
+			`test.yaml:5: interval must be hourly, daily or weekly, got "monthly"`,
+			`test.yaml:5: window must be like "01:00-03:00", got "1:00-25:00"`,
+			`test.yaml:5: weekday must be a day name like "Tuesday", got "Funday"`,
+			`test.yaml:5: invalid time zone "Mars/Olympus"`,
+		},
+	},
+	{
+		name: "unknown scheduled job type",
+		config: `
+schedule:
+  job-types:
+    hourly:
+      interval: hourly
+`,
+		errs: []string{`cannot schedule unknown job type "hourly"`},
+	},
+	{
+		name: "negative concurrent jobs",
+		config: `
+schedule:
+  max-concurrent-jobs: -1
+`,
+		errs: []string{`max-concurrent-jobs must be positive, got -1`},
+	},
+	{
+		name: "invalid tide repository",
+		config: `
+tide:
+  repos:
+  - website
+`,
+		errs: []string{`test.yaml: tide repositories must be like "org/repo", got "website"`},
+	},
+	{
+		name: "invalid protected org",
+		config: `
+tide:
+  protected-orgs:
+  - knative/serving
+`,
+		errs: []string{`test.yaml: protected orgs must be GitHub organizations, got "knative/serving"`},
+	},
+	{
+		name: "no supported releases",
+		config: `
+releases:
+  repos:
+  - knative/serving
+`,
+		errs: []string{`test.yaml: the number of supported releases must be positive, got 0`},
+	},
+	{
+		name: "invalid release repository",
+		config: `
+releases:
+  supported: 3
+  repos:
+  - serving
+`,
+		errs: []string{`test.yaml: release repositories must be like "org/repo", got "serving"`},
+	},
+	{
+		name: "invalid pod settings",
+		config: `
+presubmits:
+  knative/serving:
+    - unit-tests: true
+      resources:
+        requests:
+          cpu: lots
+      tolerations:
+      - key: dedicated
+        operator: Exists
+        value: tests
+      - operator: Equal
+        effect: Never
+`,
+		errs: []string{
+			`test.yaml:4: job "unit-tests: true": invalid resource quantity "lots"`,
+			`test.yaml:4: job "unit-tests: true": toleration of "dedicated" with operator Exists cannot have a value`,
+			`test.yaml:4: job "unit-tests: true": toleration without a key requires operator Exists`,
+			`test.yaml:4: job "unit-tests: true": toleration effect must be NoSchedule, PreferNoSchedule or NoExecute, got "Never"`,
+		},
+	},
+	{
+		name: "unknown job type defaults",
+		config: `
+job-types:
+  e2e-tests:
+    resources:
+      requests:
+        cpu: 1
+`,
+		errs: []string{`test.yaml: unknown job type "e2e-tests" in job-types`},
+	},
+	{
+		name: "invalid job type defaults",
+		config: `
+job-types:
+  performance:
+    tolerations:
+    - key: heavy
+      operator: Sometimes
+`,
+		errs: []string{`test.yaml: job type "performance": toleration operator must be Equal or Exists, got "Sometimes"`},
+	},
+	{
+		name: "invalid alert settings",
+		config: `
+presubmits:
+  knative/serving:
+    - unit-tests: true
+      alert:
+        emails: [oncall@knative.dev]
+periodics:
+  knative/serving:
+    - continuous: true
+      alert:
+        failures-to-alert: -1
+        emails: [oncall]
+`,
+		errs: []string{
+			`test.yaml:4: job "unit-tests: true": alert is only supported by repo-settings and periodic jobs with a Testgrid tab`,
+			`test.yaml:9: job "continuous: true": failures-to-alert must be positive, got -1`,
+			`test.yaml:9: job "continuous: true": invalid alert email "oncall"`,
+		},
+	},
+	{
+		name: "unknown section",
+		config: `
+presubmit:
+  knative/serving:
+    - unit-tests: true
+`,
+		errs: []string{`test.yaml:2: field presubmit not found in type generator.InputConfig`},
+	},
+}
+
+func TestGetOrgSettings(t *testing.T) {
+	g := newGenerator(InputConfig{Settings: map[string]orgSettings{"google": {GcsBucket: "google-prow"}}}, DefaultOptions(), nil)
+
+	if settings := g.getOrgSettings("google"); settings.GcsBucket != "google-prow" || settings.LogsDir != "logs" {
+		t.Errorf("Expected google-prow bucket and default logs dir, got %+v", settings)
+	}
+	if settings := g.getOrgSettings("knative"); settings.GcsBucket != "knative-prow" {
+		t.Errorf("Expected default bucket for an org without settings, got %+v", settings)
+	}
+}
+
+func TestExpandJobConfig(t *testing.T) {
+	config, err := ParseInputConfig("test.yaml", []byte(`
+templates:
+  base:
+    timeout: 90
+    full-command: "./test/e2e-tests.sh --run"
+    env: ["FOO=1", "BAR=2"]
+    volumes:
+    - name: cache
+      mount-path: /cache
+  e2e:
+    extends: base
+    needs-dind: true
+    always_run: false
+presubmits:
+  knative/serving:
+    - custom-test: e2e
+      extends: e2e
+      args: ["--mesh"]
+      env: ["BAR=3", "BAZ=4"]
+    - custom-test: other
+      extends: e2e
+      full-command: "./test/other.sh"
+      timeout: 10
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	alwaysRun := false
+	expected := []jobConfig{
+		{
+			Type:      "custom-test",
+			Line:      16,
+			Timeout:   90,
+			Command:   "./test/e2e-tests.sh",
+			Args:      []string{"--run", "--mesh"},
+			NeedsDind: true,
+			AlwaysRun: &alwaysRun,
+			Env:       []string{"FOO=1", "BAR=3", "BAZ=4"},
+			Volumes:   []volume{{Name: "cache", MountPath: "/cache"}},
+		},
+		{
+			Type:        "custom-test",
+			Line:        20,
+			Timeout:     10,
+			FullCommand: "./test/other.sh",
+			NeedsDind:   true,
+			AlwaysRun:   &alwaysRun,
+			Env:         []string{"FOO=1", "BAR=2"},
+			Volumes:     []volume{{Name: "cache", MountPath: "/cache"}},
+		},
+	}
+	for i, job := range config.Presubmits[0].Jobs {
+		if res := expandJobConfig(job.jobConfig, config.Templates); !reflect.DeepEqual(res, expected[i]) {
+			t.Errorf("Expected job %d to be expanded to %+v, got %+v", i, expected[i], res)
+		}
+	}
+}
+
+func TestMergePodConfig(t *testing.T) {
+	base := podConfig{
+		Resources:    resourcesConfig{Requests: resourceList{CPU: "1", Memory: "2Gi"}},
+		NodeSelector: map[string]string{"pool": "default", "zone": "a"},
+		Tolerations:  []toleration{{Key: "dedicated", Operator: "Equal", Value: "tests", Effect: "NoSchedule"}},
+	}
+	overrides := podConfig{
+		Resources:    resourcesConfig{Requests: resourceList{CPU: "4"}, Limits: resourceList{Memory: "8Gi"}},
+		NodeSelector: map[string]string{"pool": "heavy"},
+		Tolerations:  []toleration{{Key: "heavy", Operator: "Exists"}},
+	}
+	expected := podConfig{
+		Resources:    resourcesConfig{Requests: resourceList{CPU: "4", Memory: "2Gi"}, Limits: resourceList{Memory: "8Gi"}},
+		NodeSelector: map[string]string{"pool": "heavy", "zone": "a"},
+		Tolerations:  []toleration{{Key: "heavy", Operator: "Exists"}},
+	}
+	if res := mergePodConfig(base, overrides); !reflect.DeepEqual(res, expected) {
+		t.Errorf("Expected pod settings %+v, got %+v", expected, res)
+	}
+	if res := mergePodConfig(base, podConfig{}); !reflect.DeepEqual(res, base) {
+		t.Errorf("Expected base pod settings %+v, got %+v", base, res)
+	}
+	if base.NodeSelector["pool"] != "default" {
+		t.Errorf("Expected base node selector to be unchanged, got %v", base.NodeSelector)
+	}
+}
+
+func TestMergeAlertConfig(t *testing.T) {
+	base := alertConfig{FailuresToAlert: 3, StaleResultsHours: 24, Emails: []string{"serving@knative.dev"}}
+	overrides := alertConfig{FailuresToAlert: 1, Emails: []string{"oncall@knative.dev"}}
+	expected := alertConfig{FailuresToAlert: 1, StaleResultsHours: 24, Emails: []string{"oncall@knative.dev"}}
+	if res := mergeAlertConfig(base, overrides); !reflect.DeepEqual(res, expected) {
+		t.Errorf("Expected alert settings %+v, got %+v", expected, res)
+	}
+	if res := mergeAlertConfig(base, alertConfig{}); !reflect.DeepEqual(res, base) {
+		t.Errorf("Expected base alert settings %+v, got %+v", base, res)
+	}
+}
+
+func TestParseInvalidInputConfig(t *testing.T) {
+	for _, test := range invalidInputConfigTests {
+		_, err := ParseInputConfig("test.yaml", []byte(test.config))
+		if err == nil {
+			t.Errorf("%s: expected error, got none", test.name)
+			continue
+		}
+		for _, expected := range test.errs {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("%s: expected error %q, got %q", test.name, expected, err)
+			}
+		}
+	}
+}
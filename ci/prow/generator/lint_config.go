@@ -0,0 +1,151 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// validation of the generated Prow jobs, catching problems before Prow rejects or mishandles them
+
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxJobNameLength is the maximum length of a job name, as it's used as a Kubernetes label value.
+	maxJobNameLength = 63
+)
+
+var (
+	// jobNameRegexp matches valid Kubernetes label values, which job names are used as.
+	jobNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([-a-zA-Z0-9_.]*[a-zA-Z0-9])?$`)
+)
+
+// recordJobForLinting saves the given job data (a presubmit, postsubmit or periodic job template data) for validation.
+func (g *generator) recordJobForLinting(repoName, jobName string, data interface{}) {
+	job := Job{RepoName: repoName, Name: jobName}
+	var base baseProwJobTemplateData
+	switch v := data.(type) {
+	case presubmitJobTemplateData:
+		job.Kind, base = "presubmit", v.Base
+	case *presubmitJobTemplateData:
+		job.Kind, base = "presubmit", v.Base
+	case postsubmitJobTemplateData:
+		job.Kind, base = "postsubmit", v.Base
+	case *postsubmitJobTemplateData:
+		job.Kind, base = "postsubmit", v.Base
+	case periodicJobTemplateData:
+		job.Kind, base, job.Cron = "periodic", v.Base, v.CronString
+	case *periodicJobTemplateData:
+		job.Kind, base, job.Cron = "periodic", v.Base, v.CronString
+	default:
+		fatalf("unrecognized job template type: '%v'", v)
+	}
+	// Branch lists are updated in place when generating variants of the same job, keep a copy.
+	job.Branches = append([]string(nil), base.Branches...)
+	job.SkipBranches = append([]string(nil), base.SkipBranches...)
+	job.Args = append([]string(nil), base.Args...)
+	job.AlwaysRun, job.Optional, job.RunIfChanged = base.AlwaysRun, base.Optional, base.RunIfChanged
+	job.Image, job.Command, job.Timeout = base.Image, base.Command, base.Timeout
+	job.Fields = g.explainJob(job.Kind, base, job.Cron)
+	g.jobs = append(g.jobs, job)
+}
+
+// lintJobs validates the given jobs, returning all problems found.
+func lintJobs(jobs []Job) []string {
+	var problems []string
+	report := func(job Job, format string, args ...interface{}) {
+		where := job.Kind
+		if job.RepoName != "" {
+			where += " for " + job.RepoName
+		}
+		problem := fmt.Sprintf("%s (%s): %s", job.Name, where, fmt.Sprintf(format, args...))
+		// Variants of the same job for different branches share the same problems, report them only once.
+		if !strExists(problems, problem) {
+			problems = append(problems, problem)
+		}
+	}
+	for i, job := range jobs {
+		switch {
+		case job.Name == "":
+			report(job, "job name is empty")
+		case len(job.Name) > maxJobNameLength:
+			report(job, "job name is %d characters long, more than the %d characters allowed for Kubernetes labels", len(job.Name), maxJobNameLength)
+		case !jobNameRegexp.MatchString(job.Name):
+			report(job, "job name must contain only alphanumeric characters, '-', '_' or '.', and start and end with an alphanumeric character")
+		}
+		if job.Timeout <= 0 {
+			report(job, "timeout must be positive, got %d", job.Timeout)
+		}
+		if job.Kind == "periodic" {
+			if job.Command == "" && len(job.Args) == 0 {
+				report(job, "command is missing")
+			}
+			if job.Cron == "" {
+				report(job, "cron is empty, set one with the \"cron\" key as it can't be generated for this job type")
+			} else if len(strings.Fields(job.Cron)) != 5 {
+				report(job, "cron %q must have 5 fields", job.Cron)
+			}
+		}
+		if job.RunIfChanged != "" {
+			if job.AlwaysRun {
+				report(job, "always_run and run_if_changed are mutually exclusive, set always_run to false")
+			}
+			if _, err := regexp.Compile(job.RunIfChanged); err != nil {
+				report(job, "run_if_changed is not a valid regular expression: %v", err)
+			}
+		}
+		for _, other := range jobs[:i] {
+			if other.Kind != job.Kind || other.Name != job.Name {
+				continue
+			}
+			switch {
+			case other.RepoName != job.RepoName:
+				report(job, "job name is already used by a %s for %s", other.Kind, other.RepoName)
+			case job.Kind == "periodic":
+				report(job, "job name is already used by another periodic")
+			case branchesOverlap(other, job):
+				report(job, "job name is already used by another %s with overlapping branches", other.Kind)
+			}
+		}
+	}
+	return problems
+}
+
+// branchesOverlap returns true if a branch can trigger both jobs.
+// Jobs with the same name in the same repository are fine as long as they run on different branches.
+func branchesOverlap(a, b Job) bool {
+	if len(a.Branches) == 0 && len(b.Branches) == 0 {
+		return true
+	}
+	if len(b.Branches) == 0 {
+		a, b = b, a
+	}
+	for _, branch := range b.Branches {
+		if len(a.Branches) > 0 && strExists(a.Branches, branch) {
+			return true
+		}
+		if len(a.Branches) == 0 && !strExists(a.SkipBranches, branch) {
+			return true
+		}
+	}
+	return false
+}
+
+// lintReport returns a readable report of the given problems.
+func lintReport(problems []string) string {
+	return fmt.Sprintf("Found %d problem(s) in the generated Prow jobs:\n  %s", len(problems), strings.Join(problems, "\n  "))
+}
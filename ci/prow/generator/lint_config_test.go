@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// lint_config_test.go contains unit tests for the validation of the generated jobs
+
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newLintedJob(kind, repoName, jobName string) Job {
+	job := Job{Kind: kind, RepoName: repoName, Name: jobName, AlwaysRun: true, Timeout: 50}
+	job.Command = "./test/presubmit-tests.sh"
+	if kind == "periodic" {
+		job.Cron = "0 * * * *"
+	}
+	return job
+}
+
+func TestLintJobs(t *testing.T) {
+	valid := newLintedJob("presubmit", "knative/serving", "pull-knative-serving-unit-tests")
+	legacy := valid
+	legacy.Branches = []string{"release-0.4"}
+	current := valid
+	current.SkipBranches = []string{"release-0.4"}
+	runIfChanged := newLintedJob("presubmit", "knative/serving", "pull-knative-serving-perf-tests")
+	runIfChanged.RunIfChanged = "^test/performance/"
+	runIfChanged.AlwaysRun = false
+	if problems := lintJobs([]Job{legacy, current, runIfChanged}); len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+
+	longName := newLintedJob("presubmit", "knative/serving", "pull-knative-serving-"+strings.Repeat("a", 50))
+	duplicate := newLintedJob("presubmit", "knative/build", "pull-knative-serving-unit-tests")
+	noCron := newLintedJob("periodic", "knative/serving", "ci-knative-serving-foo")
+	noCron.Cron = ""
+	badCron := newLintedJob("periodic", "knative/serving", "ci-knative-serving-bar")
+	badCron.Cron = "0 * * *"
+	conflict := newLintedJob("presubmit", "knative/serving", "pull-knative-serving-baz")
+	conflict.RunIfChanged = "("
+	problems := lintJobs([]Job{valid, valid, longName, duplicate, noCron, badCron, conflict})
+	expected := []string{
+		"pull-knative-serving-unit-tests (presubmit for knative/serving): job name is already used by another presubmit with overlapping branches",
+		"pull-knative-serving-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa (presubmit for knative/serving): job name is 71 characters long, more than the 63 characters allowed for Kubernetes labels",
+		"pull-knative-serving-unit-tests (presubmit for knative/build): job name is already used by a presubmit for knative/serving",
+		`ci-knative-serving-foo (periodic for knative/serving): cron is empty, set one with the "cron" key as it can't be generated for this job type`,
+		`ci-knative-serving-bar (periodic for knative/serving): cron "0 * * *" must have 5 fields`,
+		"pull-knative-serving-baz (presubmit for knative/serving): always_run and run_if_changed are mutually exclusive, set always_run to false",
+		"pull-knative-serving-baz (presubmit for knative/serving): run_if_changed is not a valid regular expression: error parsing regexp: missing closing ): `(`",
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Expected problems:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(problems, "\n"))
+	}
+}
+
+var branchesOverlapTests = []struct {
+	branches1, skip1 []string
+	branches2, skip2 []string
+	overlap          bool
+}{
+	{nil, nil, nil, nil, true},
+	{nil, []string{"release-0.4"}, nil, nil, true},
+	{[]string{"release-0.4"}, nil, nil, []string{"release-0.4"}, false},
+	{nil, []string{"release-0.4"}, []string{"release-0.4", "release-0.5"}, nil, true},
+	{[]string{"release-0.4"}, nil, []string{"release-0.5"}, nil, false},
+	{[]string{"release-0.4"}, nil, []string{"release-0.4"}, nil, true},
+}
+
+func TestBranchesOverlap(t *testing.T) {
+	for _, test := range branchesOverlapTests {
+		a := Job{Branches: test.branches1, SkipBranches: test.skip1}
+		b := Job{Branches: test.branches2, SkipBranches: test.skip2}
+		if overlap := branchesOverlap(a, b); overlap != test.overlap {
+			t.Errorf("Expected overlap %v for %+v, got %v", test.overlap, test, overlap)
+		}
+	}
+}
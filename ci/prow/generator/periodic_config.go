@@ -0,0 +1,316 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// data definitions that are used for the config file generation of periodic prow jobs
+
+package generator
+
+import (
+	"fmt"
+)
+
+const (
+	// Template for periodic test/release jobs.
+	periodicTestJob = "prow_periodic_test_job.yaml"
+
+	// Template for periodic custom jobs.
+	periodicCustomJob = "prow_periodic_custom_job.yaml"
+
+	// Cron strings for key jobs
+	cleanupPeriodicJobCron           = "0 19 * * 1"   // Run at 11:00PST/12:00PST every Monday (19:00 UTC)
+	flakesReporterPeriodicJobCron    = "0 12 * * *"   // Run at 4:00PST/5:00PST every day (12:00 UTC)
+	prowversionbumperPeriodicJobCron = "0 20 * * 1"   // Run at 12:00PST/13:00PST every Monday (20:00 UTC)
+	backupPeriodicJobCron            = "15 9 * * *"   // Run at 02:15PST every day (09:15 UTC)
+	perfPeriodicJobCron              = "0 */3 * * *"  // Run every 3 hours
+	clearAlertsPeriodicJobCron       = "0,30 * * * *" // Run every 30 minutes
+
+	// Perf job constants
+	perfTimeout = 120  // Job timeout in minutes
+	perfNodes   = "16" // Number of nodes needed to run perf tests. Needs to be string
+)
+
+// periodicJobTemplateData contains data about a periodic Prow job.
+type periodicJobTemplateData struct {
+	Base            baseProwJobTemplateData
+	PeriodicJobName string
+	CronString      string
+	PeriodicCommand []string
+}
+
+// generatePeriodic generates all periodic job configs for the given repo and configuration.
+func (g *generator) generatePeriodic(title string, repoName string, periodicConfig periodicJobConfig) {
+	var data periodicJobTemplateData
+	data.Base = g.newbaseProwJobTemplateData(repoName)
+	jobNameSuffix := ""
+	jobTemplate := g.readTemplate(periodicTestJob)
+	jobType := ""
+	isMonitoredJob := false
+
+	if !periodicConfig.enabled() {
+		return
+	}
+	switch periodicConfig.Type {
+	case "continuous":
+		jobType = periodicConfig.Type
+		jobNameSuffix = "continuous"
+		isMonitoredJob = true
+		// Use default command and arguments if none given.
+		if data.Base.Command == "" {
+			data.Base.Command = g.options.PresubmitScript
+			setSource(&data.Base, "command", flagSource("presubmit-script"))
+		}
+		if len(data.Base.Args) == 0 {
+			data.Base.Args = allPresubmitTests
+		}
+	case "nightly":
+		jobType = periodicConfig.Type
+		jobNameSuffix = "nightly-release"
+		data.Base.ServiceAccount = data.Base.Settings.NightlyAccount
+		setSource(&data.Base, "service_account", g.settingSource(data.Base.OrgName, "NightlyAccount"))
+		data.Base.Command = g.options.ReleaseScript
+		setSource(&data.Base, "command", flagSource("release-script"))
+		data.Base.Args = releaseNightly
+		data.Base.Timeout = 90
+		isMonitoredJob = true
+	case "branch-ci":
+		jobType = periodicConfig.Type
+		jobNameSuffix = "continuous"
+		data.Base.Command = g.options.ReleaseScript
+		setSource(&data.Base, "command", flagSource("release-script"))
+		data.Base.Args = releaseLocal
+		setupDockerInDockerForJob(&data.Base)
+		// TODO(adrcunha): Consider reducing the timeout in the future.
+		data.Base.Timeout = 180
+		isMonitoredJob = true
+	case "dot-release", "auto-release":
+		jobType = periodicConfig.Type
+		jobNameSuffix = periodicConfig.Type
+		data.Base.ServiceAccount = data.Base.Settings.ReleaseAccount
+		setSource(&data.Base, "service_account", g.settingSource(data.Base.OrgName, "ReleaseAccount"))
+		data.Base.Command = g.options.ReleaseScript
+		setSource(&data.Base, "command", flagSource("release-script"))
+		secret := data.Base.Settings.GitHubTokenSecret
+		data.Base.Args = []string{
+			"--" + jobNameSuffix,
+			"--release-gcs " + data.Base.ReleaseGcs,
+			"--release-gcr " + data.Base.Settings.ReleaseGcr,
+			"--github-token /etc/" + secret + "/token"}
+		addVolumeToJob(&data.Base, "/etc/"+secret, secret, true, "")
+		setSource(&data.Base, "volume."+secret, g.settingSource(data.Base.OrgName, "GitHubTokenSecret"))
+		data.Base.Timeout = 90
+		isMonitoredJob = true
+	case "performance", "performance-mesh":
+		jobType = periodicConfig.Type
+		jobNameSuffix = periodicConfig.Type
+		data.Base.Command = g.options.PerformanceScript
+		setSource(&data.Base, "command", flagSource("performance-script"))
+		data.CronString = perfPeriodicJobCron
+		// We need a larger cluster of at least 16 nodes for perf tests
+		addEnvToJob(&data.Base, "E2E_MIN_CLUSTER_NODES", perfNodes)
+		addEnvToJob(&data.Base, "E2E_MAX_CLUSTER_NODES", perfNodes)
+		data.Base.Timeout = perfTimeout
+		isMonitoredJob = true
+	case "latency":
+		jobType = periodicConfig.Type
+		jobTemplate = g.readTemplate(periodicCustomJob)
+		jobNameSuffix = "latency"
+		data.Base.Image = data.Base.Settings.MetricsDockerImage
+		setSource(&data.Base, "image", g.settingSource(data.Base.OrgName, "MetricsDockerImage"))
+		data.Base.Command = "/metrics"
+		data.Base.Args = []string{
+			fmt.Sprintf("--source-directory=ci-%s-continuous", data.Base.RepoNameForJob),
+			"--artifacts-dir=$(ARTIFACTS)",
+			"--service-account=" + data.Base.ServiceAccount}
+		isMonitoredJob = true
+	case "custom-job":
+		jobType = periodicConfig.Type
+		jobNameSuffix = periodicConfig.CustomJob
+		data.Base.Timeout = 100
+	case "webhook-apicoverage":
+		jobType = periodicConfig.Type
+		jobNameSuffix = "webhook-apicoverage"
+		data.Base.Command = g.options.WebhookAPICoverageScript
+		setSource(&data.Base, "command", flagSource("webhookAPICoverageScript"))
+		addEnvToJob(&data.Base, "SYSTEM_NAMESPACE", data.Base.RepoNameForJob)
+	}
+	if periodicConfig.Cron != "" {
+		data.CronString = periodicConfig.Cron
+		setSource(&data.Base, "cron", g.describeEntry(periodicConfig.jobConfig, ""))
+	}
+	if periodicConfig.Release != "" {
+		jobNameSuffix = periodicConfig.Release + "-" + jobNameSuffix
+		data.Base.RepoBranch = "release-" + periodicConfig.Release
+		isMonitoredJob = true
+	}
+	g.parseBasicJobConfigOverrides(&data.Base, periodicConfig.jobConfig)
+	data.PeriodicJobName = fmt.Sprintf("ci-%s", data.Base.RepoNameForJob)
+	if jobNameSuffix != "" {
+		data.PeriodicJobName += "-" + jobNameSuffix
+	}
+	if isMonitoredJob {
+		addMonitoringPubsubLabelsToJob(&data.Base, data.PeriodicJobName)
+	}
+	if data.CronString == "" {
+		data.CronString = g.generateCron(jobType, data.PeriodicJobName, data.Base.Timeout)
+		setSource(&data.Base, "cron", fmt.Sprintf("schedule of %s jobs", jobType))
+	}
+	// Ensure required data exist, the cron and command are validated with the other generated jobs.
+	if jobType == "branch-ci" && data.Base.RepoBranch == "" {
+		fatalf("%q jobs are intended to be used on release branches", jobType)
+	}
+	// Generate config itself.
+	data.PeriodicCommand = g.createCommand(data.Base)
+	if data.Base.ServiceAccount != "" {
+		addEnvToJob(&data.Base, "GOOGLE_APPLICATION_CREDENTIALS", data.Base.ServiceAccount)
+		setSource(&data.Base, "env.GOOGLE_APPLICATION_CREDENTIALS", sourceOf(data.Base, "service_account"))
+		addEnvToJob(&data.Base, "E2E_CLUSTER_REGION", "us-central1")
+	}
+	if data.Base.RepoBranch != "" && data.Base.RepoBranch != "master" {
+		// If it's a release version, add env var PULL_BASE_REF as ref name of the base branch.
+		// TODO(Fredy-Z): this serves as a workaround, see https://github.com/knative/test-infra/issues/780.
+		addEnvToJob(&data.Base, "PULL_BASE_REF", data.Base.RepoBranch)
+	}
+	g.addExtraEnvVarsToJob(&data.Base)
+	configureServiceAccountForJob(&data.Base)
+	g.executeJobTemplate("periodic", jobTemplate, title, repoName, data.PeriodicJobName, false, data)
+}
+
+// generateCleanupPeriodicJob generates the cleanup job config.
+func (g *generator) generateCleanupPeriodicJob() {
+	var data periodicJobTemplateData
+	data.Base = g.newbaseProwJobTemplateData("knative/test-infra")
+	data.PeriodicJobName = "ci-knative-cleanup"
+	data.CronString = cleanupPeriodicJobCron
+	data.Base.DecorationConfig = append(data.Base.DecorationConfig, "timeout: 86400000000000") // 24 hours
+	data.Base.Command = g.options.CleanupScript
+	setSource(&data.Base, "command", flagSource("cleanup-script"))
+	data.Base.Args = []string{
+		"--project-resource-yaml ci/prow/boskos/resources.yaml",
+		"--days-to-keep-images 30",
+		"--hours-to-keep-clusters 24",
+		"--service-account " + data.Base.ServiceAccount,
+		"--artifacts $(ARTIFACTS)"}
+	data.Base.ExtraRefs = append(data.Base.ExtraRefs, "  base_ref: "+data.Base.RepoBranch)
+	g.addExtraEnvVarsToJob(&data.Base)
+	configureServiceAccountForJob(&data.Base)
+	g.executeJobTemplate("periodic cleanup", g.readTemplate(periodicCustomJob), "periodics", "knative/test-infra", data.PeriodicJobName, false, data)
+}
+
+// generateFlakytoolPeriodicJob generates the cleanup job config.
+func (g *generator) generateFlakytoolPeriodicJob() {
+	var data periodicJobTemplateData
+	data.Base = g.newbaseProwJobTemplateData("knative/test-infra")
+	data.Base.Image = g.options.FlakesReporterDockerImage
+	setSource(&data.Base, "image", flagSource("flaky-test-reporter-docker"))
+	data.PeriodicJobName = "ci-knative-flakes-reporter"
+	data.CronString = flakesReporterPeriodicJobCron
+	data.Base.Command = "/flaky-test-reporter"
+	data.Base.Args = []string{
+		"--service-account=" + data.Base.ServiceAccount,
+		"--github-account=/etc/flaky-test-reporter-github-token/token",
+		"--slack-account=/etc/flaky-test-reporter-slack-token/token"}
+	data.Base.ExtraRefs = append(data.Base.ExtraRefs, "  base_ref: "+data.Base.RepoBranch)
+	g.addExtraEnvVarsToJob(&data.Base)
+	configureServiceAccountForJob(&data.Base)
+	addVolumeToJob(&data.Base, "/etc/flaky-test-reporter-github-token", "flaky-test-reporter-github-token", true, "")
+	addVolumeToJob(&data.Base, "/etc/flaky-test-reporter-slack-token", "flaky-test-reporter-slack-token", true, "")
+	g.executeJobTemplate("periodic flakesreporter", g.readTemplate(periodicCustomJob), "periodics", "knative/test-infra", data.PeriodicJobName, false, data)
+}
+
+// generateVersionBumpertoolPeriodicJob generates the Prow version bumper job config.
+func (g *generator) generateVersionBumpertoolPeriodicJob() {
+	var data periodicJobTemplateData
+	data.Base = g.newbaseProwJobTemplateData("knative/test-infra")
+	data.Base.Image = g.options.ProwVersionBumperDockerImage
+	setSource(&data.Base, "image", flagSource("prow-auto-bumper"))
+	data.PeriodicJobName = "ci-knative-prow-auto-bumper"
+	data.CronString = prowversionbumperPeriodicJobCron
+	data.Base.Command = "/prow-auto-bumper"
+	data.Base.Args = []string{
+		"--github-account=/etc/prow-auto-bumper-github-token/token",
+		"--git-userid=knative-prow-updater-robot",
+		"--git-username='Knative Prow Updater Robot'",
+		"--git-email=knative-prow-updater-robot@google.com"}
+	data.Base.ExtraRefs = append(data.Base.ExtraRefs, "  base_ref: "+data.Base.RepoBranch)
+	g.addExtraEnvVarsToJob(&data.Base)
+	configureServiceAccountForJob(&data.Base)
+	addVolumeToJob(&data.Base, "/etc/prow-auto-bumper-github-token", "prow-auto-bumper-github-token", true, "")
+	addVolumeToJob(&data.Base, "/root/.ssh", "prow-updater-robot-ssh-key", true, "0400")
+	g.executeJobTemplate("periodic versionbumper", g.readTemplate(periodicCustomJob), "periodics", "knative/test-infra", data.PeriodicJobName, false, data)
+}
+
+// generateBackupPeriodicJob generates the backup job config.
+func (g *generator) generateBackupPeriodicJob() {
+	var data periodicJobTemplateData
+	data.Base = g.newbaseProwJobTemplateData("none/unused")
+	data.Base.ServiceAccount = "/etc/backup-account/service-account.json"
+	data.Base.Image = "gcr.io/knative-tests/test-infra/backups:latest"
+	setSource(&data.Base, "service_account", defaultSource)
+	setSource(&data.Base, "image", defaultSource)
+	data.PeriodicJobName = "ci-knative-backup-artifacts"
+	data.CronString = backupPeriodicJobCron
+	data.Base.Command = "/backup.sh"
+	data.Base.Args = []string{data.Base.ServiceAccount}
+	data.Base.ExtraRefs = []string{} // no repo clone required
+	g.addExtraEnvVarsToJob(&data.Base)
+	configureServiceAccountForJob(&data.Base)
+	g.executeJobTemplate("periodic backup", g.readTemplate(periodicCustomJob), "periodics", "knative/test-infra", data.PeriodicJobName, false, data)
+}
+
+// generateGoCoveragePeriodic generates the go coverage periodic job config for the given repo.
+func (g *generator) generateGoCoveragePeriodic(title string, repoName string) {
+	for i, repo := range g.repositories {
+		if repoName != repo.Name || !repo.EnableGoCoverage {
+			continue
+		}
+		g.repositories[i].Processed = true
+		var data periodicJobTemplateData
+		data.Base = g.newbaseProwJobTemplateData(repoName)
+		data.Base.Image = data.Base.Settings.CoverageDockerImage
+		setSource(&data.Base, "image", g.settingSource(data.Base.OrgName, "CoverageDockerImage"))
+		data.PeriodicJobName = fmt.Sprintf("ci-%s-go-coverage", data.Base.RepoNameForJob)
+		data.Base.GoCoverageThreshold = repo.GoCoverageThreshold
+		data.Base.Command = "/coverage"
+		data.Base.Args = []string{
+			"--artifacts=$(ARTIFACTS)",
+			fmt.Sprintf("--cov-threshold-percentage=%d", data.Base.GoCoverageThreshold)}
+		data.Base.ServiceAccount = ""
+		data.CronString = g.generateCron("go-coverage", data.PeriodicJobName, data.Base.Timeout)
+		data.Base.ExtraRefs = append(data.Base.ExtraRefs, "  base_ref: "+data.Base.RepoBranch)
+		if g.repositories[i].DotDev {
+			data.Base.ExtraRefs = append(data.Base.ExtraRefs, "  "+pathAlias(&data.Base))
+		}
+		g.addExtraEnvVarsToJob(&data.Base)
+		addMonitoringPubsubLabelsToJob(&data.Base, data.PeriodicJobName)
+		configureServiceAccountForJob(&data.Base)
+		g.executeJobTemplate("periodic go coverage", g.readTemplate(periodicCustomJob), title, repoName, data.PeriodicJobName, false, data)
+		return
+	}
+}
+
+// generateClearAlertsPeriodicJob generates the monitoring clear alerts job config.
+func (g *generator) generateClearAlertsPeriodicJob() {
+	var data periodicJobTemplateData
+	data.Base = g.newbaseProwJobTemplateData("knative/test-infra")
+	data.Base.Image = g.options.ClearAlertsDockerImage
+	setSource(&data.Base, "image", flagSource("clear-alerts"))
+	data.PeriodicJobName = "ci-knative-test-infra-monitoring-clear-alerts"
+	data.CronString = clearAlertsPeriodicJobCron
+	data.Base.Command = "/clearalerts"
+	data.Base.ExtraRefs = append(data.Base.ExtraRefs, "  base_ref: "+data.Base.RepoBranch)
+	addVolumeToJob(&data.Base, "/secrets/cloudsql/monitoringdb", "monitoring-db-credentials", true, "")
+	g.executeJobTemplate("periodic clearalert", g.readTemplate(periodicCustomJob), "periodics", "knative/test-infra", data.PeriodicJobName, false, data)
+}
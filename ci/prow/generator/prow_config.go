@@ -0,0 +1,1051 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// generation of the Prow jobs and the general Prow config
+
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// Manifests generated by ko are indented by 2 spaces.
+	baseIndent = "  "
+
+	// ##########################################################
+	// ############## prow configuration templates ##############
+	// ##########################################################
+	// generalProwConfig contains config-wide definitions.
+	generalProwConfig = "prow_config_header.yaml"
+
+	// presubmitJob is the template for presubmit jobs.
+	presubmitJob = "prow_presubmit_job.yaml"
+
+	// presubmitGoCoverageJob is the template for go coverage presubmit jobs.
+	presubmitGoCoverageJob = "prow_presubmit_gocoverate_job.yaml"
+
+	// goCoveragePostsubmitJob is the template for the go postsubmit coverage job.
+	goCoveragePostsubmitJob = "prow_postsubmit_gocoverage_job.yaml"
+)
+
+// repositoryData contains basic data about each Knative repository.
+type repositoryData struct {
+	Name                string
+	EnableGoCoverage    bool
+	GoCoverageThreshold int
+	Processed           bool
+	DotDev              bool
+	LegacyBranches      []string
+	Pod                 podConfig
+	// Settings are the repo-settings entries of the repository, to find the source of its pod settings.
+	Settings []jobConfig
+	// DotDevSource and LegacyBranchesSource are the entries setting DotDev and LegacyBranches.
+	DotDevSource         string
+	LegacyBranchesSource string
+}
+
+// baseProwJobTemplateData contains basic data about a Prow job.
+type baseProwJobTemplateData struct {
+	OrgName             string
+	RepoName            string
+	RepoNameForJob      string
+	GcsBucket           string
+	GcsLogDir           string
+	GcsPresubmitLogDir  string
+	RepoURI             string
+	RepoBranch          string
+	CloneURI            string
+	SecurityContext     []string
+	SkipBranches        []string
+	Branches            []string
+	DecorationConfig    []string
+	ExtraRefs           []string
+	Command             string
+	Args                []string
+	Env                 []string
+	Volumes             []string
+	VolumeMounts        []string
+	Resources           []string
+	NodeSelector        []string
+	Tolerations         []string
+	Timeout             int
+	AlwaysRun           bool
+	Optional            bool
+	RunIfChanged        string
+	LogsDir             string
+	PresubmitLogsDir    string
+	TestAccount         string
+	ServiceAccount      string
+	ReleaseGcs          string
+	GoCoverageThreshold int
+	Image               string
+	Year                int
+	Labels              []string
+	PathAlias           string
+	Settings            orgSettings
+	// Sources contains where the values of the job fields come from, keyed by field, for explaining the job.
+	Sources map[string]string
+}
+
+// ####################################################################################################
+// ################ data definitions that are used for the prow config file generation ################
+// ####################################################################################################
+// presubmitJobTemplateData contains data about a presubmit Prow job.
+type presubmitJobTemplateData struct {
+	Base                 baseProwJobTemplateData
+	PresubmitJobName     string
+	PresubmitPullJobName string
+	PresubmitPostJobName string
+	PresubmitCommand     []string
+}
+
+// postsubmitJobTemplateData contains data about a postsubmit Prow job.
+type postsubmitJobTemplateData struct {
+	Base              baseProwJobTemplateData
+	PostsubmitJobName string
+}
+
+// repoJobGenerator is a function that generates Prow job configs for the given section title and repository.
+type repoJobGenerator func(string, string)
+
+// newJobNeeded is a function that determined if we need to add a new job for this repository.
+type newJobNeeded func(repositoryData) bool
+
+var (
+	// #########################################################################
+	// ############## data used for generating prow configuration ##############
+	// #########################################################################
+	// Array constants used throughout the jobs.
+	allPresubmitTests = []string{"--all-tests", "--emit-metrics"}
+	releaseNightly    = []string{"--publish", "--tag-release"}
+	releaseLocal      = []string{"--nopublish", "--notag-release"}
+)
+
+// read template yaml file content
+func (g *generator) readTemplate(fp string) string {
+	if _, ok := g.templates[fp]; !ok {
+		content, err := ioutil.ReadFile(path.Join(g.options.TemplateDir, fp))
+		if nil != err {
+			fatalf("failed to read file '%s': '%v'", fp, err)
+		}
+		g.templates[fp] = string(content)
+	}
+	return g.templates[fp]
+}
+
+func combineSlices(a1 []string, a2 []string) []string {
+	var res []string
+	res = append(res, a1...)
+	for _, e2 := range a2 {
+		add := true
+		for _, e1 := range a1 {
+			if e1 == e2 {
+				add = false
+			}
+		}
+		if add {
+			res = append(res, e2)
+		}
+	}
+	return res
+}
+
+// Consolidate whitelisted and skipped branches with newly added whitelisted/skipped
+func consolidateBranches(whitelisted []string, skipped []string, newWhitelisted []string, newSkipped []string) ([]string, []string) {
+	// Merge the whitelisted and newWhitelisted arrays, ignoring any element present in skipped or newSkipped.
+	var combinedWhitelisted []string
+	var combinedSkipped []string
+	combinedWhitelisted = combineSlices(whitelisted, newWhitelisted)
+	combinedSkipped = combineSlices(skipped, newSkipped)
+	if len(combinedWhitelisted) > 0 {
+		var tmp []string
+		for _, elem := range combinedWhitelisted {
+			add := true
+			for _, skip := range combinedSkipped {
+				if elem == skip {
+					add = false
+				}
+			}
+			if add {
+				tmp = append(tmp, elem)
+			}
+		}
+		combinedWhitelisted = tmp
+		combinedSkipped = make([]string, 0)
+	}
+	return combinedWhitelisted, combinedSkipped
+}
+
+// Config generation functions.
+
+// getOrgSettings returns the settings for the jobs of the given GitHub organization.
+// Settings not given in the input config fall back to the values set through the command-line flags.
+func (g *generator) getOrgSettings(orgName string) orgSettings {
+	settings := orgSettings{
+		GcsBucket:            g.options.GcsBucket,
+		LogsDir:              g.options.LogsDir,
+		PresubmitLogsDir:     g.options.PresubmitLogsDir,
+		ProwTestsDockerImage: g.options.ProwTestsDockerImage,
+		CoverageDockerImage:  g.options.CoverageDockerImage,
+		MetricsDockerImage:   g.options.MetricsDockerImage,
+		TestAccount:          g.options.TestAccount,
+		NightlyAccount:       g.options.NightlyAccount,
+		ReleaseAccount:       g.options.ReleaseAccount,
+		ReleaseGcs:           g.options.ReleaseGcs,
+		ReleaseGcr:           g.options.ReleaseGcr,
+		GitHubTokenSecret:    g.options.GitHubTokenSecret,
+		CoverageTokenSecret:  g.options.CoverageTokenSecret,
+		DotDevDomain:         orgName + ".dev",
+	}
+	// All settings are strings, override the ones set for the organization.
+	overrides := reflect.ValueOf(g.config.Settings[orgName])
+	values := reflect.ValueOf(&settings).Elem()
+	for i := 0; i < overrides.NumField(); i++ {
+		if v := overrides.Field(i).String(); v != "" {
+			values.Field(i).SetString(v)
+		}
+	}
+	return settings
+}
+
+// pathAlias returns the path alias of the repository of the job for its vanity import URL, under the dot-dev domain of its org.
+func pathAlias(data *baseProwJobTemplateData) string {
+	return fmt.Sprintf("path_alias: %s/%s", data.Settings.DotDevDomain, data.RepoName)
+}
+
+// newbaseProwJobTemplateData returns a baseProwJobTemplateData type with its initial, default values.
+func (g *generator) newbaseProwJobTemplateData(repo string) baseProwJobTemplateData {
+	var data baseProwJobTemplateData
+	data.Timeout = 50
+	data.OrgName = strings.Split(repo, "/")[0]
+	data.RepoName = strings.Replace(repo, data.OrgName+"/", "", 1)
+	data.RepoNameForJob = strings.ToLower(strings.Replace(repo, "/", "-", -1))
+	data.RepoBranch = "master" // Default to be master, will override later for other branches
+	data.Settings = g.getOrgSettings(data.OrgName)
+	data.Sources = make(map[string]string)
+	data.GcsBucket = data.Settings.GcsBucket
+	data.RepoURI = "github.com/" + repo
+	data.CloneURI = fmt.Sprintf("\"https://%s.git\"", data.RepoURI)
+	data.GcsLogDir = fmt.Sprintf("gs://%s/%s", data.GcsBucket, data.Settings.LogsDir)
+	data.GcsPresubmitLogDir = fmt.Sprintf("gs://%s/%s", data.GcsBucket, data.Settings.PresubmitLogsDir)
+	data.Year = time.Now().Year()
+	data.PresubmitLogsDir = data.Settings.PresubmitLogsDir
+	data.LogsDir = data.Settings.LogsDir
+	data.ReleaseGcs = data.Settings.ReleaseGcs + "/" + data.RepoName
+	data.AlwaysRun = true
+	data.Image = data.Settings.ProwTestsDockerImage
+	setSource(&data, "image", g.settingSource(data.OrgName, "ProwTestsDockerImage"))
+	data.ServiceAccount = data.Settings.TestAccount
+	setSource(&data, "service_account", g.settingSource(data.OrgName, "TestAccount"))
+	data.Command = ""
+	data.Args = make([]string, 0)
+	data.Volumes = make([]string, 0)
+	data.VolumeMounts = make([]string, 0)
+	data.Env = make([]string, 0)
+	data.ExtraRefs = []string{"- org: " + data.OrgName, "  repo: " + data.RepoName}
+	data.DecorationConfig = make([]string, 0)
+	if data.GcsBucket != g.options.GcsBucket {
+		// The default decoration config uploads the logs to the default bucket, use the org one instead.
+		data.DecorationConfig = []string{"gcs_configuration:", "  bucket: " + data.GcsBucket, "  path_strategy: \"explicit\""}
+	}
+	data.Labels = make([]string, 0)
+	return data
+}
+
+// General helpers.
+
+// createCommand returns an array with the command to run and its arguments.
+func (g *generator) createCommand(data baseProwJobTemplateData) []string {
+	c := []string{data.Command}
+	// Prefix the pre-command if present.
+	if g.options.PreCommand != "" {
+		c = append([]string{g.options.PreCommand}, c...)
+	}
+	return append(c, data.Args...)
+}
+
+// addEnvToJob adds the given key/pair environment variable to the job.
+func addEnvToJob(data *baseProwJobTemplateData, key, value string) {
+	// Value should always be string. Add quotes if we get a number
+	if isNum(value) {
+		value = "\"" + value + "\""
+	}
+
+	(*data).Env = append((*data).Env, []string{"- name: " + key, "  value: " + value}...)
+}
+
+// addLabelToJob adds extra labels to a job
+func addLabelToJob(data *baseProwJobTemplateData, key, value string) {
+	(*data).Labels = append((*data).Labels, []string{key + ": " + value}...)
+}
+
+// addPubsubLabelsToJob adds the pubsub labels so the prow job message will be picked up by test-infra monitoring
+func addMonitoringPubsubLabelsToJob(data *baseProwJobTemplateData, runID string) {
+	addLabelToJob(data, "prow.k8s.io/pubsub.project", "knative-tests")
+	addLabelToJob(data, "prow.k8s.io/pubsub.topic", "knative-monitoring")
+	addLabelToJob(data, "prow.k8s.io/pubsub.runID", runID)
+}
+
+// addVolumeToJob adds the given mount path as volume for the job.
+func addVolumeToJob(data *baseProwJobTemplateData, mountPath, name string, isSecret bool, defaultMode string) {
+	(*data).VolumeMounts = append((*data).VolumeMounts, []string{"- name: " + name, "  mountPath: " + mountPath}...)
+	if isSecret {
+		(*data).VolumeMounts = append((*data).VolumeMounts, "  readOnly: true")
+	}
+	s := []string{"- name: " + name}
+	if isSecret {
+		arr := []string{"  secret:", "    secretName: " + name}
+		if len(defaultMode) > 0 {
+			arr = append(arr, "    defaultMode: "+defaultMode)
+		}
+		s = append(s, arr...)
+	} else {
+		s = append(s, "  emptyDir: {}")
+	}
+	(*data).Volumes = append((*data).Volumes, s...)
+}
+
+// setPodConfigForJob sets the resources, node selector and tolerations of the pod running the job.
+func setPodConfigForJob(data *baseProwJobTemplateData, pod podConfig) {
+	data.Resources = nil
+	for _, r := range []struct {
+		name string
+		list resourceList
+	}{{"requests", pod.Resources.Requests}, {"limits", pod.Resources.Limits}} {
+		var lines []string
+		if r.list.CPU != "" {
+			lines = append(lines, "  cpu: "+quote(r.list.CPU))
+		}
+		if r.list.Memory != "" {
+			lines = append(lines, "  memory: "+quote(r.list.Memory))
+		}
+		if len(lines) > 0 {
+			data.Resources = append(append(data.Resources, r.name+":"), lines...)
+		}
+	}
+	keys := make([]string, 0, len(pod.NodeSelector))
+	for key := range pod.NodeSelector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	data.NodeSelector = nil
+	for _, key := range keys {
+		data.NodeSelector = append(data.NodeSelector, key+": "+quote(pod.NodeSelector[key]))
+	}
+	data.Tolerations = nil
+	for _, t := range pod.Tolerations {
+		var lines []string
+		for _, field := range [][2]string{{"key", t.Key}, {"operator", t.Operator}, {"value", t.Value}, {"effect", t.Effect}} {
+			if field[1] != "" {
+				lines = append(lines, field[0]+": "+quote(field[1]))
+			}
+		}
+		data.Tolerations = append(data.Tolerations, "- "+lines[0])
+		for _, line := range lines[1:] {
+			data.Tolerations = append(data.Tolerations, "  "+line)
+		}
+	}
+}
+
+// configureServiceAccountForJob adds the necessary volumes for the service account for the job.
+func configureServiceAccountForJob(data *baseProwJobTemplateData) {
+	if data.ServiceAccount == "" {
+		return
+	}
+	p := strings.Split(data.ServiceAccount, "/")
+	if len(p) != 4 || p[0] != "" || p[1] != "etc" || p[3] != "service-account.json" {
+		fatalf("service account path %q is expected to be \"/etc/<name>/service-account.json\"", data.ServiceAccount)
+	}
+	name := p[2]
+	addVolumeToJob(data, "/etc/"+name, name, true, "")
+	setSource(data, "volume."+name, sourceOf(*data, "service_account"))
+}
+
+// addExtraEnvVarsToJob adds any extra environment variables (defined on command-line) to a job.
+func (g *generator) addExtraEnvVarsToJob(data *baseProwJobTemplateData) {
+	for _, env := range g.options.ExtraEnvVars {
+		pair := strings.Split(env, "=")
+		if len(pair) != 2 {
+			fatalf("environment variable %q is expected to be \"key=value\"", env)
+		}
+		addEnvToJob(data, pair[0], pair[1])
+		setSource(data, "env."+pair[0], flagSource("extra-env"))
+	}
+}
+
+// setupDockerInDockerForJob enables docker-in-docker for the given job.
+func setupDockerInDockerForJob(data *baseProwJobTemplateData) {
+	addVolumeToJob(data, "/docker-graph", "docker-graph", false, "")
+	addEnvToJob(data, "DOCKER_IN_DOCKER_ENABLED", "\"true\"")
+	(*data).SecurityContext = []string{"privileged: true"}
+}
+
+// Config parsers.
+
+// parseBasicJobConfigOverrides updates the given baseProwJobTemplateData with any base option present in the given config.
+func (g *generator) parseBasicJobConfigOverrides(data *baseProwJobTemplateData, config jobConfig) {
+	entry := config
+	source := func(isSet func(jobConfig) bool) string {
+		return g.entrySource(entry, isSet)
+	}
+	config = expandJobConfig(config, g.config.Templates)
+	(*data).ExtraRefs = append((*data).ExtraRefs, "  base_ref: "+(*data).RepoBranch)
+	if config.SkipBranches != nil {
+		(*data).SkipBranches = config.SkipBranches
+		setSource(data, "skip_branches", source(func(c jobConfig) bool { return c.SkipBranches != nil }))
+	}
+	if config.Branches != nil {
+		(*data).Branches = config.Branches
+		setSource(data, "branches", source(func(c jobConfig) bool { return c.Branches != nil }))
+	}
+	if config.Args != nil {
+		(*data).Args = config.Args
+		setSource(data, "args", g.argsSource(entry))
+	}
+	if config.Timeout > 0 {
+		(*data).Timeout = config.Timeout
+		setSource(data, "timeout", source(func(c jobConfig) bool { return c.Timeout > 0 }))
+	}
+	if config.Command != "" {
+		(*data).Command = string(config.Command)
+		setSource(data, "command", source(func(c jobConfig) bool { return c.Command != "" || c.FullCommand != "" }))
+	}
+	if config.FullCommand != "" {
+		parts := strings.Split(config.FullCommand, " ")
+		(*data).Command = parts[0]
+		(*data).Args = parts[1:]
+		fullCommandSource := source(func(c jobConfig) bool { return c.FullCommand != "" })
+		setSource(data, "command", fullCommandSource)
+		setSource(data, "args", fullCommandSource)
+	}
+	if config.NeedsDind {
+		setupDockerInDockerForJob(data)
+		dindSource := source(func(c jobConfig) bool { return c.NeedsDind })
+		for _, field := range []string{"env.DOCKER_IN_DOCKER_ENABLED", "volume.docker-graph", "security_context"} {
+			setSource(data, field, dindSource)
+		}
+	}
+	for _, env := range config.Env {
+		pair := strings.SplitN(env, "=", 2)
+		addEnvToJob(data, pair[0], pair[1])
+		setSource(data, "env."+pair[0], source(func(c jobConfig) bool {
+			for _, e := range c.Env {
+				if strings.SplitN(e, "=", 2)[0] == pair[0] {
+					return true
+				}
+			}
+			return false
+		}))
+	}
+	for _, v := range config.Volumes {
+		addVolumeToJob(data, v.MountPath, v.Name, v.Secret, v.DefaultMode)
+		name := v.Name
+		setSource(data, "volume."+name, source(func(c jobConfig) bool {
+			for _, v := range c.Volumes {
+				if v.Name == name {
+					return true
+				}
+			}
+			return false
+		}))
+	}
+	if config.AlwaysRun != nil {
+		(*data).AlwaysRun = *config.AlwaysRun
+		setSource(data, "always_run", source(func(c jobConfig) bool { return c.AlwaysRun != nil }))
+	}
+	repoName := (*data).OrgName + "/" + (*data).RepoName
+	for i, repo := range g.repositories {
+		if repo.Name != repoName {
+			continue
+		}
+		if config.DotDev {
+			g.repositories[i].DotDev = true
+			g.repositories[i].DotDevSource = source(func(c jobConfig) bool { return c.DotDev })
+		}
+		if config.LegacyBranches != nil {
+			g.repositories[i].LegacyBranches = config.LegacyBranches
+			g.repositories[i].LegacyBranchesSource = source(func(c jobConfig) bool { return c.LegacyBranches != nil })
+		}
+		if config.Type == "repo-settings" {
+			g.repositories[i].Pod = mergePodConfig(g.repositories[i].Pod, config.podConfig)
+			g.repositories[i].Settings = append(g.repositories[i].Settings, entry)
+		}
+	}
+	// Add repo path alias to job for vanity import URLs if dot-dev setting is true (and this is not a legacy branch)
+	for _, repo := range g.repositories {
+		if repo.Name == repoName && repo.DotDev {
+			needPathAlias := true
+			for _, branchName := range repo.LegacyBranches {
+				if branchName == (*data).RepoBranch {
+					needPathAlias = false
+				}
+			}
+			if needPathAlias {
+				(*data).PathAlias = pathAlias(data)
+				(*data).ExtraRefs = append((*data).ExtraRefs, "  "+(*data).PathAlias)
+				setSource(data, "path_alias", repo.DotDevSource)
+			}
+			break
+		}
+	}
+	// The pod settings of the job override the ones of the repository, which override the ones of the job type.
+	pod := g.config.JobTypes[config.Type]
+	var podRepo *repositoryData
+	for i, repo := range g.repositories {
+		if repo.Name == repoName {
+			pod = mergePodConfig(pod, repo.Pod)
+			podRepo = &g.repositories[i]
+			break
+		}
+	}
+	setPodConfigForJob(data, mergePodConfig(pod, config.podConfig))
+	g.setPodSources(data, entry, podRepo)
+	// Override any values if provided by command-line flags.
+	if g.options.TimeoutOverride > 0 {
+		(*data).Timeout = g.options.TimeoutOverride
+		setSource(data, "timeout", flagSource("timeout-override"))
+	}
+}
+
+// generatePresubmit generates all presubmit job configs for the given repo and configuration.
+func (g *generator) generatePresubmit(title string, repoName string, presubmitConfig presubmitJobConfig) {
+	var data presubmitJobTemplateData
+	data.Base = g.newbaseProwJobTemplateData(repoName)
+	data.Base.Command = g.options.PresubmitScript
+	setSource(&data.Base, "command", flagSource("presubmit-script"))
+	data.Base.GoCoverageThreshold = presubmitConfig.GoCoverageThreshold
+	jobTemplate := g.readTemplate(presubmitJob)
+	repoData := repositoryData{Name: repoName, EnableGoCoverage: false, GoCoverageThreshold: data.Base.GoCoverageThreshold}
+	isMonitoredJob := false
+	generateJob := true
+	if !presubmitConfig.enabled() {
+		return
+	}
+	switch presubmitConfig.Type {
+	case "build-tests", "unit-tests", "integration-tests":
+		jobName := presubmitConfig.Type
+		data.PresubmitJobName = data.Base.RepoNameForJob + "-" + jobName
+		// Use default arguments if none given.
+		if len(data.Base.Args) == 0 {
+			data.Base.Args = []string{"--" + jobName}
+		}
+		if presubmitConfig.Type == "integration-tests" {
+			isMonitoredJob = true
+		}
+	case "go-coverage":
+		jobTemplate = g.readTemplate(presubmitGoCoverageJob)
+		data.PresubmitJobName = data.Base.RepoNameForJob + "-go-coverage"
+		data.Base.Image = data.Base.Settings.CoverageDockerImage
+		setSource(&data.Base, "image", g.settingSource(data.Base.OrgName, "CoverageDockerImage"))
+		data.Base.ServiceAccount = ""
+		data.Base.Optional = true
+		repoData.EnableGoCoverage = true
+		secret := data.Base.Settings.CoverageTokenSecret
+		addVolumeToJob(&data.Base, "/etc/"+secret, secret, true, "")
+		setSource(&data.Base, "volume."+secret, g.settingSource(data.Base.OrgName, "CoverageTokenSecret"))
+	case "custom-test":
+		data.PresubmitJobName = data.Base.RepoNameForJob + "-" + presubmitConfig.CustomTest
+	case "repo-settings":
+		generateJob = false
+	}
+	data.Base.RunIfChanged = presubmitConfig.RunIfChanged
+	setSource(&data.Base, "run_if_changed", g.describeEntry(presubmitConfig.jobConfig, ""))
+	g.repositories = append(g.repositories, repoData)
+	g.parseBasicJobConfigOverrides(&data.Base, presubmitConfig.jobConfig)
+	if !generateJob {
+		return
+	}
+	data.PresubmitCommand = g.createCommand(data.Base)
+	data.PresubmitPullJobName = "pull-" + data.PresubmitJobName
+	data.PresubmitPostJobName = "post-" + data.PresubmitJobName
+	if data.Base.ServiceAccount != "" {
+		addEnvToJob(&data.Base, "GOOGLE_APPLICATION_CREDENTIALS", data.Base.ServiceAccount)
+		setSource(&data.Base, "env.GOOGLE_APPLICATION_CREDENTIALS", sourceOf(data.Base, "service_account"))
+		addEnvToJob(&data.Base, "E2E_CLUSTER_REGION", "us-central1")
+	}
+	if isMonitoredJob {
+		addMonitoringPubsubLabelsToJob(&data.Base, data.PresubmitPullJobName)
+	}
+	g.addExtraEnvVarsToJob(&data.Base)
+	configureServiceAccountForJob(&data.Base)
+	jobName := data.PresubmitPullJobName
+	g.executeJobTemplateWrapper(repoName, &data, func(data interface{}) {
+		g.executeJobTemplate("presubmit", jobTemplate, title, repoName, jobName, true, data)
+	})
+	// TODO(adrcunha): remove once the coverage-dev job isn't necessary anymore.
+	// Generate config for pull-knative-serving-go-coverage-dev right after pull-knative-serving-go-coverage
+	if data.PresubmitPullJobName == "pull-knative-serving-go-coverage" {
+		data.PresubmitPullJobName += "-dev"
+		data.Base.AlwaysRun = false
+		data.Base.Image = strings.Replace(data.Base.Image, "coverage:latest", "coverage-dev:latest-dev", -1)
+		template := strings.Replace(g.readTemplate(presubmitGoCoverageJob), "(all|", "(", 1)
+		g.executeJobTemplate("presubmit", template, title, repoName, data.PresubmitPullJobName, true, data)
+	}
+}
+
+// generateGoCoveragePostsubmit generates the go coverage postsubmit job config for the given repo.
+func (g *generator) generateGoCoveragePostsubmit(title, repoName string) {
+	var data postsubmitJobTemplateData
+	data.Base = g.newbaseProwJobTemplateData(repoName)
+	data.Base.Image = data.Base.Settings.CoverageDockerImage
+	setSource(&data.Base, "image", g.settingSource(data.Base.OrgName, "CoverageDockerImage"))
+	data.PostsubmitJobName = fmt.Sprintf("post-%s-go-coverage", data.Base.RepoNameForJob)
+	for _, repo := range g.repositories {
+		if repo.Name == repoName && repo.DotDev {
+			data.Base.PathAlias = pathAlias(&data.Base)
+			setSource(&data.Base, "path_alias", repo.DotDevSource)
+		}
+	}
+	g.addExtraEnvVarsToJob(&data.Base)
+	configureServiceAccountForJob(&data.Base)
+	jobName := data.PostsubmitJobName
+	g.executeJobTemplateWrapper(repoName, &data, func(data interface{}) {
+		g.executeJobTemplate("postsubmit go coverage", g.readTemplate(goCoveragePostsubmitJob), "postsubmits", repoName, jobName, true, data)
+	})
+	// TODO(adrcunha): remove once the coverage-dev job isn't necessary anymore.
+	// Generate config for post-knative-serving-go-coverage-dev right after post-knative-serving-go-coverage
+	if data.PostsubmitJobName == "post-knative-serving-go-coverage" {
+		data.PostsubmitJobName += "-dev"
+		data.Base.Image = strings.Replace(data.Base.Image, "coverage:latest", "coverage-dev:latest-dev", -1)
+		g.executeJobTemplate("presubmit", g.readTemplate(goCoveragePostsubmitJob), "postsubmits", repoName, data.PostsubmitJobName, false, data)
+	}
+}
+
+// generateOtherJobConfigs generates job config with the generator if new job is required for it.
+func (g *generator) generateOtherJobConfigs(title string, newJobNeeded newJobNeeded, generate repoJobGenerator) {
+	for i := range g.repositories { // Keep order for predictable output.
+		if !newJobNeeded(g.repositories[i]) {
+			continue
+		}
+		generate(title, g.repositories[i].Name)
+	}
+}
+
+// generateProwJobs generates all Prow jobs from the input config.
+func (g *generator) generateProwJobs() {
+	g.repositories = make([]repositoryData, 0)
+	g.sectionMap = make(map[string]bool)
+	g.jobConfigFiles = make(map[string]*bytes.Buffer)
+	g.jobs = nil
+	for _, repo := range g.config.Presubmits {
+		for _, jobConfig := range repo.Jobs {
+			g.generatePresubmit("presubmits", repo.Name, jobConfig)
+		}
+	}
+	for _, repo := range g.config.Periodics {
+		for _, jobConfig := range repo.Jobs {
+			g.generatePeriodic("periodics", repo.Name, jobConfig)
+		}
+		g.generateGoCoveragePeriodic("periodics", repo.Name)
+	}
+	g.generateOtherJobConfigs("periodics", func(repo repositoryData) bool {
+		return !repo.Processed && repo.EnableGoCoverage
+	}, g.generateGoCoveragePeriodic)
+	g.generateCleanupPeriodicJob()
+	g.generateClearAlertsPeriodicJob()
+	g.generateFlakytoolPeriodicJob()
+	g.generateVersionBumpertoolPeriodicJob()
+	g.generateBackupPeriodicJob()
+	g.generateOtherJobConfigs("postsubmits", func(repo repositoryData) bool {
+		return repo.EnableGoCoverage
+	}, g.generateGoCoveragePostsubmit)
+}
+
+// Template helpers.
+
+// gitHubRepo returns the correct reference for the GitHub repository.
+func (g *generator) gitHubRepo(data baseProwJobTemplateData) string {
+	if g.options.RepositoryOverride != "" {
+		return g.options.RepositoryOverride
+	}
+	s := data.RepoURI
+	if data.RepoBranch != "" {
+		s += "=" + data.RepoBranch
+	}
+	return s
+}
+
+// isNum checks if the given string is a valid number
+func isNum(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// quote returns the given string quoted if it's not a number, or not a key/value pair, or already quoted.
+func quote(s string) string {
+	if isNum(s) {
+		return s
+	}
+	if strings.HasPrefix(s, "'") || strings.HasPrefix(s, "\"") || strings.Contains(s, ": ") || strings.HasSuffix(s, ":") {
+		return s
+	}
+	return "\"" + s + "\""
+}
+
+// indentBase is a helper function which returns the given array indented.
+func indentBase(indentation int, prefix string, indentFirstLine bool, array []string) string {
+	s := ""
+	if len(array) == 0 {
+		return s
+	}
+	indent := strings.Repeat(" ", indentation)
+	for i := 0; i < len(array); i++ {
+		if i > 0 || indentFirstLine {
+			s += indent
+		}
+		s += prefix + quote(array[i]) + "\n"
+	}
+	return s
+}
+
+// indentArray returns the given array indented, prefixed by "-".
+func indentArray(indentation int, array []string) string {
+	return indentBase(indentation, "- ", false, array)
+}
+
+// indentKeys returns the given array of key/value pairs indented.
+func indentKeys(indentation int, array []string) string {
+	return indentBase(indentation, "", false, array)
+}
+
+// indentSectionBase is a helper function which returns the given array of key/value pairs indented inside a section.
+func indentSectionBase(indentation int, title string, prefix string, array []string) string {
+	keys := indentBase(indentation, prefix, true, array)
+	if keys == "" {
+		return keys
+	}
+	return title + ":\n" + keys
+}
+
+// indentArraySection returns the given array indented inside a section.
+func indentArraySection(indentation int, title string, array []string) string {
+	return indentSectionBase(indentation, title, "- ", array)
+}
+
+// indentSection returns the given array of key/value pairs indented inside a section.
+func indentSection(indentation int, title string, array []string) string {
+	return indentSectionBase(indentation, title, "", array)
+}
+
+// indentMap returns the given map indented, with each key/value separated by ": "
+func indentMap(indentation int, mp map[string]string) string {
+	// Extract map keys to keep order consistent.
+	keys := make([]string, 0, len(mp))
+	for key := range mp {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	arr := make([]string, len(mp))
+	for i := 0; i < len(mp); i++ {
+		arr[i] = keys[i] + ": " + quote(mp[keys[i]])
+	}
+	return indentBase(indentation, "", false, arr)
+}
+
+// outputConfig outputs the given line, if not empty, to stdout.
+func (g *generator) outputConfig(line string) {
+	s := strings.TrimSpace(line)
+	if s != "" {
+		fmt.Fprintln(g.output, line)
+	}
+}
+
+// strExists checks if the given string exists in the array
+func strExists(arr []string, str string) bool {
+	for _, s := range arr {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
+
+// executeJobTemplateWrapper takes in consideration of repo settings, decides how many varianats of the
+// same job needs to be generated and generates them.
+func (g *generator) executeJobTemplateWrapper(repoName string, data interface{}, generateOneJob func(data interface{})) {
+	var legacyBranches []string
+	var legacyBranchesSource string
+	// Find out if LegacyBranches is set in repo settings
+	for _, repo := range g.repositories {
+		if repo.Name == repoName {
+			if len(repo.LegacyBranches) > 0 {
+				legacyBranches = repo.LegacyBranches
+				legacyBranchesSource = repo.LegacyBranchesSource
+			}
+		}
+	}
+	if len(legacyBranches) == 0 { // Generate only one job as normal if LegacyBranches is not set
+		generateOneJob(data)
+	} else {
+		// Generate one job with the vanity import path alias for branches other than legacy branches,
+		// and another job without path alias for legacy branches
+		var base *baseProwJobTemplateData
+		switch v := data.(type) {
+		case *presubmitJobTemplateData:
+			base = &data.(*presubmitJobTemplateData).Base
+		case *postsubmitJobTemplateData:
+			base = &data.(*postsubmitJobTemplateData).Base
+		default:
+			fatalf("unrecognized job template type: '%v'", v)
+		}
+		branches := base.Branches
+		skipBranches := base.SkipBranches
+		// The branches of both jobs come from the branches and skip_branches of the job, and the legacy branches.
+		var branchSources []string
+		for _, field := range []string{"branches", "skip_branches"} {
+			if source, exists := base.Sources[field]; exists && !strExists(branchSources, source) {
+				branchSources = append(branchSources, source)
+			}
+		}
+		branchSources = append(branchSources, legacyBranchesSource)
+		for _, field := range []string{"branches", "skip_branches"} {
+			setSource(base, field, strings.Join(branchSources, " + "))
+		}
+		setSource(base, "path_alias", legacyBranchesSource)
+		base.PathAlias = ""
+		base.Branches, base.SkipBranches = consolidateBranches(branches, skipBranches, legacyBranches, make([]string, 0))
+		generateOneJob(data)
+		base.Branches, base.SkipBranches = consolidateBranches(branches, skipBranches, make([]string, 0), legacyBranches)
+		base.PathAlias = pathAlias(base)
+		base.ExtraRefs = append(base.ExtraRefs, "  "+base.PathAlias)
+		generateOneJob(data)
+	}
+}
+
+// executeTemplate outputs the given job template with the given data, respecting any filtering.
+func (g *generator) executeJobTemplate(name, templ, title, repoName, jobName string, groupByRepo bool, data interface{}) {
+	g.recordJobForLinting(repoName, jobName, data)
+	if g.options.JobNameFilter != "" && g.options.JobNameFilter != jobName {
+		return
+	}
+	section := title
+	if g.options.SplitJobs {
+		// Each file has its own sections, and presubmits and postsubmits are always grouped by repository.
+		section = jobConfigFileName(title, repoName)
+		g.output = g.jobConfigOutput(section)
+		groupByRepo = title != "periodics"
+	} else {
+		g.setSection(title)
+	}
+	if !g.sectionMap[section] {
+		g.outputConfig(title + ":")
+		g.sectionMap[section] = true
+	}
+	if groupByRepo {
+		if !g.sectionMap[section+repoName] {
+			g.outputConfig(baseIndent + repoName + ":")
+			g.sectionMap[section+repoName] = true
+		}
+	}
+	g.executeTemplate(name, templ, data)
+}
+
+// executeTemplate outputs the given template with the given data.
+func (g *generator) executeTemplate(name, templ string, data interface{}) {
+	var res bytes.Buffer
+	funcMap := template.FuncMap{
+		"indent_section":       indentSection,
+		"indent_array_section": indentArraySection,
+		"indent_array":         indentArray,
+		"indent_keys":          indentKeys,
+		"indent_map":           indentMap,
+		"repo":                 g.gitHubRepo,
+	}
+	t := template.Must(template.New(name).Funcs(funcMap).Delims("[[", "]]").Parse(templ))
+	if err := t.Execute(&res, data); err != nil {
+		fatalf("error in template %s: %v", name, err)
+	}
+	for _, line := range strings.Split(res.String(), "\n") {
+		g.outputConfig(line)
+	}
+}
+
+// parseGoCoverageMap constructs a map, indicating which repo (in the org/repo form) is enabled for go coverage check
+func parseGoCoverageMap(presubmitRepos presubmitRepos) map[string]bool {
+	goCoverageMap := make(map[string]bool)
+	for _, repo := range presubmitRepos {
+		goCoverageMap[repo.Name] = false
+		for _, jobConfig := range repo.Jobs {
+			if jobConfig.Type == "go-coverage" {
+				goCoverageMap[repo.Name] = jobConfig.GoCoverage
+			}
+		}
+	}
+
+	return goCoverageMap
+}
+
+// collectMetaData collects the meta data from the input config, which can be then used for building the test groups and dashboards config
+func (g *generator) collectMetaData(periodicRepos periodicRepos) {
+	for _, repo := range periodicRepos {
+		projName := strings.Split(repo.Name, "/")[0]
+		repoName := strings.Split(repo.Name, "/")[1]
+		jobDetailMap := g.addProjAndRepoIfNeed(projName, repoName)
+
+		// parse job configs
+		for _, jobConfig := range repo.Jobs {
+			jobDetailMap = g.metaData[projName]
+			jobName := ""
+			switch jobConfig.Type {
+			case "continuous", "dot-release", "auto-release", "performance", "performance-mesh", "latency", "nightly":
+				jobName = jobConfig.Type
+			case "branch-ci":
+				jobName = "continuous"
+			case "custom-job":
+				jobName = jobConfig.CustomJob
+			default:
+				// continue here since we do not need to care about other job types.
+				continue
+			}
+			// add job types for the corresponding repos, if needed
+			if jobConfig.enabled() {
+				jobProjName := projName
+				// if it's a job for a release branch
+				if jobConfig.Release != "" {
+					jobProjName = fmt.Sprintf("%s-%s", projName, jobConfig.Release)
+					jobDetailMap = g.addProjAndRepoIfNeed(jobProjName, repoName)
+				}
+				newJobTypes := append(jobDetailMap[repoName], jobName)
+				jobDetailMap[repoName] = newJobTypes
+				g.addTestGroupAlert(jobProjName, repoName, jobName, jobConfig.jobConfig)
+			}
+		}
+		g.addTestCoverageJobIfNeeded(&jobDetailMap, projName, repoName)
+	}
+
+	// add test coverage jobs for the repos that haven't been handled
+	g.addRemainingTestCoverageJobs()
+}
+
+// collectPresubmitMetaData collects the presubmit jobs from the input config, which can be then used for building the presubmit test groups and dashboards config
+func (g *generator) collectPresubmitMetaData(presubmitRepos presubmitRepos) {
+	for _, repo := range presubmitRepos {
+		projName := strings.Split(repo.Name, "/")[0]
+		repoName := strings.Split(repo.Name, "/")[1]
+		for _, jobConfig := range repo.Jobs {
+			jobName := ""
+			switch jobConfig.Type {
+			case "build-tests", "unit-tests", "integration-tests", "go-coverage":
+				jobName = jobConfig.Type
+			case "custom-test":
+				jobName = jobConfig.CustomTest
+			default:
+				// continue here since repo-settings does not define a job.
+				continue
+			}
+			if !jobConfig.enabled() {
+				continue
+			}
+			if _, exists := g.presubmitMetaData[projName]; !exists {
+				g.presubmitMetaData[projName] = make(map[string][]string)
+				if !strExists(g.projNames, projName) {
+					g.projNames = append(g.projNames, projName)
+				}
+			}
+			if !strExists(g.repoNames, repoName) {
+				g.repoNames = append(g.repoNames, repoName)
+			}
+			if !strExists(g.presubmitMetaData[projName][repoName], jobName) {
+				g.presubmitMetaData[projName][repoName] = append(g.presubmitMetaData[projName][repoName], jobName)
+			}
+		}
+	}
+}
+
+// addProjAndRepoIfNeed adds the project and repo if they are new in the metaData map, then return the jobDetailMap
+func (g *generator) addProjAndRepoIfNeed(projName string, repoName string) map[string][]string {
+	// add project in the metaData
+	if _, exists := g.metaData[projName]; !exists {
+		g.metaData[projName] = make(map[string][]string)
+		if !strExists(g.projNames, projName) {
+			g.projNames = append(g.projNames, projName)
+		}
+	}
+
+	// add repo in the project
+	jobDetailMap := g.metaData[projName]
+	if _, exists := jobDetailMap[repoName]; !exists {
+		if !strExists(g.repoNames, repoName) {
+			g.repoNames = append(g.repoNames, repoName)
+		}
+		jobDetailMap[repoName] = make([]string, 0)
+	}
+	return jobDetailMap
+}
+
+// addTestCoverageJobIfNeeded adds test-coverage job for the repo if it has go coverage check
+func (g *generator) addTestCoverageJobIfNeeded(jobDetailMap *map[string][]string, projName string, repoName string) {
+	fullRepoName := projName + "/" + repoName
+	if g.goCoverageMap[fullRepoName] {
+		newJobTypes := append((*jobDetailMap)[repoName], "test-coverage")
+		(*jobDetailMap)[repoName] = newJobTypes
+		// delete this repo from the goCoverageMap to avoid it being processed again when we
+		// call the function addRemainingTestCoverageJobs
+		delete(g.goCoverageMap, fullRepoName)
+	}
+}
+
+// addRemainingTestCoverageJobs adds test-coverage jobs for the repos that haven't been processed.
+func (g *generator) addRemainingTestCoverageJobs() {
+	// handle repos that only have go coverage, sorted to keep the output stable
+	fullRepoNames := make([]string, 0, len(g.goCoverageMap))
+	for fullRepoName, hasGoCoverage := range g.goCoverageMap {
+		if hasGoCoverage {
+			fullRepoNames = append(fullRepoNames, fullRepoName)
+		}
+	}
+	sort.Strings(fullRepoNames)
+	for _, fullRepoName := range fullRepoNames {
+		projName := strings.Split(fullRepoName, "/")[0]
+		repoName := strings.Split(fullRepoName, "/")[1]
+		jobDetailMap := g.addProjAndRepoIfNeed(projName, repoName)
+		jobDetailMap[repoName] = []string{"test-coverage"}
+	}
+}
+
+// splitProjName returns the org and the version of the given project name, the version being empty if it's not released.
+func splitProjName(projName string) (string, string) {
+	if match := releasedProjRegexp.FindStringSubmatch(projName); match != nil {
+		return match[1], match[2]
+	}
+	return projName, ""
+}
+
+// buildProjRepoStr builds the projRepoStr used in the config file with projName and repoName
+func buildProjRepoStr(projName string, repoName string) string {
+	projName, projVersion := splitProjName(projName)
+	projRepoStr := repoName
+	if projVersion != "" {
+		projRepoStr += ("-" + projVersion)
+	}
+	projRepoStr = projName + "-" + projRepoStr
+	return strings.ToLower(projRepoStr)
+}
+
+// isReleased returns true for project name that has version
+func isReleased(projName string) bool {
+	return releasedProjRegexp.MatchString(projName)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// make_config_test.go contains unit tests for generating the Prow jobs
+
+package generator
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPodConfigOverrides(t *testing.T) {
+	g := newGenerator(InputConfig{JobTypes: map[string]podConfig{
+		"unit-tests": {Resources: resourcesConfig{Requests: resourceList{CPU: "1", Memory: "2Gi"}}},
+	}}, DefaultOptions(), nil)
+	g.repositories = []repositoryData{{Name: "knative/serving"}}
+	repoSettings := jobConfig{Type: "repo-settings"}
+	repoSettings.NodeSelector = map[string]string{"pool": "serving"}
+	data := g.newbaseProwJobTemplateData("knative/serving")
+	g.parseBasicJobConfigOverrides(&data, repoSettings)
+
+	job := jobConfig{Type: "unit-tests"}
+	job.Resources.Limits.Memory = "4Gi"
+	job.Tolerations = []toleration{{Key: "dedicated", Operator: "Equal", Value: "serving", Effect: "NoSchedule"}}
+	data = g.newbaseProwJobTemplateData("knative/serving")
+	g.parseBasicJobConfigOverrides(&data, job)
+
+	expectedResources := []string{"requests:", "  cpu: 1", `  memory: "2Gi"`, "limits:", `  memory: "4Gi"`}
+	if !reflect.DeepEqual(data.Resources, expectedResources) {
+		t.Errorf("Expected resources %q, got %q", expectedResources, data.Resources)
+	}
+	expectedNodeSelector := []string{`pool: "serving"`}
+	if !reflect.DeepEqual(data.NodeSelector, expectedNodeSelector) {
+		t.Errorf("Expected node selector %q, got %q", expectedNodeSelector, data.NodeSelector)
+	}
+	expectedTolerations := []string{`- key: "dedicated"`, `  operator: "Equal"`, `  value: "serving"`, `  effect: "NoSchedule"`}
+	if !reflect.DeepEqual(data.Tolerations, expectedTolerations) {
+		t.Errorf("Expected tolerations %q, got %q", expectedTolerations, data.Tolerations)
+	}
+}
+
+func TestRepoSettingsOfOtherOrgs(t *testing.T) {
+	config := InputConfig{Settings: map[string]orgSettings{"myorg": {DotDevDomain: "myorg.io"}}}
+	g := newGenerator(config, DefaultOptions(), nil)
+	g.repositories = []repositoryData{{Name: "knative/serving"}, {Name: "myorg/serving"}}
+	repoSettings := jobConfig{Type: "repo-settings", DotDev: true}
+	repoSettings.Resources.Requests.CPU = "3"
+	data := g.newbaseProwJobTemplateData("knative/serving")
+	g.parseBasicJobConfigOverrides(&data, repoSettings)
+	if data.PathAlias != "path_alias: knative.dev/serving" {
+		t.Errorf("Expected the knative.dev path alias for knative/serving, got %q", data.PathAlias)
+	}
+
+	data = g.newbaseProwJobTemplateData("myorg/serving")
+	g.parseBasicJobConfigOverrides(&data, jobConfig{Type: "unit-tests"})
+	if data.PathAlias != "" || len(data.Resources) != 0 {
+		t.Errorf("Expected no settings of knative/serving for myorg/serving, got path alias %q and resources %q", data.PathAlias, data.Resources)
+	}
+	data = g.newbaseProwJobTemplateData("myorg/serving")
+	g.parseBasicJobConfigOverrides(&data, jobConfig{Type: "repo-settings", DotDev: true})
+	if data.PathAlias != "path_alias: myorg.io/serving" {
+		t.Errorf("Expected the myorg.io path alias for myorg/serving, got %q", data.PathAlias)
+	}
+}
+
+func TestTestGroupAlerts(t *testing.T) {
+	g := newGenerator(InputConfig{}, DefaultOptions(), nil)
+	g.repoAlerts = map[string]alertConfig{"knative/serving": {FailuresToAlert: 5, Emails: []string{"serving@knative.dev"}}}
+	job := jobConfig{Type: "continuous"}
+	job.Alert.Emails = []string{"oncall@knative.dev", "serving@knative.dev"}
+	g.addTestGroupAlert("knative", "serving", "continuous", job)
+	g.addTestGroupAlert("knative", "build", "continuous", jobConfig{Type: "continuous"})
+	if len(g.testGroupAlerts) != 1 {
+		t.Fatalf("Expected alert settings only for knative/serving, got %+v", g.testGroupAlerts)
+	}
+
+	var buf bytes.Buffer
+	g.output = &buf
+	g.options.TemplateDir = "../templates"
+	g.executeTestGroupTemplate("ci-knative-serving-continuous", "knative-prow/logs/ci-knative-serving-continuous",
+		map[string]string{"alert_stale_results_hours": "3", "num_failures_to_alert": "3"})
+	g.executeDashboardTabTemplate("continuous", "ci-knative-serving-continuous", testgridTabSortByName, map[string]string{})
+	expected := `- name: ci-knative-serving-continuous
+  gcs_prefix: knative-prow/logs/ci-knative-serving-continuous
+  alert_stale_results_hours: 3
+  num_failures_to_alert: 5
+  - name: continuous
+    test_group_name: ci-knative-serving-continuous
+    base_options: "sort-by-name="
+    alert_options:
+      alert_mail_to_addresses: "oncall@knative.dev,serving@knative.dev"
+`
+	if buf.String() != expected {
+		t.Errorf("Expected Testgrid config:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestPresubmitTestgridConfig(t *testing.T) {
+	config, err := ParseInputConfig("test.yaml", []byte(`
+presubmits:
+  knative/serving:
+    - repo-settings:
+      go-coverage-threshold: 80
+    - unit-tests: true
+    - build-tests: false
+    - custom-test: smoke-tests
+`))
+	if err != nil {
+		t.Fatalf("Cannot parse test config: %v", err)
+	}
+	options := DefaultOptions()
+	options.TemplateDir = "../templates"
+	g := newGenerator(config, options, nil)
+	g.collectPresubmitMetaData(config.Presubmits)
+
+	var buf bytes.Buffer
+	g.output = &buf
+	g.generatePresubmitTestGroups()
+	g.generatePresubmitDashboards()
+	expected := `- name: pull-knative-serving-unit-tests
+  gcs_prefix: knative-prow/pr-logs/directory/pull-knative-serving-unit-tests
+  alert_stale_results_hours: 0
+  num_failures_to_alert: 9999
+- name: pull-knative-serving-smoke-tests
+  gcs_prefix: knative-prow/pr-logs/directory/pull-knative-serving-smoke-tests
+  alert_stale_results_hours: 0
+  num_failures_to_alert: 9999
+- name: serving-presubmits
+  dashboard_tab:
+  - name: unit-tests
+    test_group_name: pull-knative-serving-unit-tests
+    base_options: "sort-by-name="
+  - name: smoke-tests
+    test_group_name: pull-knative-serving-smoke-tests
+    base_options: "sort-by-name="
+`
+	if buf.String() != expected {
+		t.Errorf("Expected Testgrid config:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
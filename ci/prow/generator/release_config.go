@@ -0,0 +1,187 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// release branch jobs generated from the branches of the repositories on GitHub
+
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/knative/test-infra/shared/ghutil"
+)
+
+var (
+	// releaseBranchRegexp matches the release branches, like "release-0.7".
+	releaseBranchRegexp = regexp.MustCompile(`^release-[0-9]+\.[0-9]+$`)
+)
+
+// ExpandReleaseBranches returns the given input config with the release branch jobs of the repositories in its
+// releases section matching their release branches on GitHub, listed with the given client:
+// - the branch-ci periodic jobs are replaced by one for each of the supported (i.e., latest) releases,
+// - the presubmit jobs skip the release branches not supported anymore, and so does the legacy-branches setting.
+// The first branch-ci job of a repository, if any, is the model of the generated ones.
+func ExpandReleaseBranches(config InputConfig, client ghutil.GithubOperations) (InputConfig, error) {
+	res := config
+	res.Presubmits = append(presubmitRepos{}, config.Presubmits...)
+	res.Periodics = append(periodicRepos{}, config.Periodics...)
+	for _, repoName := range config.Releases.Repos {
+		parts := strings.Split(repoName, "/")
+		branches, err := client.ListBranches(parts[0], parts[1])
+		if err != nil {
+			return config, fmt.Errorf("cannot list the branches of %s: %v", repoName, err)
+		}
+		var names []string
+		for _, branch := range branches {
+			names = append(names, branch.GetName())
+		}
+		supported, unsupported := splitReleaseBranches(names, config.Releases.Supported)
+		res.Periodics = expandBranchCIJobs(res.Periodics, repoName, supported)
+		for i, repo := range res.Presubmits {
+			if repo.Name == repoName && len(unsupported) > 0 {
+				res.Presubmits[i].Jobs = skipReleaseBranches(repo.Jobs, unsupported, config.Templates)
+			}
+		}
+	}
+	return res, nil
+}
+
+// splitReleaseBranches returns the releases (like "0.7") of the given number of latest release branches, oldest first,
+// and the release branches older than them. Other branches are ignored.
+func splitReleaseBranches(branches []string, supported int) ([]string, []string) {
+	var releases []string
+	for _, branch := range branches {
+		if releaseBranchRegexp.MatchString(branch) {
+			releases = append(releases, strings.TrimPrefix(branch, "release-"))
+		}
+	}
+	sort.Slice(releases, func(i, j int) bool { return releaseLess(releases[i], releases[j]) })
+	var unsupported []string
+	for len(releases) > supported {
+		unsupported = append(unsupported, "release-"+releases[0])
+		releases = releases[1:]
+	}
+	return releases, unsupported
+}
+
+// releaseLess returns whether the release a (like "0.7") is older than the release b (like "0.10").
+func releaseLess(a, b string) bool {
+	va, vb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(va) && i < len(vb); i++ {
+		na, errA := strconv.Atoi(va[i])
+		nb, errB := strconv.Atoi(vb[i])
+		if errA != nil || errB != nil {
+			return a < b
+		}
+		if na != nb {
+			return na < nb
+		}
+	}
+	return len(va) < len(vb)
+}
+
+// expandBranchCIJobs returns the given periodic jobs with the branch-ci jobs of the given repository
+// replaced by one for each given release, where the first one was.
+func expandBranchCIJobs(repos periodicRepos, repoName string, releases []string) periodicRepos {
+	index := -1
+	for i, repo := range repos {
+		if repo.Name == repoName {
+			index = i
+		}
+	}
+	if index < 0 {
+		repos = append(repos, periodicRepo{Name: repoName})
+		index = len(repos) - 1
+	}
+	model := periodicJobConfig{BranchCI: true, jobConfig: jobConfig{Type: "branch-ci"}}
+	position := -1
+	var jobs []periodicJobConfig
+	for _, job := range repos[index].Jobs {
+		if job.Type == "branch-ci" && job.Release != "" {
+			if position < 0 {
+				model, position = job, len(jobs)
+			}
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	if position < 0 {
+		position = len(jobs)
+	}
+	var generated []periodicJobConfig
+	for _, release := range releases {
+		job := model
+		job.Release = release
+		generated = append(generated, job)
+	}
+	repos[index].Jobs = append(append(append([]periodicJobConfig{}, jobs[:position]...), generated...), jobs[position:]...)
+	return repos
+}
+
+// skipReleaseBranches returns the given presubmit jobs of a repository, not running on the given branches anymore.
+func skipReleaseBranches(jobs []presubmitJobConfig, branches []string, templates map[string]jobTemplate) []presubmitJobConfig {
+	res := make([]presubmitJobConfig, len(jobs))
+	for i, job := range jobs {
+		res[i] = job
+		expanded := expandJobConfig(job.jobConfig, templates)
+		if job.Type == "repo-settings" {
+			if expanded.LegacyBranches != nil {
+				res[i].LegacyBranches = []string{}
+				for _, branch := range expanded.LegacyBranches {
+					if !strExists(branches, branch) {
+						res[i].LegacyBranches = append(res[i].LegacyBranches, branch)
+					}
+				}
+			}
+			continue
+		}
+		// Jobs running only on some branches are left as is.
+		if expanded.Branches == nil {
+			res[i].SkipBranches = combineSlices(expanded.SkipBranches, branches)
+		}
+	}
+	return res
+}
+
+// sortReleasedProjNames returns the names of the released projects (like "knative-0.7") among the given ones,
+// sorted by project and release.
+func sortReleasedProjNames(projNames []string) []string {
+	var res []string
+	// Keep the projects in the order they first appear.
+	order := make(map[string]int)
+	for _, projName := range projNames {
+		if isReleased(projName) {
+			res = append(res, projName)
+			name, _ := splitProjName(projName)
+			if _, exists := order[name]; !exists {
+				order[name] = len(order)
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		nameI, releaseI := splitProjName(res[i])
+		nameJ, releaseJ := splitProjName(res[j])
+		if nameI != nameJ {
+			return order[nameI] < order[nameJ]
+		}
+		return releaseLess(releaseI, releaseJ)
+	})
+	return res
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// release_config_test.go contains unit tests for generating the release branch jobs from the GitHub branches
+
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/knative/test-infra/shared/ghutil/fakeghutil"
+)
+
+const releasesInputConfig = `
+releases:
+  supported: 3
+  repos:
+  - knative/serving
+  - knative/eventing
+
+presubmits:
+  knative/serving:
+  - repo-settings:
+    legacy-branches:
+    - release-0.6
+    - release-0.7
+  - unit-tests: true
+  - integration-tests: true
+    skip_branches:
+    - release-0.8
+  - custom-test: legacy-tests
+    branches:
+    - release-0.6
+
+periodics:
+  knative/serving:
+  - continuous: true
+  - branch-ci: true
+    release: "0.6"
+    needs-dind: true
+  - branch-ci: true
+    release: "0.7"
+  - nightly: true
+`
+
+var splitReleaseBranchesTests = []struct {
+	branches    []string
+	supported   int
+	releases    []string
+	unsupported []string
+}{
+	{[]string{"master", "release-0.7", "release-0.10", "release-0.9", "release-0.8"}, 3, []string{"0.8", "0.9", "0.10"}, []string{"release-0.7"}},
+	{[]string{"release-1.0", "release-0.12", "release-next", "release-1.0-rc1"}, 5, []string{"0.12", "1.0"}, nil},
+	{[]string{"master"}, 2, nil, nil},
+}
+
+func TestSplitReleaseBranches(t *testing.T) {
+	for _, test := range splitReleaseBranchesTests {
+		releases, unsupported := splitReleaseBranches(test.branches, test.supported)
+		if !reflect.DeepEqual(releases, test.releases) || !reflect.DeepEqual(unsupported, test.unsupported) {
+			t.Errorf("%v: expected releases %v and unsupported branches %v, got %v and %v", test.branches, test.releases, test.unsupported, releases, unsupported)
+		}
+	}
+}
+
+func TestExpandReleaseBranches(t *testing.T) {
+	config, err := ParseInputConfig("test.yaml", []byte(releasesInputConfig))
+	if err != nil {
+		t.Fatalf("Cannot parse test config: %v", err)
+	}
+	client := fakeghutil.NewFakeGithubClient()
+	for _, branch := range []string{"master", "release-0.6", "release-0.7", "release-0.8", "release-0.9"} {
+		client.AddBranch("knative", "serving", branch)
+	}
+	client.AddBranch("knative", "eventing", "release-0.9")
+	res, err := ExpandReleaseBranches(config, client)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	periodics := make(map[string][]string)
+	for _, repo := range res.Periodics {
+		for _, job := range repo.Jobs {
+			periodics[repo.Name] = append(periodics[repo.Name], job.Type+" "+job.Release)
+			if repo.Name == "knative/serving" && job.Type == "branch-ci" && !job.NeedsDind {
+				t.Errorf("%s: expected branch-ci %s to be like the first hand-written one", repo.Name, job.Release)
+			}
+		}
+	}
+	expectedPeriodics := map[string][]string{
+		"knative/serving":  {"continuous ", "branch-ci 0.7", "branch-ci 0.8", "branch-ci 0.9", "nightly "},
+		"knative/eventing": {"branch-ci 0.9"},
+	}
+	if !reflect.DeepEqual(periodics, expectedPeriodics) {
+		t.Errorf("Expected periodic jobs %v, got %v", expectedPeriodics, periodics)
+	}
+
+	jobs := res.Presubmits[0].Jobs
+	if expected := []string{"release-0.7"}; !reflect.DeepEqual(jobs[0].LegacyBranches, expected) {
+		t.Errorf("Expected legacy branches %v, got %v", expected, jobs[0].LegacyBranches)
+	}
+	for i, expected := range [][]string{{"release-0.6"}, {"release-0.8", "release-0.6"}, nil} {
+		if !reflect.DeepEqual(jobs[i+1].SkipBranches, expected) {
+			t.Errorf("%s: expected skip branches %v, got %v", jobs[i+1].Type, expected, jobs[i+1].SkipBranches)
+		}
+	}
+
+	// The given config is left untouched.
+	if len(config.Periodics) != 1 || len(config.Periodics[0].Jobs) != 4 || config.Presubmits[0].Jobs[1].SkipBranches != nil {
+		t.Errorf("Expected the input config to be left untouched, got %+v", config)
+	}
+}
+
+func TestSortReleasedProjNames(t *testing.T) {
+	projNames := []string{"knative", "knative-0.9", "google-1.0", "knative-0.10", "knative-0.8", "google"}
+	expected := []string{"knative-0.8", "knative-0.9", "knative-0.10", "google-1.0"}
+	if res := sortReleasedProjNames(projNames); !reflect.DeepEqual(res, expected) {
+		t.Errorf("Expected %v, got %v", expected, res)
+	}
+}
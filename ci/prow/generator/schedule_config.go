@@ -0,0 +1,454 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// scheduling of the periodic jobs, spreading their start times to limit how many run at once
+
+package generator
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	minutesPerHour = 60
+	minutesPerDay  = 24 * minutesPerHour
+	minutesPerWeek = 7 * minutesPerDay
+
+	// defaultScheduleTimeZone is the time zone of the windows, if none is given.
+	defaultScheduleTimeZone = "America/Los_Angeles"
+
+	// startSpacing is the number of minutes under which jobs starting one after the other are considered to start together.
+	startSpacing = 5
+)
+
+var (
+	// defaultJobTypeSchedules is the scheduling policy of each periodic job type, unless overridden in the input config.
+	defaultJobTypeSchedules = map[string]jobTypeSchedule{
+		"continuous":          {Interval: "hourly"},
+		"custom-job":          {Interval: "hourly"},
+		"auto-release":        {Interval: "hourly"},
+		"branch-ci":           {Interval: "daily", Window: "00:00-04:00"},
+		"nightly":             {Interval: "daily", Window: "01:00-05:00"},
+		"dot-release":         {Interval: "weekly", Window: "01:00-05:00", Weekday: "Tuesday"},
+		"latency":             {Interval: "daily", Window: "00:00-04:00"},
+		"performance":         {Interval: "daily", Window: "00:00-04:00"},
+		"performance-mesh":    {Interval: "daily", Window: "02:00-06:00"},
+		"webhook-apicoverage": {Interval: "daily", Window: "01:00-05:00"},
+		"go-coverage":         {Interval: "daily", Window: "17:00-21:00"},
+	}
+)
+
+// scheduleRequest is a periodic job whose cron must be generated.
+type scheduleRequest struct {
+	JobType string
+	JobName string
+	Timeout int
+}
+
+// scheduleCandidate is a possible schedule of a job: its cron and the start times of its runs.
+type scheduleCandidate struct {
+	Cron   string
+	Starts []int // minutes of the week, in UTC, starting on Sunday
+}
+
+// scheduler generates the crons of the periodic jobs.
+// The jobs are first collected (with their cron unknown) by generating the config once, then scheduled
+// one after the other, each at the time the fewest jobs are already running, away from the start of other jobs.
+// Jobs restricted to a window are scheduled first, in the order they were generated, then the hourly ones can
+// fill the gaps around them.
+type scheduler struct {
+	config   scheduleConfig
+	requests []scheduleRequest
+	crons    map[string]string
+	// load is the number of jobs running at each minute of the week, in UTC.
+	load []int
+	// starts is the number of jobs starting at each minute of the week, in UTC.
+	starts []int
+}
+
+// newScheduler returns a scheduler using the given policy.
+func newScheduler(config scheduleConfig) *scheduler {
+	return &scheduler{config: config, crons: make(map[string]string), load: make([]int, minutesPerWeek), starts: make([]int, minutesPerWeek)}
+}
+
+// generateCron returns the cron of the given periodic job, based on its type and timeout.
+// Until the jobs are scheduled, the job is recorded and an empty cron is returned.
+func (g *generator) generateCron(jobType, jobName string, timeout int) string {
+	if cron, exists := g.scheduler.crons[jobName]; exists {
+		return cron
+	}
+	g.scheduler.requests = append(g.scheduler.requests, scheduleRequest{JobType: jobType, JobName: jobName, Timeout: timeout})
+	return ""
+}
+
+// schedule generates the crons of the recorded jobs, taking into account the load of the given jobs with a fixed cron.
+func (s *scheduler) schedule(jobs []Job) error {
+	for _, job := range jobs {
+		if job.Kind != "periodic" || job.Cron == "" {
+			continue
+		}
+		starts, err := cronStarts(job.Cron)
+		if err != nil {
+			return fmt.Errorf("cannot schedule around %s: %v", job.Name, err)
+		}
+		s.add(starts, job.Timeout)
+	}
+	var hourly, windowed []scheduleRequest
+	for _, req := range s.requests {
+		if policy, _ := s.policy(req.JobType); policy.Interval == "hourly" {
+			hourly = append(hourly, req)
+		} else {
+			windowed = append(windowed, req)
+		}
+	}
+	for _, req := range append(windowed, hourly...) {
+		policy, exists := s.policy(req.JobType)
+		if !exists {
+			// No cron for this job type, this is reported when validating the generated jobs.
+			continue
+		}
+		candidates, err := s.candidates(policy, req.Timeout)
+		if err != nil {
+			return fmt.Errorf("cannot schedule %s: %v", req.JobName, err)
+		}
+		best, peak := s.best(candidates, req.Timeout)
+		if s.config.MaxConcurrentJobs > 0 && peak > s.config.MaxConcurrentJobs {
+			return fmt.Errorf("cannot schedule %s without running %d jobs at once, more than the %d allowed", req.JobName, peak, s.config.MaxConcurrentJobs)
+		}
+		s.add(best.Starts, req.Timeout)
+		s.crons[req.JobName] = best.Cron
+	}
+	return nil
+}
+
+// policy returns the scheduling policy of the given job type, and false if it has none.
+func (s *scheduler) policy(jobType string) (jobTypeSchedule, bool) {
+	policy, exists := s.config.JobTypes[jobType]
+	if !exists {
+		policy, exists = defaultJobTypeSchedules[jobType]
+	}
+	if policy.TimeZone == "" {
+		policy.TimeZone = s.config.TimeZone
+	}
+	if policy.TimeZone == "" {
+		policy.TimeZone = defaultScheduleTimeZone
+	}
+	return policy, exists
+}
+
+// candidates returns the possible schedules of a job following the given policy.
+func (s *scheduler) candidates(policy jobTypeSchedule, timeout int) ([]scheduleCandidate, error) {
+	var candidates []scheduleCandidate
+	if policy.Interval == "hourly" {
+		hours := int((timeout+5)/60) + 1 // Allow at least 5 minutes between runs
+		for hour := 0; hour < hours; hour++ {
+			for minute := 0; minute < minutesPerHour; minute++ {
+				c := scheduleCandidate{Cron: fmt.Sprintf("%d * * * *", minute)}
+				if hours > 1 && hour == 0 {
+					c.Cron = fmt.Sprintf("%d */%d * * *", minute, hours)
+				} else if hours > 1 {
+					c.Cron = fmt.Sprintf("%d %d-23/%d * * *", minute, hour, hours)
+				}
+				for day := 0; day < 7; day++ {
+					for h := hour; h < 24; h += hours {
+						c.Starts = append(c.Starts, day*minutesPerDay+h*minutesPerHour+minute)
+					}
+				}
+				candidates = append(candidates, c)
+			}
+		}
+		return candidates, nil
+	}
+
+	starts, err := windowStarts(policy)
+	if err != nil {
+		return nil, err
+	}
+	for _, start := range starts {
+		minute, hour := start%minutesPerHour, start%minutesPerDay/minutesPerHour
+		if policy.Interval == "weekly" {
+			c := scheduleCandidate{Cron: fmt.Sprintf("%d %d * * %d", minute, hour, start/minutesPerDay), Starts: []int{start}}
+			candidates = append(candidates, c)
+			continue
+		}
+		c := scheduleCandidate{Cron: fmt.Sprintf("%d %d * * *", minute, hour)}
+		for day := 0; day < 7; day++ {
+			c.Starts = append(c.Starts, day*minutesPerDay+start%minutesPerDay)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// windowStarts returns the minutes of the week (in UTC) a job following the given daily or weekly policy can start at.
+// As crons are in UTC, only the times within the window both in standard and in daylight saving time are returned.
+// If there are none (i.e., the window is shorter than the daylight saving shift), the window in standard time is used.
+func windowStarts(policy jobTypeSchedule) ([]int, error) {
+	from, to, err := parseWindow(policy.Window)
+	if err != nil {
+		return nil, err
+	}
+	weekday := -1
+	if policy.Interval == "weekly" {
+		day, err := parseWeekday(policy.Weekday)
+		if err != nil {
+			return nil, err
+		}
+		weekday = int(day)
+	}
+	offsets, err := timeZoneOffsets(policy.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	inWindow := func(utc, offset int) bool {
+		local := ((utc+offset)%minutesPerWeek + minutesPerWeek) % minutesPerWeek
+		if weekday != -1 && local/minutesPerDay != weekday {
+			return false
+		}
+		minute := local % minutesPerDay
+		if from <= to {
+			return minute >= from && minute < to
+		}
+		return minute >= from || minute < to
+	}
+	var starts []int
+	for utc := 0; utc < minutesPerWeek; utc++ {
+		valid := true
+		for _, offset := range offsets {
+			valid = valid && inWindow(utc, offset)
+		}
+		if valid {
+			starts = append(starts, utc)
+		}
+	}
+	if len(starts) == 0 {
+		log.Printf("Window %s in %s is shorter than the daylight saving time shift, jobs will start outside of it part of the year", policy.Window, policy.TimeZone)
+		for utc := 0; utc < minutesPerWeek; utc++ {
+			if inWindow(utc, offsets[0]) {
+				starts = append(starts, utc)
+			}
+		}
+	}
+	if weekday == -1 {
+		// Daily jobs run at the same time every day, only keep the first day.
+		var daily []int
+		for _, start := range starts {
+			if start < minutesPerDay {
+				daily = append(daily, start)
+			}
+		}
+		starts = daily
+	}
+	return starts, nil
+}
+
+// timeZoneOffsets returns the offsets (in minutes) from UTC used by the given time zone this year and the next one
+// (as the generated schedules stay in use past the end of the year), standard time first.
+func timeZoneOffsets(name string) ([]int, error) {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	var offsets []int
+	year := time.Now().Year()
+	for _, y := range []int{year, year + 1} {
+		for month := time.January; month <= time.December; month++ {
+			_, offset := time.Date(y, month, 1, 12, 0, 0, 0, location).Zone()
+			offset /= 60
+			if !intExists(offsets, offset) {
+				offsets = append(offsets, offset)
+			}
+		}
+	}
+	// Daylight saving time is always ahead of standard time.
+	if len(offsets) > 1 && offsets[1] < offsets[0] {
+		offsets[0], offsets[1] = offsets[1], offsets[0]
+	}
+	return offsets, nil
+}
+
+// best returns the candidate with the lowest peak of jobs running at once. In case of a tie, it returns the one
+// starting the least often with other jobs (so they don't all start at the same minute), then the one with the
+// lowest overall load.
+func (s *scheduler) best(candidates []scheduleCandidate, timeout int) (scheduleCandidate, int) {
+	var best scheduleCandidate
+	bestPeak, bestNear, bestSum := -1, 0, 0
+	for _, c := range candidates {
+		peak, near, sum := 0, 0, 0
+		for _, start := range c.Starts {
+			for m := start - startSpacing + 1; m < start+startSpacing; m++ {
+				near += s.starts[(m%minutesPerWeek+minutesPerWeek)%minutesPerWeek]
+			}
+			for m := start; m < start+timeout; m++ {
+				load := s.load[m%minutesPerWeek] + 1
+				if load > peak {
+					peak = load
+				}
+				sum += load
+			}
+		}
+		if bestPeak == -1 || peak < bestPeak || (peak == bestPeak && (near < bestNear || (near == bestNear && sum < bestSum))) {
+			best, bestPeak, bestNear, bestSum = c, peak, near, sum
+		}
+	}
+	return best, bestPeak
+}
+
+// add records the load of a job starting at the given times and running for the given timeout.
+func (s *scheduler) add(starts []int, timeout int) {
+	for _, start := range starts {
+		s.starts[start%minutesPerWeek]++
+		for m := start; m < start+timeout; m++ {
+			s.load[m%minutesPerWeek]++
+		}
+	}
+}
+
+// printLoad writes a histogram of the highest number of jobs running at once for each hour of the day, in UTC.
+func (s *scheduler) printLoad(out io.Writer) {
+	peaks := make([]int, 24)
+	maxPeak := 0
+	for m, load := range s.load {
+		hour := m % minutesPerDay / minutesPerHour
+		if load > peaks[hour] {
+			peaks[hour] = load
+		}
+		if load > maxPeak {
+			maxPeak = load
+		}
+	}
+	fmt.Fprintln(out, "Highest number of periodic jobs running at once, by hour (UTC):")
+	for hour, peak := range peaks {
+		fmt.Fprintf(out, "%02d:00 %3d %s\n", hour, peak, strings.Repeat("#", peak))
+	}
+	fmt.Fprintf(out, "Peak: %d jobs\n", maxPeak)
+}
+
+// cronStarts returns the minutes of the week (in UTC) the given cron starts a job at.
+// Day of month and month are ignored, so jobs running on some days of the month are considered to run every day.
+func cronStarts(cron string) ([]int, error) {
+	fields := strings.Fields(cron)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q must have 5 fields", cron)
+	}
+	minutes, err := cronField(fields[0], 0, 59)
+	if err != nil {
+		return nil, err
+	}
+	hours, err := cronField(fields[1], 0, 23)
+	if err != nil {
+		return nil, err
+	}
+	days, err := cronField(fields[4], 0, 6)
+	if err != nil {
+		return nil, err
+	}
+	var starts []int
+	for _, day := range days {
+		for _, hour := range hours {
+			for _, minute := range minutes {
+				starts = append(starts, day*minutesPerDay+hour*minutesPerHour+minute)
+			}
+		}
+	}
+	return starts, nil
+}
+
+// cronField returns the values matched by the given cron field, like "*/3", "1-23/2" or "0,30".
+func cronField(field string, min, max int) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(field, ",") {
+		rangeStr, step := part, 1
+		if i := strings.Index(part, "/"); i != -1 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in cron field %q", field)
+			}
+			rangeStr = part[:i]
+		}
+		from, to := min, max
+		if rangeStr != "*" {
+			bounds := strings.SplitN(rangeStr, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid cron field %q", field)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid cron field %q", field)
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("cron field %q is out of range %d-%d", field, min, max)
+		}
+		for v := from; v <= to; v += step {
+			if !intExists(values, v) {
+				values = append(values, v)
+			}
+		}
+	}
+	return values, nil
+}
+
+// parseWindow returns the start and end (in minutes of the day) of the given time range, like "01:00-03:00".
+// The end is excluded, and it's before the start if the window spans midnight.
+func parseWindow(window string) (int, int, error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("window must be like \"01:00-03:00\", got %q", window)
+	}
+	var bounds [2]int
+	for i, part := range parts {
+		var hour, minute int
+		if n, err := fmt.Sscanf(part, "%d:%d", &hour, &minute); err != nil || n != 2 || hour < 0 || hour > 24 || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+			return 0, 0, fmt.Errorf("window must be like \"01:00-03:00\", got %q", window)
+		}
+		bounds[i] = (hour*minutesPerHour + minute) % minutesPerDay
+	}
+	if bounds[0] == bounds[1] {
+		return 0, 0, fmt.Errorf("window %q is empty", window)
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// parseWeekday returns the day of the week with the given name, like "Tuesday".
+func parseWeekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("weekday must be a day name like \"Tuesday\", got %q", name)
+}
+
+// intExists checks if the given int exists in the array
+func intExists(arr []int, i int) bool {
+	for _, v := range arr {
+		if v == i {
+			return true
+		}
+	}
+	return false
+}
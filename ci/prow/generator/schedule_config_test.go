@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// schedule_config_test.go contains unit tests for scheduling the periodic jobs
+
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var cronStartsTests = []struct {
+	cron   string
+	starts []int
+}{
+	{"15 9 * * 2", []int{2*minutesPerDay + 9*minutesPerHour + 15}},
+	{"0,30 1 * * 0", []int{60, 90}},
+	{"5 1-23/8 * * 6", []int{6*minutesPerDay + 65, 6*minutesPerDay + 545, 6*minutesPerDay + 1025}},
+	{"5 */12 * * 1-2", []int{minutesPerDay + 5, minutesPerDay + 725, 2*minutesPerDay + 5, 2*minutesPerDay + 725}},
+}
+
+func TestCronStarts(t *testing.T) {
+	for _, test := range cronStartsTests {
+		starts, err := cronStarts(test.cron)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.cron, err)
+			continue
+		}
+		if !reflect.DeepEqual(starts, test.starts) {
+			t.Errorf("%q: expected starts %v, got %v", test.cron, test.starts, starts)
+		}
+	}
+	for _, cron := range []string{"0 * * *", "60 * * * *", "0 */0 * * *", "a * * * *"} {
+		if _, err := cronStarts(cron); err == nil {
+			t.Errorf("%q: expected error, got none", cron)
+		}
+	}
+}
+
+func TestWindowStarts(t *testing.T) {
+	// 01:00-04:00 in Los Angeles is 09:00-12:00 UTC in standard time, and 08:00-11:00 UTC in daylight saving time.
+	starts, err := windowStarts(jobTypeSchedule{Interval: "daily", Window: "01:00-04:00", TimeZone: "America/Los_Angeles"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(starts) != 2*minutesPerHour || starts[0] != 9*minutesPerHour || starts[len(starts)-1] != 11*minutesPerHour-1 {
+		t.Errorf("Expected starts from 09:00 to 10:59 UTC, got %d starts from %d to %d", len(starts), starts[0], starts[len(starts)-1])
+	}
+
+	// Tuesday 08:00-10:00 in Tokyo (no daylight saving time) is Monday 23:00 to Tuesday 01:00 UTC.
+	starts, err = windowStarts(jobTypeSchedule{Interval: "weekly", Window: "08:00-10:00", Weekday: "Tuesday", TimeZone: "Asia/Tokyo"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(starts) != 2*minutesPerHour || starts[0] != minutesPerDay+23*minutesPerHour {
+		t.Errorf("Expected starts from Monday 23:00 to Tuesday 00:59 UTC, got %d starts from %d", len(starts), starts[0])
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	g := newGenerator(InputConfig{}, DefaultOptions(), newScheduler(scheduleConfig{MaxConcurrentJobs: 2}))
+	fixed := Job{Kind: "periodic", Name: "fixed", Cron: "0 * * * *", Timeout: 30}
+	for _, name := range []string{"a", "b", "c"} {
+		if cron := g.generateCron("continuous", name, 25); cron != "" {
+			t.Errorf("Expected no cron before scheduling, got %q", cron)
+		}
+	}
+	if err := g.scheduler.schedule([]Job{fixed}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The first job fills the gap left by the fixed one, the others overlap as little as possible, without starting with another job.
+	expected := map[string]string{"a": "30 * * * *", "b": "35 * * * *", "c": "5 * * * *"}
+	for name, cron := range expected {
+		if res := g.generateCron("continuous", name, 25); res != cron {
+			t.Errorf("Expected cron %q for %q, got %q", cron, name, res)
+		}
+	}
+
+	// Long daily jobs overlap whatever their start time, they still start at different times.
+	g.scheduler = newScheduler(scheduleConfig{})
+	names := []string{"d1", "d2", "d3", "d4", "d5", "d6"}
+	for _, name := range names {
+		g.generateCron("branch-ci", name, 600)
+	}
+	if err := g.scheduler.schedule(nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	crons := make(map[string]bool)
+	for _, name := range names {
+		cron := g.generateCron("branch-ci", name, 600)
+		if crons[cron] {
+			t.Errorf("Expected the daily jobs to start at different times, got %q twice", cron)
+		}
+		crons[cron] = true
+	}
+
+	g.scheduler = newScheduler(scheduleConfig{MaxConcurrentJobs: 1})
+	g.generateCron("continuous", "a", 25)
+	err := g.scheduler.schedule([]Job{fixed, {Kind: "periodic", Name: "fixed2", Cron: "30 * * * *", Timeout: 30}})
+	if err == nil || !strings.Contains(err.Error(), "cannot schedule a without running 2 jobs at once") {
+		t.Errorf("Expected error about too many jobs at once, got %v", err)
+	}
+}
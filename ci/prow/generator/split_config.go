@@ -0,0 +1,154 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// output of the generated Prow jobs in one file per repository and job type
+
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+const (
+	// jobsHeaderConfig is the header of each generated job config file.
+	jobsHeaderConfig = "prow_jobs_header.yaml"
+)
+
+var (
+	// jobConfigFileRegexp matches the paths of the generated job config files, relative to the jobs output dir.
+	jobConfigFileRegexp = regexp.MustCompile(`^[^/]+/([^/]+)/([^/]+)-(presubmits|postsubmits|periodics)\.yaml$`)
+)
+
+// jobConfigFileName returns the path of the file containing the jobs of the given section (e.g., "presubmits") for the given repository.
+// The path is relative to the jobs output dir, like "knative/serving/serving-presubmits.yaml".
+func jobConfigFileName(section, repoName string) string {
+	return path.Join(repoName, fmt.Sprintf("%s-%s.yaml", path.Base(repoName), section))
+}
+
+// jobConfigOutput returns the output for the given job config file, starting it with the header if it's a new file.
+func (g *generator) jobConfigOutput(fileName string) io.Writer {
+	if buf, exists := g.jobConfigFiles[fileName]; exists {
+		return buf
+	}
+	buf := &bytes.Buffer{}
+	g.jobConfigFiles[fileName] = buf
+	g.output = buf
+	g.executeTemplate("jobs header", g.readTemplate(jobsHeaderConfig), g.newbaseProwJobTemplateData(""))
+	return buf
+}
+
+// sortedJobConfigFileNames returns the names of the given job config files, sorted.
+func sortedJobConfigFileNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// staleJobConfigFiles returns the job config files found in the given dir that aren't in the generated files, e.g. for removed repositories.
+// Files not following the job config files naming are ignored.
+func staleJobConfigFiles(dir string, files map[string]string) ([]string, error) {
+	var stale []string
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		match := jobConfigFileRegexp.FindStringSubmatch(name)
+		if match == nil || match[1] != match[2] {
+			return nil
+		}
+		if _, exists := files[name]; !exists {
+			stale = append(stale, name)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return stale, err
+}
+
+// WriteJobConfigFiles writes the given job config files (ProwConfig.JobFiles) to the given dir, and removes the stale ones.
+func WriteJobConfigFiles(dir string, files map[string]string) error {
+	for _, name := range sortedJobConfigFileNames(files) {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			return fmt.Errorf("cannot create dir for %q: %v", fileName, err)
+		}
+		if err := ioutil.WriteFile(fileName, []byte(files[name]), 0644); err != nil {
+			return fmt.Errorf("cannot write %q: %v", fileName, err)
+		}
+	}
+	stale, err := staleJobConfigFiles(dir, files)
+	if err != nil {
+		return fmt.Errorf("cannot list the job config files in %q: %v", dir, err)
+	}
+	for _, name := range stale {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.Remove(fileName); err != nil {
+			return fmt.Errorf("cannot remove stale file %q: %v", fileName, err)
+		}
+		// Remove the dirs of the repository and org if they're now empty, ignoring the error otherwise.
+		repoDir := filepath.Dir(fileName)
+		if os.Remove(repoDir) == nil {
+			os.Remove(filepath.Dir(repoDir))
+		}
+	}
+	return nil
+}
+
+// CheckJobConfigFiles compares the given job config files (ProwConfig.JobFiles) with the ones in the given dir.
+// The differences, missing and stale files are written to out. Returns true if they are identical.
+func CheckJobConfigFiles(dir string, files map[string]string, out io.Writer) (bool, error) {
+	upToDate := true
+	for _, name := range sortedJobConfigFileNames(files) {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Stat(fileName); os.IsNotExist(err) {
+			fmt.Fprintf(out, "%s: missing\n", fileName)
+			upToDate = false
+			continue
+		}
+		same, err := CheckGeneratedConfig(fileName, files[name], out)
+		if err != nil {
+			return false, err
+		}
+		upToDate = same && upToDate
+	}
+	stale, err := staleJobConfigFiles(dir, files)
+	if err != nil {
+		return false, fmt.Errorf("cannot list the job config files in %q: %v", dir, err)
+	}
+	for _, name := range stale {
+		fmt.Fprintf(out, "%s: not generated anymore, must be removed\n", filepath.Join(dir, filepath.FromSlash(name)))
+		upToDate = false
+	}
+	return upToDate, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// split_config_test.go contains unit tests for writing the jobs in one file per repository
+
+package generator
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJobConfigFileName(t *testing.T) {
+	if name := jobConfigFileName("presubmits", "knative/serving"); name != "knative/serving/serving-presubmits.yaml" {
+		t.Errorf("Expected knative/serving/serving-presubmits.yaml, got %q", name)
+	}
+}
+
+func TestWriteJobConfigFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A stale file from a removed repository, and files not generated by the tool.
+	for _, name := range []string{"knative/old/old-periodics.yaml", "knative/serving/OWNERS", "README.md"} {
+		fileName := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(fileName), 0755)
+		if err := ioutil.WriteFile(fileName, []byte("foo"), 0644); err != nil {
+			t.Fatalf("Cannot write %q: %v", fileName, err)
+		}
+	}
+	files := map[string]string{
+		"knative/serving/serving-presubmits.yaml": "presubmits:\n",
+		"knative/serving/serving-periodics.yaml":  "periodics:\n",
+	}
+
+	stale, err := staleJobConfigFiles(dir, files)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"knative/old/old-periodics.yaml"}; !reflect.DeepEqual(stale, expected) {
+		t.Errorf("Expected stale files %v, got %v", expected, stale)
+	}
+
+	var out bytes.Buffer
+	if upToDate, err := CheckJobConfigFiles(dir, files, &out); err != nil || upToDate {
+		t.Errorf("Expected job configs not to be up to date, got %v (error %v)", upToDate, err)
+	}
+
+	if err := WriteJobConfigFiles(dir, files); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "knative/old")); !os.IsNotExist(err) {
+		t.Errorf("Expected the dir of the removed repository to be deleted, got %v", err)
+	}
+	for _, name := range []string{"knative/serving/OWNERS", "README.md"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %q to be kept, got %v", name, err)
+		}
+	}
+	if upToDate, err := CheckJobConfigFiles(dir, files, &out); err != nil || !upToDate {
+		t.Errorf("Expected job configs to be up to date, got %v (error %v)", upToDate, err)
+	}
+}
@@ -0,0 +1,372 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// data definitions that are used for the testgrid config file generation
+
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// baseOptions setting for testgrid dashboard tabs
+	testgridTabGroupByDir    = "exclude-filter-by-regex=Overall$&group-by-directory=&expand-groups=&sort-by-name="
+	testgridTabGroupByTarget = "exclude-filter-by-regex=Overall$&group-by-target=&expand-groups=&sort-by-name="
+	testgridTabSortByName    = "sort-by-name="
+
+	// generalTestgridConfig contains config-wide definitions.
+	generalTestgridConfig = "testgrid_config_header.yaml"
+
+	// testGroupTemplate is the template for the test group config
+	testGroupTemplate = "testgrid_testgroup.yaml"
+
+	// dashboardTabTemplate is the template for the dashboard tab config
+	dashboardTabTemplate = "testgrid_dashboardtab.yaml"
+
+	// dashboardGroupTemplate is the template for the dashboard tab config
+	dashboardGroupTemplate = "testgrid_dashboardgroup.yaml"
+)
+
+var (
+	// releasedProjRegexp matches the name of a released project, i.e. the org name followed by the release version
+	releasedProjRegexp = regexp.MustCompile(`^(.+)-([0-9\.]+)$`)
+)
+
+// baseTestgridTemplateData contains basic data about the testgrid config file.
+// TODO(Fredy-Z): remove this structure and use baseProwJobTemplateData instead
+type baseTestgridTemplateData struct {
+	TestGroupName string
+	Year          int
+}
+
+// testGroupTemplateData contains data about a test group
+type testGroupTemplateData struct {
+	Base baseTestgridTemplateData
+	// TODO(Fredy-Z): use baseProwJobTemplateData then this attribute can be removed
+	GcsLogDir string
+	Extras    map[string]string
+}
+
+// dashboardTabTemplateData contains data about a dashboard tab
+type dashboardTabTemplateData struct {
+	Base        baseTestgridTemplateData
+	Name        string
+	BaseOptions string
+	AlertEmails string
+	Extras      map[string]string
+}
+
+// dashboardGroupTemplateData contains data about a dashboard group
+type dashboardGroupTemplateData struct {
+	Name      string
+	RepoNames []string
+}
+
+// testgridEntityGenerator is a function that generates the entity given the repo name and job names
+type testgridEntityGenerator func(string, string, []string)
+
+// newBaseTestgridTemplateData returns a testgridTemplateData type with its initial, default values.
+func newBaseTestgridTemplateData(testGroupName string) baseTestgridTemplateData {
+	var data baseTestgridTemplateData
+	data.Year = time.Now().Year()
+	data.TestGroupName = testGroupName
+	return data
+}
+
+// generateTestGridSection generates the configs for a TestGrid section using the given generator
+func (g *generator) generateTestGridSection(sectionName string, generator testgridEntityGenerator, skipReleasedProj bool) {
+	g.outputConfig(sectionName + ":")
+	for _, projName := range g.projNames {
+		// Do not handle the project if it is released and we want to skip it.
+		if skipReleasedProj && isReleased(projName) {
+			continue
+		}
+		repos := g.metaData[projName]
+		for _, repoName := range g.repoNames {
+			if jobNames, exists := repos[repoName]; exists {
+				generator(projName, repoName, jobNames)
+			}
+		}
+	}
+}
+
+// generateTestGroup generates the test group configuration
+func (g *generator) generateTestGroup(projName string, repoName string, jobNames []string) {
+	projRepoStr := buildProjRepoStr(projName, repoName)
+	orgName, _ := splitProjName(projName)
+	settings := g.getOrgSettings(orgName)
+	for _, jobName := range jobNames {
+		testGroupName := getTestGroupName(projRepoStr, jobName)
+		gcsLogDir := fmt.Sprintf("%s/%s/%s", settings.GcsBucket, settings.LogsDir, testGroupName)
+		extras := make(map[string]string)
+		switch jobName {
+		case "continuous", "dot-release", "auto-release", "performance", "performance-mesh", "latency", "nightly":
+			isDailyBranch := regexp.MustCompile(`-[0-9\.]+-continuous`).FindString(testGroupName) != ""
+			if !isDailyBranch && (jobName == "continuous" || jobName == "auto-release") {
+				// TODO(Fredy-Z): this value should be derived from the cron string
+				extras["alert_stale_results_hours"] = "3"
+				if jobName == "continuous" {
+					// For continuous flows, alert after 3 failures due to flakiness
+					extras["num_failures_to_alert"] = "3"
+				}
+			}
+			if jobName == "dot-release" {
+				// TODO(Fredy-Z): this value should be derived from the cron string
+				extras["alert_stale_results_hours"] = "170" // 1 week + 2h
+			}
+			if jobName == "latency" {
+				extras["short_text_metric"] = "latency"
+			}
+			if jobName == "performance" || jobName == "performance-mesh" {
+				extras["short_text_metric"] = "perf_latency"
+			}
+		case "test-coverage":
+			gcsLogDir = strings.ToLower(fmt.Sprintf("%s/%s/ci-%s-%s", settings.GcsBucket, settings.LogsDir, projRepoStr, "go-coverage"))
+			extras["short_text_metric"] = "coverage"
+			// Do not alert on coverage failures (i.e., coverage below threshold)
+			extras["num_failures_to_alert"] = "9999"
+		case "istio-1.0-mesh", "istio-1.0-no-mesh", "istio-1.1-mesh", "istio-1.1-no-mesh", "istio-1.2-mesh", "istio-1.2-no-mesh":
+			extras["alert_stale_results_hours"] = "3"
+			extras["num_failures_to_alert"] = "3"
+		default:
+			fatalf("unknown jobName for generateTestGroup: %s", jobName)
+		}
+		g.executeTestGroupTemplate(testGroupName, gcsLogDir, extras)
+	}
+}
+
+// executeTestGroupTemplate outputs the given test group config template with the given data
+// The alerting settings of the test group override the given ones.
+func (g *generator) executeTestGroupTemplate(testGroupName string, gcsLogDir string, extras map[string]string) {
+	var data testGroupTemplateData
+	data.Base.TestGroupName = testGroupName
+	data.GcsLogDir = gcsLogDir
+	data.Extras = make(map[string]string)
+	for key, value := range extras {
+		data.Extras[key] = value
+	}
+	alert := g.testGroupAlerts[testGroupName]
+	if alert.FailuresToAlert > 0 {
+		data.Extras["num_failures_to_alert"] = strconv.Itoa(alert.FailuresToAlert)
+	}
+	if alert.StaleResultsHours > 0 {
+		data.Extras["alert_stale_results_hours"] = strconv.Itoa(alert.StaleResultsHours)
+	}
+	g.executeTemplate("test group", g.readTemplate(testGroupTemplate), data)
+}
+
+// generateDashboard generates the dashboard configuration
+func (g *generator) generateDashboard(projName string, repoName string, jobNames []string) {
+	projRepoStr := buildProjRepoStr(projName, repoName)
+	g.outputDashboard(g.getDashboardName(projName, repoName), projName+"/"+repoName)
+	noExtras := make(map[string]string)
+	for _, jobName := range jobNames {
+		testGroupName := getTestGroupName(projRepoStr, jobName)
+		switch jobName {
+		case "continuous":
+			g.executeDashboardTabTemplate("continuous", testGroupName, testgridTabSortByName, noExtras)
+			// This is a special case for knative/serving, as conformance tab is just a filtered view of the continuous tab.
+			if projRepoStr == "knative-serving" {
+				g.executeDashboardTabTemplate("conformance", testGroupName, "include-filter-by-regex=test/conformance/&sort-by-name=", noExtras)
+			}
+		case "dot-release", "auto-release", "performance", "performance-mesh", "latency":
+			extras := make(map[string]string)
+			baseOptions := testgridTabSortByName
+			if jobName == "performance" || jobName == "performance-mesh" {
+				baseOptions = testgridTabGroupByTarget
+			}
+			if jobName == "latency" {
+				baseOptions = testgridTabGroupByDir
+				extras["description"] = "95% latency in ms"
+			}
+			g.executeDashboardTabTemplate(jobName, testGroupName, baseOptions, extras)
+		case "nightly":
+			g.executeDashboardTabTemplate("nightly", testGroupName, testgridTabSortByName, noExtras)
+		case "test-coverage":
+			g.executeDashboardTabTemplate("coverage", testGroupName, testgridTabGroupByDir, noExtras)
+		case "istio-1.0-mesh", "istio-1.0-no-mesh", "istio-1.1-mesh", "istio-1.1-no-mesh", "istio-1.2-mesh", "istio-1.2-no-mesh":
+			g.executeDashboardTabTemplate(jobName, testGroupName, testgridTabSortByName, noExtras)
+		default:
+			fatalf("unknown job name %q", jobName)
+		}
+	}
+}
+
+// executeTestGroupTemplate outputs the given dashboard tab config template with the given data
+func (g *generator) executeDashboardTabTemplate(dashboardTabName string, testGroupName string, baseOptions string, extras map[string]string) {
+	var data dashboardTabTemplateData
+	data.Name = dashboardTabName
+	data.Base.TestGroupName = testGroupName
+	data.BaseOptions = baseOptions
+	data.AlertEmails = strings.Join(g.testGroupAlerts[testGroupName].Emails, ",")
+	data.Extras = extras
+	g.executeTemplate("dashboard tab", g.readTemplate(dashboardTabTemplate), data)
+}
+
+// parseRepoAlerts returns the default alerting settings of the repositories (in the org/repo form), set in their repo-settings.
+func (g *generator) parseRepoAlerts(presubmitRepos presubmitRepos) map[string]alertConfig {
+	alerts := make(map[string]alertConfig)
+	for _, repo := range presubmitRepos {
+		for _, job := range repo.Jobs {
+			if job.Type == "repo-settings" {
+				alerts[repo.Name] = mergeAlertConfig(alerts[repo.Name], expandJobConfig(job.jobConfig, g.config.Templates).Alert)
+			}
+		}
+	}
+	return alerts
+}
+
+// addTestGroupAlert saves the alerting settings of the test group of the given periodic job, merged with the ones of its repository.
+func (g *generator) addTestGroupAlert(projName, repoName, jobName string, job jobConfig) {
+	orgName, _ := splitProjName(projName)
+	alert := mergeAlertConfig(g.repoAlerts[orgName+"/"+repoName], expandJobConfig(job, g.config.Templates).Alert)
+	if !reflect.DeepEqual(alert, alertConfig{}) {
+		g.testGroupAlerts[getTestGroupName(buildProjRepoStr(projName, repoName), jobName)] = alert
+	}
+}
+
+// getDashboardName returns the name of the dashboard of the given repo, prefixed as set for its org to avoid clashes between orgs
+func (g *generator) getDashboardName(projName string, repoName string) string {
+	orgName, _ := splitProjName(projName)
+	return g.getOrgSettings(orgName).TestgridDashboardPrefix + strings.ToLower(repoName)
+}
+
+// getTestGroupName get the testGroupName from the given repoName and jobName
+func getTestGroupName(repoName string, jobName string) string {
+	switch jobName {
+	case "continuous", "dot-release", "auto-release", "performance", "performance-mesh", "latency":
+		return strings.ToLower(fmt.Sprintf("ci-%s-%s", repoName, jobName))
+	case "nightly":
+		return strings.ToLower(fmt.Sprintf("ci-%s-%s-release", repoName, jobName))
+	case "test-coverage":
+		return strings.ToLower(fmt.Sprintf("pull-%s-%s", repoName, jobName))
+	case "istio-1.0-mesh", "istio-1.0-no-mesh", "istio-1.1-mesh", "istio-1.1-no-mesh", "istio-1.2-mesh", "istio-1.2-no-mesh":
+		return strings.ToLower(fmt.Sprintf("ci-%s-%s", repoName, jobName))
+	}
+	fatalf("unknown jobName for getTestGroupName: %s", jobName)
+	return ""
+}
+
+// getPresubmitTestGroupName returns the testGroupName of the given presubmit job, i.e. the name of the Prow job
+func getPresubmitTestGroupName(projName string, repoName string, jobName string) string {
+	return strings.ToLower(fmt.Sprintf("pull-%s-%s", buildProjRepoStr(projName, repoName), jobName))
+}
+
+// getPresubmitDashboardName returns the name of the dashboard with the presubmit jobs of the given repo
+func (g *generator) getPresubmitDashboardName(projName string, repoName string) string {
+	return g.getDashboardName(projName, repoName) + "-presubmits"
+}
+
+// generatePresubmitTestGroups generates the test groups configuration of the presubmit jobs, reading the results from the pull requests logs
+func (g *generator) generatePresubmitTestGroups() {
+	for _, projName := range g.projNames {
+		settings := g.getOrgSettings(projName)
+		repos := g.presubmitMetaData[projName]
+		for _, repoName := range g.repoNames {
+			for _, jobName := range repos[repoName] {
+				testGroupName := getPresubmitTestGroupName(projName, repoName, jobName)
+				gcsLogDir := fmt.Sprintf("%s/%s/directory/%s", settings.GcsBucket, settings.PresubmitLogsDir, testGroupName)
+				extras := make(map[string]string)
+				// Pull requests are expected to break the jobs, and the jobs only run when pull requests are updated,
+				// so do not alert on failures or stale results.
+				extras["num_failures_to_alert"] = "9999"
+				extras["alert_stale_results_hours"] = "0"
+				g.executeTestGroupTemplate(testGroupName, gcsLogDir, extras)
+			}
+		}
+	}
+}
+
+// outputDashboard starts the config of the dashboard with the given name, showing the given repo or release.
+// Dashboard names must be unique, repos with the same name in different orgs need a testgrid-dashboard-prefix.
+func (g *generator) outputDashboard(name, what string) {
+	if other, exists := g.dashboards[name]; exists {
+		fatalf("dashboard %q of %s clashes with the one of %s, set a testgrid-dashboard-prefix for their org", name, what, other)
+	}
+	g.dashboards[name] = what
+	g.outputConfig("- name: " + name + "\n" + baseIndent + "dashboard_tab:")
+}
+
+// generatePresubmitDashboards generates the dashboards configuration of the presubmit jobs, one for each repo
+func (g *generator) generatePresubmitDashboards() {
+	noExtras := make(map[string]string)
+	for _, projName := range g.projNames {
+		repos := g.presubmitMetaData[projName]
+		for _, repoName := range g.repoNames {
+			jobNames, exists := repos[repoName]
+			if !exists {
+				continue
+			}
+			g.outputDashboard(g.getPresubmitDashboardName(projName, repoName), "presubmits of "+projName+"/"+repoName)
+			for _, jobName := range jobNames {
+				g.executeDashboardTabTemplate(jobName, getPresubmitTestGroupName(projName, repoName, jobName), testgridTabSortByName, noExtras)
+			}
+		}
+	}
+}
+
+// generateDashboardsForReleases generates a dashboard for each release, with the continuous jobs of the release branches.
+// The releases are sorted by version, as their jobs may be listed in any order (or generated by ExpandReleaseBranches).
+func (g *generator) generateDashboardsForReleases() {
+	for _, projName := range sortReleasedProjNames(g.projNames) {
+		repos := g.metaData[projName]
+		g.outputDashboard(projName, "release "+projName)
+		noExtras := make(map[string]string)
+		for _, repoName := range g.repoNames {
+			if _, exists := repos[repoName]; exists {
+				testGroupName := getTestGroupName(buildProjRepoStr(projName, repoName), "continuous")
+				g.executeDashboardTabTemplate(repoName, testGroupName, testgridTabSortByName, noExtras)
+			}
+		}
+	}
+}
+
+// generateDashboardGroups generates the dashboard groups configuration
+func (g *generator) generateDashboardGroups() {
+	g.outputConfig("dashboard_groups:")
+	for _, projName := range g.projNames {
+		// there is only one dashboard for each released project, so we do not need to group them
+		if isReleased(projName) {
+			continue
+		}
+
+		dashboardRepoNames := make([]string, 0)
+		repos := g.metaData[projName]
+		for _, repoName := range g.repoNames {
+			if _, exists := repos[repoName]; exists {
+				dashboardRepoNames = append(dashboardRepoNames, g.getDashboardName(projName, repoName))
+			}
+			if _, exists := g.presubmitMetaData[projName][repoName]; exists {
+				dashboardRepoNames = append(dashboardRepoNames, g.getPresubmitDashboardName(projName, repoName))
+			}
+		}
+		g.executeDashboardGroupTemplate(projName, dashboardRepoNames)
+	}
+}
+
+// executeDashboardGroupTemplate outputs the given dashboard group config template with the given data
+func (g *generator) executeDashboardGroupTemplate(dashboardGroupName string, dashboardRepoNames []string) {
+	var data dashboardGroupTemplateData
+	data.Name = dashboardGroupName
+	data.RepoNames = dashboardRepoNames
+	g.executeTemplate("dashboard group", g.readTemplate(dashboardGroupTemplate), data)
+}
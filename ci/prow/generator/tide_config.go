@@ -0,0 +1,177 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// merge requirements (Tide and branch protection) derived from the presubmit jobs
+
+package generator
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// tideMergeConfig is the template for the Tide and branch protection config.
+	tideMergeConfig = "prow_tide_config.yaml"
+
+	// defaultBranch is the branch the jobs run against unless they restrict their branches.
+	defaultBranch = "master"
+)
+
+var (
+	// literalBranchRegexp matches the branches of the jobs naming a single branch, without regular expression syntax
+	// (other than dots, that match themselves too), as only those can be protected.
+	literalBranchRegexp = regexp.MustCompile(`^[\w./-]+$`)
+)
+
+// tideTemplateData contains the data of the Tide and branch protection config template.
+type tideTemplateData struct {
+	Orgs  []protectedOrg
+	Repos []string
+}
+
+// protectedOrg contains the protected repositories of a GitHub organization.
+type protectedOrg struct {
+	Name  string
+	Repos []protectedRepo
+}
+
+// protectedRepo contains the contexts required on all branches of a repository, and the ones only required on some branches.
+// As in the branch protection config, the contexts of a branch are added to the ones of its repository.
+type protectedRepo struct {
+	Name     string
+	Contexts []string
+	Branches []protectedBranch
+}
+
+// protectedBranch contains the contexts required on a branch in addition to the ones of its repository.
+type protectedBranch struct {
+	Name     string
+	Contexts []string
+}
+
+// newTideTemplateData returns the merge requirements of the repositories with the given jobs and the given extra repositories.
+// The contexts of the presubmit jobs always running and not optional are required on the branches the jobs run against.
+// Branches not named by any job require the contexts common to all branches.
+// Only the repositories of the given protected orgs get branch protection, the whole org being protected.
+func newTideTemplateData(jobs []Job, extraRepos []string, protectedOrgs []string) tideTemplateData {
+	var data tideTemplateData
+	for _, job := range jobs {
+		if job.Kind == "presubmit" && !strExists(data.Repos, job.RepoName) {
+			data.Repos = append(data.Repos, job.RepoName)
+		}
+	}
+	for _, repoName := range extraRepos {
+		if !strExists(data.Repos, repoName) {
+			data.Repos = append(data.Repos, repoName)
+		}
+	}
+
+	for _, orgName := range protectedOrgs {
+		data.Orgs = append(data.Orgs, protectedOrg{Name: orgName})
+	}
+	for _, repoName := range data.Repos {
+		var required []Job
+		for _, job := range jobs {
+			if job.Kind == "presubmit" && job.RepoName == repoName && job.AlwaysRun && !job.Optional {
+				required = append(required, job)
+			}
+		}
+		parts := strings.SplitN(repoName, "/", 2)
+		repo := protectedRepo{Name: parts[1]}
+		repo.Contexts, repo.Branches = requiredContexts(required)
+		if len(repo.Contexts) == 0 && len(repo.Branches) == 0 {
+			// Protected by its org, without required contexts.
+			continue
+		}
+		for i := range data.Orgs {
+			if data.Orgs[i].Name == parts[0] {
+				data.Orgs[i].Repos = append(data.Orgs[i].Repos, repo)
+			}
+		}
+	}
+	return data
+}
+
+// requiredContexts returns the contexts of the given jobs required on all branches, and the ones only required on some branches.
+// Jobs whose branches are given by regular expressions are only required on the branches named by the jobs they run against,
+// as the branches they match can't be listed.
+func requiredContexts(jobs []Job) ([]string, []protectedBranch) {
+	branches := []string{defaultBranch}
+	var matchingJobs []string
+	for _, job := range jobs {
+		for _, branch := range append(append([]string(nil), job.Branches...), job.SkipBranches...) {
+			if !literalBranchRegexp.MatchString(branch) {
+				if !strExists(matchingJobs, job.Name) {
+					matchingJobs = append(matchingJobs, job.Name)
+				}
+			} else if !strExists(branches, branch) {
+				branches = append(branches, branch)
+			}
+		}
+	}
+	sort.Strings(branches)
+	contexts := make(map[string][]string)
+	for _, branch := range branches {
+		for _, job := range jobs {
+			if RunsOnBranch(job.Branches, job.SkipBranches, branch) && !strExists(contexts[branch], job.Name) {
+				contexts[branch] = append(contexts[branch], job.Name)
+			}
+		}
+	}
+
+	var common []string
+	for _, context := range contexts[defaultBranch] {
+		isCommon := !strExists(matchingJobs, context)
+		for _, branch := range branches {
+			isCommon = isCommon && strExists(contexts[branch], context)
+		}
+		if isCommon {
+			common = append(common, context)
+		}
+	}
+	var res []protectedBranch
+	for _, branch := range branches {
+		var extra []string
+		for _, context := range contexts[branch] {
+			if !strExists(common, context) {
+				extra = append(extra, context)
+			}
+		}
+		if len(extra) > 0 {
+			res = append(res, protectedBranch{Name: branch, Contexts: extra})
+		}
+	}
+	return common, res
+}
+
+// RunsOnBranch returns true if a job with the given branches and skipped branches runs against the given branch.
+// As in Prow, the branches are regular expressions.
+func RunsOnBranch(branches, skipBranches []string, branch string) bool {
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if re, err := regexp.Compile("^(?:" + pattern + ")$"); err == nil && re.MatchString(branch) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(branches) > 0 {
+		return matches(branches)
+	}
+	return !matches(skipBranches)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// tide_config_test.go contains unit tests for the merge requirements derived from the presubmit jobs
+
+package generator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewTideTemplateData(t *testing.T) {
+	unit := newLintedJob("presubmit", "knative/serving", "pull-knative-serving-unit-tests")
+	legacyUnit := unit
+	legacyUnit.Branches = []string{"release-0.4"}
+	unit.SkipBranches = []string{"release-0.4"}
+	smoke := newLintedJob("presubmit", "knative/serving", "pull-knative-serving-smoke-tests")
+	smoke.SkipBranches = []string{"release-0.4", "release-0.5"}
+	legacy := newLintedJob("presubmit", "knative/serving", "pull-knative-serving-legacy-tests")
+	legacy.Branches = []string{"release-0.5"}
+	perf := newLintedJob("presubmit", "knative/serving", "pull-knative-serving-perf-tests")
+	perf.AlwaysRun = false
+	coverage := newLintedJob("presubmit", "knative/serving", "pull-knative-serving-go-coverage")
+	coverage.Optional = true
+	build := newLintedJob("presubmit", "knative/build", "pull-knative-build-unit-tests")
+	buildIntegration := newLintedJob("presubmit", "knative/build", "pull-knative-build-integration-tests")
+	buildIntegration.SkipBranches = []string{"release-.*"}
+	other := newLintedJob("presubmit", "google/foo", "pull-google-foo-unit-tests")
+	periodic := newLintedJob("periodic", "knative/pkg", "ci-knative-pkg-continuous")
+
+	// Only knative is protected, and its repositories without required contexts are protected by the org only.
+	// Jobs skipping branches given by a regular expression are only required on the branches that are named.
+	jobs := []Job{legacyUnit, unit, smoke, legacy, perf, coverage, build, buildIntegration, other, periodic}
+	data := newTideTemplateData(jobs, []string{"knative/website", "knative/build"}, []string{"knative"})
+	expected := tideTemplateData{
+		Orgs: []protectedOrg{
+			{
+				Name: "knative",
+				Repos: []protectedRepo{
+					{
+						Name:     "serving",
+						Contexts: []string{"pull-knative-serving-unit-tests"},
+						Branches: []protectedBranch{
+							{Name: "master", Contexts: []string{"pull-knative-serving-smoke-tests"}},
+							{Name: "release-0.5", Contexts: []string{"pull-knative-serving-legacy-tests"}},
+						},
+					},
+					{
+						Name:     "build",
+						Contexts: []string{"pull-knative-build-unit-tests"},
+						Branches: []protectedBranch{
+							{Name: "master", Contexts: []string{"pull-knative-build-integration-tests"}},
+						},
+					},
+				},
+			},
+		},
+		Repos: []string{"knative/serving", "knative/build", "google/foo", "knative/website"},
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Expected merge requirements:\n%+v\ngot:\n%+v", expected, data)
+	}
+}
@@ -0,0 +1,456 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// running a generated Prow job locally in Docker
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knative/test-infra/ci/prow/generator"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// runJobCommand is the subcommand running a job locally.
+	runJobCommand = "run-job"
+
+	// prowGoPath is the GOPATH of the test pods, where Prow clones the repository under test.
+	prowGoPath = "/home/prow/go"
+
+	// prowArtifactsDir is the dir where the jobs write the artifacts that Prow uploads to GCS.
+	prowArtifactsDir = "/logs/artifacts"
+)
+
+var (
+	// shellSafeRegexp matches the strings that don't need quoting in a shell command.
+	shellSafeRegexp = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,-]+$`)
+)
+
+// prowConfig contains the parts of a generated Prow config needed to run a job locally.
+type prowConfig struct {
+	Plank struct {
+		DefaultDecorationConfig prowDecorationConfig `yaml:"default_decoration_config"`
+	} `yaml:"plank"`
+	Presubmits  map[string][]prowJob `yaml:"presubmits"`
+	Postsubmits map[string][]prowJob `yaml:"postsubmits"`
+	Periodics   []prowJob            `yaml:"periodics"`
+}
+
+// prowDecorationConfig contains the timeouts of a job.
+type prowDecorationConfig struct {
+	Timeout     prowDuration `yaml:"timeout"`
+	GracePeriod prowDuration `yaml:"grace_period"`
+}
+
+// prowDuration is a duration given either in nanoseconds or as a string like "2h".
+type prowDuration time.Duration
+
+// prowJob is a job of a generated Prow config.
+type prowJob struct {
+	Name             string                `yaml:"name"`
+	PathAlias        string                `yaml:"path_alias"`
+	Branches         []string              `yaml:"branches"`
+	SkipBranches     []string              `yaml:"skip_branches"`
+	ExtraRefs        []prowRef             `yaml:"extra_refs"`
+	DecorationConfig *prowDecorationConfig `yaml:"decoration_config"`
+	Spec             struct {
+		Containers []prowContainer `yaml:"containers"`
+		Volumes    []prowVolume    `yaml:"volumes"`
+	} `yaml:"spec"`
+}
+
+// prowRef is a repository cloned by Prow for a periodic job.
+type prowRef struct {
+	Org       string `yaml:"org"`
+	Repo      string `yaml:"repo"`
+	BaseRef   string `yaml:"base_ref"`
+	PathAlias string `yaml:"path_alias"`
+}
+
+// prowContainer is the container running a job.
+type prowContainer struct {
+	Image           string            `yaml:"image"`
+	Command         []string          `yaml:"command"`
+	Args            []string          `yaml:"args"`
+	Env             []prowEnvVar      `yaml:"env"`
+	VolumeMounts    []prowVolumeMount `yaml:"volumeMounts"`
+	SecurityContext struct {
+		Privileged bool `yaml:"privileged"`
+	} `yaml:"securityContext"`
+}
+
+// prowEnvVar is an environment variable of a job container.
+type prowEnvVar struct {
+	Name      string      `yaml:"name"`
+	Value     string      `yaml:"value"`
+	ValueFrom interface{} `yaml:"valueFrom"`
+}
+
+// prowVolumeMount is a volume mounted in a job container.
+type prowVolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+}
+
+// prowVolume is a volume of a job pod.
+type prowVolume struct {
+	Name   string `yaml:"name"`
+	Secret *struct {
+		SecretName string `yaml:"secretName"`
+	} `yaml:"secret"`
+	EmptyDir interface{} `yaml:"emptyDir"`
+}
+
+// localJob is a job resolved from the Prow config, with the repository it tests.
+type localJob struct {
+	Name        string
+	Type        string
+	Org         string
+	Repo        string
+	BaseRef     string
+	PathAlias   string
+	Container   prowContainer
+	Volumes     []prowVolume
+	Timeout     time.Duration
+	GracePeriod time.Duration
+}
+
+// localRunOptions contains the local resources replacing the ones of the Prow cluster.
+type localRunOptions struct {
+	Checkout      string
+	SecretsDir    string
+	ArtifactsDir  string
+	BuildID       string
+	ContainerName string
+}
+
+// UnmarshalYAML decodes a duration given either in nanoseconds or as a string.
+func (d *prowDuration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var ns int64
+	if err := unmarshal(&ns); err == nil {
+		*d = prowDuration(ns)
+		return nil
+	}
+	var str string
+	if err := unmarshal(&str); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = prowDuration(duration)
+	return nil
+}
+
+// readProwConfig reads the given Prow config, adding the jobs of the job config files found in jobsDir, if set.
+func readProwConfig(fileName, jobsDir string) (prowConfig, error) {
+	var config prowConfig
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("cannot parse %q: %v", fileName, err)
+	}
+	if jobsDir == "" {
+		return config, nil
+	}
+	err = filepath.Walk(jobsDir, func(fileName string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(fileName) != ".yaml" {
+			return err
+		}
+		content, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+		var jobs prowConfig
+		if err := yaml.Unmarshal(content, &jobs); err != nil {
+			return fmt.Errorf("cannot parse %q: %v", fileName, err)
+		}
+		if config.Presubmits == nil {
+			config.Presubmits = make(map[string][]prowJob)
+		}
+		if config.Postsubmits == nil {
+			config.Postsubmits = make(map[string][]prowJob)
+		}
+		for repo, repoJobs := range jobs.Presubmits {
+			config.Presubmits[repo] = append(config.Presubmits[repo], repoJobs...)
+		}
+		for repo, repoJobs := range jobs.Postsubmits {
+			config.Postsubmits[repo] = append(config.Postsubmits[repo], repoJobs...)
+		}
+		config.Periodics = append(config.Periodics, jobs.Periodics...)
+		return nil
+	})
+	return config, err
+}
+
+// findLocalJob returns the job with the given name, running against the given branch for presubmit and postsubmit jobs.
+func findLocalJob(config prowConfig, jobName, branch string) (localJob, error) {
+	var found []localJob
+	addRepoJobs := func(jobType string, jobs map[string][]prowJob) {
+		for repoName, repoJobs := range jobs {
+			for _, job := range repoJobs {
+				if job.Name != jobName || !generator.RunsOnBranch(job.Branches, job.SkipBranches, branch) {
+					continue
+				}
+				parts := strings.SplitN(repoName, "/", 2)
+				if len(parts) != 2 {
+					continue
+				}
+				found = append(found, newLocalJob(config, job, jobType, prowRef{Org: parts[0], Repo: parts[1], BaseRef: branch, PathAlias: job.PathAlias}))
+			}
+		}
+	}
+	addRepoJobs("presubmit", config.Presubmits)
+	addRepoJobs("postsubmit", config.Postsubmits)
+	for _, job := range config.Periodics {
+		if job.Name != jobName {
+			continue
+		}
+		if len(job.ExtraRefs) == 0 {
+			return localJob{}, fmt.Errorf("periodic job %q doesn't test a repository", jobName)
+		}
+		found = append(found, newLocalJob(config, job, "periodic", job.ExtraRefs[0]))
+	}
+
+	switch {
+	case len(found) == 0:
+		return localJob{}, fmt.Errorf("no job %q running against branch %q", jobName, branch)
+	case len(found) > 1:
+		return localJob{}, fmt.Errorf("%d jobs %q run against branch %q", len(found), jobName, branch)
+	case found[0].Container.Image == "":
+		return localJob{}, fmt.Errorf("job %q must have a single container", jobName)
+	}
+	return found[0], nil
+}
+
+// newLocalJob returns the local job for the given Prow job, testing the given repository.
+func newLocalJob(config prowConfig, job prowJob, jobType string, ref prowRef) localJob {
+	local := localJob{
+		Name:        job.Name,
+		Type:        jobType,
+		Org:         ref.Org,
+		Repo:        ref.Repo,
+		BaseRef:     ref.BaseRef,
+		PathAlias:   ref.PathAlias,
+		Volumes:     job.Spec.Volumes,
+		Timeout:     time.Duration(config.Plank.DefaultDecorationConfig.Timeout),
+		GracePeriod: time.Duration(config.Plank.DefaultDecorationConfig.GracePeriod),
+	}
+	if local.PathAlias == "" {
+		local.PathAlias = path.Join("github.com", ref.Org, ref.Repo)
+	}
+	if len(job.Spec.Containers) == 1 {
+		local.Container = job.Spec.Containers[0]
+	}
+	if job.DecorationConfig != nil && job.DecorationConfig.Timeout != 0 {
+		local.Timeout = time.Duration(job.DecorationConfig.Timeout)
+	}
+	if job.DecorationConfig != nil && job.DecorationConfig.GracePeriod != 0 {
+		local.GracePeriod = time.Duration(job.DecorationConfig.GracePeriod)
+	}
+	return local
+}
+
+// dockerRunArgs returns the arguments of "docker run" running the given job like in a Prow test pod.
+// The secrets are mounted from the dirs named after them in the secrets dir, the other volumes are ignored.
+func dockerRunArgs(job localJob, options localRunOptions) ([]string, error) {
+	workDir := path.Join(prowGoPath, "src", job.PathAlias)
+	args := []string{"run", "--rm", "--name", options.ContainerName,
+		"-v", options.Checkout + ":" + workDir, "-w", workDir}
+	if job.Container.SecurityContext.Privileged {
+		args = append(args, "--privileged")
+	}
+	if options.ArtifactsDir != "" {
+		args = append(args, "-v", options.ArtifactsDir+":"+prowArtifactsDir)
+	}
+
+	// Environment set by Prow, then the job one.
+	env := []string{"CI=true", "GOPATH=" + prowGoPath, "ARTIFACTS=" + prowArtifactsDir,
+		"JOB_NAME=" + job.Name, "JOB_TYPE=" + job.Type, "BUILD_ID=" + options.BuildID,
+		"REPO_OWNER=" + job.Org, "REPO_NAME=" + job.Repo, "PULL_BASE_REF=" + job.BaseRef}
+	for _, v := range job.Container.Env {
+		if v.ValueFrom != nil {
+			log.Printf("Ignoring environment variable %q of job %q, its value comes from the cluster", v.Name, job.Name)
+			continue
+		}
+		env = append(env, v.Name+"="+v.Value)
+	}
+	for _, v := range env {
+		args = append(args, "-e", v)
+	}
+
+	for _, mount := range job.Container.VolumeMounts {
+		var volume *prowVolume
+		for i := range job.Volumes {
+			if job.Volumes[i].Name == mount.Name {
+				volume = &job.Volumes[i]
+			}
+		}
+		switch {
+		case volume == nil:
+			return nil, fmt.Errorf("job %q mounts the unknown volume %q", job.Name, mount.Name)
+		case volume.Secret != nil && options.SecretsDir == "":
+			log.Printf("Not mounting secret %q in %q, no secrets dir given", volume.Secret.SecretName, mount.MountPath)
+		case volume.Secret != nil:
+			secretDir := filepath.Join(options.SecretsDir, volume.Secret.SecretName)
+			if _, err := os.Stat(secretDir); err != nil {
+				return nil, fmt.Errorf("secret %q of job %q is expected in %q: %v", volume.Secret.SecretName, job.Name, secretDir, err)
+			}
+			args = append(args, "-v", secretDir+":"+mount.MountPath+":ro")
+		case volume.EmptyDir != nil:
+			args = append(args, "-v", mount.MountPath)
+		default:
+			log.Printf("Not mounting volume %q in %q, only secrets and empty dirs are supported", mount.Name, mount.MountPath)
+		}
+	}
+
+	if len(job.Container.Command) > 0 {
+		args = append(args, "--entrypoint", job.Container.Command[0], job.Container.Image)
+		args = append(args, job.Container.Command[1:]...)
+	} else {
+		args = append(args, job.Container.Image)
+	}
+	return append(args, job.Container.Args...), nil
+}
+
+// shellCommand returns the given command with its arguments quoted for a shell.
+func shellCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = arg
+		if !shellSafeRegexp.MatchString(arg) {
+			quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// runDocker runs docker with the given arguments, stopping the container after the timeout like Prow does.
+// Returns the exit code of docker.
+func runDocker(args []string, containerName string, timeout, gracePeriod time.Duration) int {
+	cmd := exec.Command("docker", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		log.Fatalf("Cannot run docker: %v", err)
+	}
+	// Closed by the timer, so that the exit code can be checked without racing with it.
+	timedOut := make(chan struct{})
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			close(timedOut)
+			log.Printf("Job timed out after %v, stopping it", timeout)
+			stop := exec.Command("docker", "stop", "--time", strconv.Itoa(int(gracePeriod.Seconds())), containerName)
+			if err := stop.Run(); err != nil {
+				log.Printf("Cannot stop container %q: %v", containerName, err)
+			}
+		})
+		defer timer.Stop()
+	}
+	err := cmd.Wait()
+	select {
+	case <-timedOut:
+		return 1
+	default:
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(interface{ ExitStatus() int }); ok {
+			return status.ExitStatus()
+		}
+		return 1
+	}
+	if err != nil {
+		log.Fatalf("Cannot run docker: %v", err)
+	}
+	return 0
+}
+
+// runJob is the entry point of the run-job subcommand, which runs a generated job locally in Docker.
+func runJob(args []string) {
+	flags := flag.NewFlagSet(runJobCommand, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [flags] <job name> <repository checkout>\n", runJobCommand)
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", "config.yaml", "The generated Prow config containing the job")
+	jobsDir := flags.String("jobs-dir", "", "If set, the dir of the job config files generated with --prow-jobs-output-dir, also searched for the job")
+	branch := flags.String("branch", "master", "The branch tested by the presubmit or postsubmit job, to choose between the jobs with the same name")
+	secretsDir := flags.String("secrets-dir", "", "Dir containing a subdir per secret used by the job (e.g., test-account/service-account.json), mounted instead of the cluster secrets")
+	artifactsDir := flags.String("artifacts-dir", "", "If set, the dir where the job writes its artifacts")
+	run := flags.Bool("run", false, "Whether to run the job instead of printing the docker command")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	config, err := readProwConfig(*configFile, *jobsDir)
+	if err != nil {
+		log.Fatalf("Cannot read Prow config: %v", err)
+	}
+	job, err := findLocalJob(config, flags.Arg(0), *branch)
+	if err != nil {
+		log.Fatalf("Cannot find job: %v", err)
+	}
+	options := localRunOptions{
+		Checkout:     flags.Arg(1),
+		SecretsDir:   *secretsDir,
+		ArtifactsDir: *artifactsDir,
+		BuildID:      strconv.FormatInt(time.Now().Unix(), 10),
+	}
+	options.ContainerName = job.Name + "-" + options.BuildID
+	// Docker only mounts absolute paths.
+	for _, dir := range []*string{&options.Checkout, &options.SecretsDir, &options.ArtifactsDir} {
+		if *dir == "" {
+			continue
+		}
+		abs, err := filepath.Abs(*dir)
+		if err != nil {
+			log.Fatalf("Cannot get the absolute path of %q: %v", *dir, err)
+		}
+		*dir = abs
+	}
+	dockerArgs, err := dockerRunArgs(job, options)
+	if err != nil {
+		log.Fatalf("Cannot run job locally: %v", err)
+	}
+
+	if !*run {
+		cmd := append([]string{"docker"}, dockerArgs...)
+		if job.Timeout > 0 {
+			// Give the job the grace period to exit before killing it, like Prow does.
+			cmd = append([]string{"timeout", "-k", fmt.Sprintf("%ds", int(job.GracePeriod.Seconds())), fmt.Sprintf("%ds", int(job.Timeout.Seconds()))}, cmd...)
+		}
+		fmt.Println(shellCommand(cmd))
+		return
+	}
+	os.Exit(runDocker(dockerArgs, options.ContainerName, job.Timeout, job.GracePeriod))
+}
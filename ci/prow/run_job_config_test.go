@@ -0,0 +1,205 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// run_job_config_test.go contains unit tests for running the jobs locally
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const testProwConfig = `
+plank:
+  default_decoration_config:
+    timeout: 7200000000000 # 2h
+    grace_period: 15000000000 # 15s
+presubmits:
+  knative/serving:
+  - name: pull-knative-serving-build-tests
+    branches:
+    - "release-0.4"
+    spec:
+      containers:
+      - image: old-image
+  - name: pull-knative-serving-build-tests
+    path_alias: knative.dev/serving
+    skip_branches:
+    - "release-0.4"
+    spec:
+      containers:
+      - image: new-image
+periodics:
+- name: ci-knative-serving-release
+  decoration_config:
+    timeout: 3h
+  extra_refs:
+  - org: knative
+    repo: serving
+    base_ref: release-0.4
+  spec:
+    containers:
+    - image: image
+- name: ci-knative-cleanup
+  spec:
+    containers:
+    - image: image
+`
+
+var findLocalJobTests = []struct {
+	jobName string
+	branch  string
+	job     localJob
+	err     string
+}{
+	{
+		jobName: "pull-knative-serving-build-tests",
+		branch:  "master",
+		job: localJob{Name: "pull-knative-serving-build-tests", Type: "presubmit", Org: "knative", Repo: "serving", BaseRef: "master",
+			PathAlias: "knative.dev/serving", Container: prowContainer{Image: "new-image"}, Timeout: 2 * time.Hour, GracePeriod: 15 * time.Second},
+	},
+	{
+		jobName: "pull-knative-serving-build-tests",
+		branch:  "release-0.4",
+		job: localJob{Name: "pull-knative-serving-build-tests", Type: "presubmit", Org: "knative", Repo: "serving", BaseRef: "release-0.4",
+			PathAlias: "github.com/knative/serving", Container: prowContainer{Image: "old-image"}, Timeout: 2 * time.Hour, GracePeriod: 15 * time.Second},
+	},
+	{
+		jobName: "ci-knative-serving-release",
+		branch:  "master",
+		job: localJob{Name: "ci-knative-serving-release", Type: "periodic", Org: "knative", Repo: "serving", BaseRef: "release-0.4",
+			PathAlias: "github.com/knative/serving", Container: prowContainer{Image: "image"}, Timeout: 3 * time.Hour, GracePeriod: 15 * time.Second},
+	},
+	{jobName: "ci-knative-cleanup", branch: "master", err: `periodic job "ci-knative-cleanup" doesn't test a repository`},
+	{jobName: "pull-knative-serving-unit-tests", branch: "master", err: `no job "pull-knative-serving-unit-tests" running against branch "master"`},
+}
+
+func TestFindLocalJob(t *testing.T) {
+	var config prowConfig
+	if err := yaml.Unmarshal([]byte(testProwConfig), &config); err != nil {
+		t.Fatalf("Cannot parse test config: %v", err)
+	}
+	for _, test := range findLocalJobTests {
+		job, err := findLocalJob(config, test.jobName, test.branch)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s on %s: expected error %q, got %v", test.jobName, test.branch, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s on %s: unexpected error: %v", test.jobName, test.branch, err)
+			continue
+		}
+		if !reflect.DeepEqual(job, test.job) {
+			t.Errorf("%s on %s: expected job %+v, got %+v", test.jobName, test.branch, test.job, job)
+		}
+	}
+}
+
+func TestReadProwConfigWithJobsDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	if err != nil {
+		t.Fatalf("Cannot create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "config.yaml")
+	jobsDir := filepath.Join(dir, "jobs", "knative", "serving")
+	if err := os.MkdirAll(jobsDir, 0755); err != nil {
+		t.Fatalf("Cannot create jobs dir: %v", err)
+	}
+	files := map[string]string{
+		configFile: "plank:\n  default_decoration_config:\n    timeout: 2h\n",
+		filepath.Join(jobsDir, "serving-presubmits.yaml"): "presubmits:\n  knative/serving:\n  - name: pull-job\n    spec:\n      containers:\n      - image: image\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Cannot write %q: %v", name, err)
+		}
+	}
+	config, err := readProwConfig(configFile, filepath.Join(dir, "jobs"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	job, err := findLocalJob(config, "pull-job", "master")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if job.Timeout != 2*time.Hour {
+		t.Errorf("Expected the timeout of the Prow config, got %v", job.Timeout)
+	}
+}
+
+func TestDockerRunArgs(t *testing.T) {
+	secretsDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatalf("Cannot create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(secretsDir)
+	if err := os.Mkdir(filepath.Join(secretsDir, "test-account"), 0755); err != nil {
+		t.Fatalf("Cannot create secret dir: %v", err)
+	}
+
+	job := localJob{Name: "pull-job", Type: "presubmit", Org: "knative", Repo: "serving", BaseRef: "master", PathAlias: "knative.dev/serving"}
+	job.Container.Image = "image"
+	job.Container.Command = []string{"runner.sh"}
+	job.Container.Args = []string{"./test/presubmit-tests.sh", "--unit-tests"}
+	job.Container.SecurityContext.Privileged = true
+	job.Container.Env = []prowEnvVar{{Name: "FOO", Value: "bar"}, {Name: "TOKEN", ValueFrom: map[interface{}]interface{}{}}}
+	job.Container.VolumeMounts = []prowVolumeMount{{Name: "docker-graph", MountPath: "/docker-graph"}, {Name: "test-account", MountPath: "/etc/test-account"}}
+	job.Volumes = []prowVolume{{Name: "docker-graph", EmptyDir: map[interface{}]interface{}{}}, {Name: "test-account"}}
+	job.Volumes[1].Secret = &struct {
+		SecretName string `yaml:"secretName"`
+	}{"test-account"}
+
+	options := localRunOptions{Checkout: "/src/serving", SecretsDir: secretsDir, ArtifactsDir: "/tmp/artifacts", BuildID: "1", ContainerName: "pull-job-1"}
+	args, err := dockerRunArgs(job, options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"run", "--rm", "--name", "pull-job-1",
+		"-v", "/src/serving:/home/prow/go/src/knative.dev/serving", "-w", "/home/prow/go/src/knative.dev/serving",
+		"--privileged", "-v", "/tmp/artifacts:/logs/artifacts",
+		"-e", "CI=true", "-e", "GOPATH=/home/prow/go", "-e", "ARTIFACTS=/logs/artifacts",
+		"-e", "JOB_NAME=pull-job", "-e", "JOB_TYPE=presubmit", "-e", "BUILD_ID=1",
+		"-e", "REPO_OWNER=knative", "-e", "REPO_NAME=serving", "-e", "PULL_BASE_REF=master", "-e", "FOO=bar",
+		"-v", "/docker-graph", "-v", filepath.Join(secretsDir, "test-account") + ":/etc/test-account:ro",
+		"--entrypoint", "runner.sh", "image", "./test/presubmit-tests.sh", "--unit-tests"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected args:\n%s\ngot:\n%s", strings.Join(expected, " "), strings.Join(args, " "))
+	}
+
+	options.SecretsDir = filepath.Join(secretsDir, "missing")
+	if _, err := dockerRunArgs(job, options); err == nil || !strings.Contains(err.Error(), `secret "test-account" of job "pull-job" is expected in`) {
+		t.Errorf("Expected error about the missing secret, got %v", err)
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	cmd := shellCommand([]string{"docker", "run", "-e", "FOO=a b", "-e", "BAR=it's", "image"})
+	expected := `docker run -e 'FOO=a b' -e 'BAR=it'\''s' image`
+	if cmd != expected {
+		t.Errorf("Expected command %q, got %q", expected, cmd)
+	}
+}
@@ -23,7 +23,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/url"
 	"os"
 	"path"
@@ -34,54 +33,158 @@ import (
 	"google.golang.org/api/option"
 )
 
-var client *storage.Client
-
-// Authenticate explicitly sets up authentication for the rest of run
+// Authenticate explicitly sets up authentication for the rest of run, using GCS for the functions of this package
 func Authenticate(ctx context.Context, serviceAccount string) error {
-	var err error
-	client, err = storage.NewClient(ctx, option.WithCredentialsFile(serviceAccount))
-	return err
+	s, err := NewGCSStorage(ctx, serviceAccount)
+	if err != nil {
+		return err
+	}
+	SetStorage(s)
+	return nil
+}
+
+// Exists checks if path exist under gcs bucket,
+// this path can either be a directory or a file.
+// Returns an error if the existence cannot be checked, rather than false
+func Exists(ctx context.Context, bucketName, storagePath string) (bool, error) {
+	return backend.Exists(ctx, bucketName, storagePath)
+}
+
+// IsNotExist checks if the given error returned by the functions of this package means the file doesn't exist
+func IsNotExist(err error) bool {
+	return err == storage.ErrObjectNotExist || os.IsNotExist(err)
+}
+
+// ListChildrenFiles recursively lists all children files, limited by opts if not nil.
+// Returns an error if the listing fails or the context is done, never partial results.
+func ListChildrenFiles(ctx context.Context, bucketName, storagePath string, opts *ListOptions) ([]string, error) {
+	return backend.ListChildrenFiles(ctx, bucketName, storagePath, opts)
+}
+
+// ListDirectChildren lists direct children paths (including files and directories), limited by opts if not nil.
+// Returns an error if the listing fails or the context is done, never partial results.
+func ListDirectChildren(ctx context.Context, bucketName, storagePath string, opts *ListOptions) ([]string, error) {
+	return backend.ListDirectChildren(ctx, bucketName, storagePath, opts)
+}
+
+// Copy file from within gcs
+func Copy(ctx context.Context, srcBucketName, srcPath, dstBucketName, dstPath string) error {
+	return backend.Copy(ctx, srcBucketName, srcPath, dstBucketName, dstPath)
+}
+
+// Download file from gcs
+func Download(ctx context.Context, bucketName, srcPath, dstPath string) error {
+	return backend.Download(ctx, bucketName, srcPath, dstPath)
+}
+
+// Upload file to gcs
+func Upload(ctx context.Context, bucketName, dstPath, srcPath string) error {
+	return backend.Upload(ctx, bucketName, dstPath, srcPath)
+}
+
+// Read reads the specified file
+func Read(ctx context.Context, bucketName, filePath string) ([]byte, error) {
+	return backend.Read(ctx, bucketName, filePath)
+}
+
+// ReadURL reads from a gsUrl and return a log structure
+func ReadURL(ctx context.Context, gcsURL string) ([]byte, error) {
+	bucket, obj, err := linkToBucketAndObject(gcsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(ctx, bucket, obj)
+}
+
+// NewReader creates a new Reader of a gcs file.
+// Important: caller must call Close on the returned Reader when done reading
+func NewReader(ctx context.Context, bucketName, filePath string) (io.ReadCloser, error) {
+	return backend.NewReader(ctx, bucketName, filePath)
+}
+
+// BuildLogPath returns the build log path from the test result gcsURL
+func BuildLogPath(gcsURL string) (string, error) {
+	u, err := url.Parse(gcsURL)
+	if err != nil {
+		return gcsURL, err
+	}
+	u.Path = path.Join(u.Path, "build-log.txt")
+	return u.String(), nil
+}
+
+// GetConsoleURL returns the gcs link renderable directly from a browser
+func GetConsoleURL(gcsURL string) (string, error) {
+	u, err := url.Parse(gcsURL)
+	if err != nil {
+		return gcsURL, err
+	}
+	u.Path = path.Join("storage/browser", u.Host, u.Path)
+	u.Scheme = "https"
+	u.Host = "console.cloud.google.com"
+	return u.String(), nil
+}
+
+// gcsStorage is the Storage of GCS
+type gcsStorage struct {
+	client *storage.Client
+}
+
+// NewGCSStorage returns the Storage of GCS, authenticated with the given service account file
+func NewGCSStorage(ctx context.Context, serviceAccount string) (Storage, error) {
+	client, err := storage.NewClient(ctx, option.WithCredentialsFile(serviceAccount))
+	if err != nil {
+		return nil, err
+	}
+	return &gcsStorage{client: client}, nil
 }
 
 // Exists checks if path exist under gcs bucket,
 // this path can either be a directory or a file.
-func Exists(ctx context.Context, bucketName, storagePath string) bool {
+func (gs *gcsStorage) Exists(ctx context.Context, bucketName, storagePath string) (bool, error) {
 	// Check if this is a file
-	handle := createStorageObject(bucketName, storagePath)
-	if _, err := handle.Attrs(ctx); nil == err {
-		return true
+	handle := gs.createStorageObject(bucketName, storagePath)
+	_, err := handle.Attrs(ctx)
+	if nil == err {
+		return true, nil
+	}
+	if err != storage.ErrObjectNotExist {
+		return false, err
 	}
 	// Check if this is a directory,
 	// gcs directory paths are virtual paths, they automatically got deleted if there is no child file
-	_, err := getObjectsIter(ctx, bucketName, strings.TrimRight(storagePath, " /")+"/", "").Next()
-	return nil == err
+	_, err = gs.getObjectsIter(ctx, bucketName, strings.TrimRight(storagePath, " /")+"/", "").Next()
+	if err == iterator.Done {
+		return false, nil
+	}
+	return nil == err, err
 }
 
 // ListChildrenFiles recursively lists all children files.
-func ListChildrenFiles(ctx context.Context, bucketName, storagePath string) []string {
-	return list(ctx, bucketName, strings.TrimRight(storagePath, " /")+"/", "")
+func (gs *gcsStorage) ListChildrenFiles(ctx context.Context, bucketName, storagePath string, opts *ListOptions) ([]string, error) {
+	return gs.list(ctx, bucketName, strings.TrimRight(storagePath, " /")+"/", "", opts)
 }
 
 // ListDirectChildren lists direct children paths (including files and directories).
-func ListDirectChildren(ctx context.Context, bucketName, storagePath string) []string {
+func (gs *gcsStorage) ListDirectChildren(ctx context.Context, bucketName, storagePath string, opts *ListOptions) ([]string, error) {
 	// If there are 2 directories named "foo" and "foobar",
 	// then given storagePath "foo" will get files both under "foo" and "foobar".
 	// Add trailling slash to storagePath, so that only gets children under given directory.
-	return list(ctx, bucketName, strings.TrimRight(storagePath, " /")+"/", "/")
+	return gs.list(ctx, bucketName, strings.TrimRight(storagePath, " /")+"/", "/", opts)
 }
 
 // Copy file from within gcs
-func Copy(ctx context.Context, srcBucketName, srcPath, dstBucketName, dstPath string) error {
-	src := createStorageObject(srcBucketName, srcPath)
-	dst := createStorageObject(dstBucketName, dstPath)
+func (gs *gcsStorage) Copy(ctx context.Context, srcBucketName, srcPath, dstBucketName, dstPath string) error {
+	src := gs.createStorageObject(srcBucketName, srcPath)
+	dst := gs.createStorageObject(dstBucketName, dstPath)
 
 	_, err := dst.CopierFrom(src).Run(ctx)
 	return err
 }
 
 // Download file from gcs
-func Download(ctx context.Context, bucketName, srcPath, dstPath string) error {
-	handle := createStorageObject(bucketName, srcPath)
+func (gs *gcsStorage) Download(ctx context.Context, bucketName, srcPath, dstPath string) error {
+	handle := gs.createStorageObject(bucketName, srcPath)
 	if _, err := handle.Attrs(ctx); nil != err {
 		return err
 	}
@@ -102,12 +205,12 @@ func Download(ctx context.Context, bucketName, srcPath, dstPath string) error {
 }
 
 // Upload file to gcs
-func Upload(ctx context.Context, bucketName, dstPath, srcPath string) error {
+func (gs *gcsStorage) Upload(ctx context.Context, bucketName, dstPath, srcPath string) error {
 	src, err := os.Open(srcPath)
 	if nil != err {
 		return err
 	}
-	dst := createStorageObject(bucketName, dstPath).NewWriter(ctx)
+	dst := gs.createStorageObject(bucketName, dstPath).NewWriter(ctx)
 	if _, err = io.Copy(dst, src); nil != err {
 		return err
 	}
@@ -116,83 +219,47 @@ func Upload(ctx context.Context, bucketName, dstPath, srcPath string) error {
 }
 
 // Read reads the specified file
-func Read(ctx context.Context, bucketName, filePath string) ([]byte, error) {
-	var contents []byte
-	f, err := NewReader(ctx, bucketName, filePath)
-	if err != nil {
-		return contents, err
-	}
-	defer f.Close()
-	contents, err = ioutil.ReadAll(f)
-	if err != nil {
-		return contents, err
-	}
-	return contents, nil
-}
-
-// ReadURL reads from a gsUrl and return a log structure
-func ReadURL(ctx context.Context, gcsURL string) ([]byte, error) {
-	bucket, obj, err := linkToBucketAndObject(gcsURL)
-	if err != nil {
-		return nil, err
-	}
-
-	return Read(ctx, bucket, obj)
+func (gs *gcsStorage) Read(ctx context.Context, bucketName, filePath string) ([]byte, error) {
+	return readAll(gs.NewReader(ctx, bucketName, filePath))
 }
 
 // NewReader creates a new Reader of a gcs file.
 // Important: caller must call Close on the returned Reader when done reading
-func NewReader(ctx context.Context, bucketName, filePath string) (*storage.Reader, error) {
-	o := createStorageObject(bucketName, filePath)
+func (gs *gcsStorage) NewReader(ctx context.Context, bucketName, filePath string) (io.ReadCloser, error) {
+	o := gs.createStorageObject(bucketName, filePath)
 	if _, err := o.Attrs(ctx); err != nil {
 		return nil, err
 	}
 	return o.NewReader(ctx)
 }
 
-// BuildLogPath returns the build log path from the test result gcsURL
-func BuildLogPath(gcsURL string) (string, error) {
-	u, err := url.Parse(gcsURL)
-	if err != nil {
-		return gcsURL, err
-	}
-	u.Path = path.Join(u.Path, "build-log.txt")
-	return u.String(), nil
-}
-
-// GetConsoleURL returns the gcs link renderable directly from a browser
-func GetConsoleURL(gcsURL string) (string, error) {
-	u, err := url.Parse(gcsURL)
-	if err != nil {
-		return gcsURL, err
-	}
-	u.Path = path.Join("storage/browser", u.Host, u.Path)
-	u.Scheme = "https"
-	u.Host = "console.cloud.google.com"
-	return u.String(), nil
-}
-
 // create storage object handle, this step doesn't access internet
-func createStorageObject(bucketName, filePath string) *storage.ObjectHandle {
-	return client.Bucket(bucketName).Object(filePath)
+func (gs *gcsStorage) createStorageObject(bucketName, filePath string) *storage.ObjectHandle {
+	return gs.client.Bucket(bucketName).Object(filePath)
 }
 
 // Query items under given gcs storagePath, use exclusionFilter to eliminate some files.
-func getObjectsAttrs(ctx context.Context, bucketName, storagePath, exclusionFilter string) []*storage.ObjectAttrs {
+// Returns an error rather than partial results if the iteration fails or the context is done,
+// stops after the maximum number of results of opts.
+func (gs *gcsStorage) getObjectsAttrs(ctx context.Context, bucketName, storagePath, exclusionFilter string, opts *ListOptions) ([]*storage.ObjectAttrs, error) {
 	var allAttrs []*storage.ObjectAttrs
-	it := getObjectsIter(ctx, bucketName, storagePath, exclusionFilter)
+	it := gs.getObjectsIter(ctx, bucketName, storagePath, exclusionFilter)
+	it.PageInfo().MaxSize = opts.pageSize()
 
-	for {
+	for max := opts.maxResults(); max == 0 || len(allAttrs) < max; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		attrs, err := it.Next()
 		if err == iterator.Done {
 			break
 		}
 		if err != nil {
-			log.Fatalf("Error iterating: %v", err)
+			return nil, fmt.Errorf("error listing '%s' in bucket '%s': %v", storagePath, bucketName, err)
 		}
 		allAttrs = append(allAttrs, attrs)
 	}
-	return allAttrs
+	return allAttrs, nil
 }
 
 // list child under storagePath, use exclusionFilter for skipping some files.
@@ -201,23 +268,36 @@ func getObjectsAttrs(ctx context.Context, bucketName, storagePath, exclusionFilt
 // If exclusionFilter is empty string, returns all files but not directories,
 // if exclusionFilter is "/", returns all direct children, including both files and directories.
 // see https://godoc.org/cloud.google.com/go/storage#Query
-func list(ctx context.Context, bucketName, storagePath, exclusionFilter string) []string {
+func (gs *gcsStorage) list(ctx context.Context, bucketName, storagePath, exclusionFilter string, opts *ListOptions) ([]string, error) {
 	var filePaths []string
-	objsAttrs := getObjectsAttrs(ctx, bucketName, storagePath, exclusionFilter)
+	objsAttrs, err := gs.getObjectsAttrs(ctx, bucketName, storagePath, exclusionFilter, opts)
+	if err != nil {
+		return nil, err
+	}
 	for _, attrs := range objsAttrs {
 		filePaths = append(filePaths, path.Join(attrs.Prefix, attrs.Name))
 	}
-	return filePaths
+	return filePaths, nil
 }
 
 // get objects iterator under given storagePath and bucketName, use exclusionFilter to eliminate some files.
-func getObjectsIter(ctx context.Context, bucketName, storagePath, exclusionFilter string) *storage.ObjectIterator {
-	return client.Bucket(bucketName).Objects(ctx, &storage.Query{
+func (gs *gcsStorage) getObjectsIter(ctx context.Context, bucketName, storagePath, exclusionFilter string) *storage.ObjectIterator {
+	return gs.client.Bucket(bucketName).Objects(ctx, &storage.Query{
 		Prefix:    storagePath,
 		Delimiter: exclusionFilter,
 	})
 }
 
+// readAll reads all contents of the reader, and closes it
+func readAll(f io.ReadCloser, err error) ([]byte, error) {
+	var contents []byte
+	if err != nil {
+		return contents, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
 // get the bucket and object from the gsURL
 func linkToBucketAndObject(gsURL string) (string, string, error) {
 	var bucket, obj string
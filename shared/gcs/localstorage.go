@@ -0,0 +1,149 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// localstorage.go defines the Storage of a local directory, where buckets are sub directories
+
+package gcs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localStorage is the Storage of a local directory
+type localStorage struct {
+	root string
+}
+
+// NewLocalStorage returns the Storage of the given local directory, where buckets are sub directories
+func NewLocalStorage(root string) Storage {
+	return &localStorage{root: root}
+}
+
+// Exists checks if path exist under the bucket directory,
+// this path can either be a directory or a file.
+func (ls *localStorage) Exists(ctx context.Context, bucketName, storagePath string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	_, err := os.Stat(ls.localPath(bucketName, storagePath))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return nil == err, err
+}
+
+// ListChildrenFiles recursively lists all children files.
+func (ls *localStorage) ListChildrenFiles(ctx context.Context, bucketName, storagePath string, opts *ListOptions) ([]string, error) {
+	names, err := ls.walk(ctx, bucketName, storagePath)
+	if err != nil {
+		return nil, err
+	}
+	return listNames(ctx, names, storagePath, true, opts)
+}
+
+// ListDirectChildren lists direct children paths (including files and directories).
+func (ls *localStorage) ListDirectChildren(ctx context.Context, bucketName, storagePath string, opts *ListOptions) ([]string, error) {
+	names, err := ls.walk(ctx, bucketName, storagePath)
+	if err != nil {
+		return nil, err
+	}
+	return listNames(ctx, names, storagePath, false, opts)
+}
+
+// Copy file from within the local directory
+func (ls *localStorage) Copy(ctx context.Context, srcBucketName, srcPath, dstBucketName, dstPath string) error {
+	return copyFile(ls.localPath(srcBucketName, srcPath), ls.localPath(dstBucketName, dstPath))
+}
+
+// Download file from the bucket directory
+func (ls *localStorage) Download(ctx context.Context, bucketName, srcPath, dstPath string) error {
+	return copyFile(ls.localPath(bucketName, srcPath), dstPath)
+}
+
+// Upload file to the bucket directory
+func (ls *localStorage) Upload(ctx context.Context, bucketName, dstPath, srcPath string) error {
+	return copyFile(srcPath, ls.localPath(bucketName, dstPath))
+}
+
+// Read reads the specified file
+func (ls *localStorage) Read(ctx context.Context, bucketName, filePath string) ([]byte, error) {
+	return ioutil.ReadFile(ls.localPath(bucketName, filePath))
+}
+
+// NewReader creates a new Reader of a file.
+// Important: caller must call Close on the returned Reader when done reading
+func (ls *localStorage) NewReader(ctx context.Context, bucketName, filePath string) (io.ReadCloser, error) {
+	return os.Open(ls.localPath(bucketName, filePath))
+}
+
+// localPath returns the local path of the given path in the bucket
+func (ls *localStorage) localPath(bucketName, storagePath string) string {
+	return filepath.Join(ls.root, bucketName, filepath.FromSlash(storagePath))
+}
+
+// walk returns the paths, relative to the bucket directory, of all files under storagePath,
+// none if storagePath doesn't exist
+func (ls *localStorage) walk(ctx context.Context, bucketName, storagePath string) ([]string, error) {
+	var names []string
+	bucketDir := ls.localPath(bucketName, "")
+	root := ls.localPath(bucketName, strings.TrimRight(storagePath, " /"))
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if p == root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, err := filepath.Rel(bucketDir, p)
+			if err != nil {
+				return err
+			}
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return names, err
+}
+
+// copyFile copies the file src to dst, creating the parent directories of dst if needed
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
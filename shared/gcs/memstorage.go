@@ -0,0 +1,135 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// memstorage.go defines an in-memory Storage, for testing
+
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"cloud.google.com/go/storage"
+)
+
+// MemStorage is an in-memory Storage, files are added with Write or Upload
+type MemStorage struct {
+	mutex   sync.Mutex
+	buckets map[string]map[string][]byte // map of bucket name: map of file path: contents
+}
+
+// NewMemStorage returns an empty MemStorage
+func NewMemStorage() *MemStorage {
+	return &MemStorage{buckets: make(map[string]map[string][]byte)}
+}
+
+// Write writes the contents to the file, replacing it if it exists
+func (ms *MemStorage) Write(bucketName, filePath string, contents []byte) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if _, ok := ms.buckets[bucketName]; !ok {
+		ms.buckets[bucketName] = make(map[string][]byte)
+	}
+	ms.buckets[bucketName][filePath] = append([]byte{}, contents...)
+}
+
+// Exists checks if path exist under the bucket,
+// this path can either be a directory or a file.
+func (ms *MemStorage) Exists(ctx context.Context, bucketName, storagePath string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if _, err := ms.Read(ctx, bucketName, storagePath); nil == err {
+		return true, nil
+	}
+	children, err := ms.ListChildrenFiles(ctx, bucketName, storagePath, &ListOptions{MaxResults: 1})
+	return len(children) > 0, err
+}
+
+// ListChildrenFiles recursively lists all children files.
+func (ms *MemStorage) ListChildrenFiles(ctx context.Context, bucketName, storagePath string, opts *ListOptions) ([]string, error) {
+	return listNames(ctx, ms.names(bucketName), storagePath, true, opts)
+}
+
+// ListDirectChildren lists direct children paths (including files and directories).
+func (ms *MemStorage) ListDirectChildren(ctx context.Context, bucketName, storagePath string, opts *ListOptions) ([]string, error) {
+	return listNames(ctx, ms.names(bucketName), storagePath, false, opts)
+}
+
+// Copy file from within the storage
+func (ms *MemStorage) Copy(ctx context.Context, srcBucketName, srcPath, dstBucketName, dstPath string) error {
+	contents, err := ms.Read(ctx, srcBucketName, srcPath)
+	if err != nil {
+		return err
+	}
+	ms.Write(dstBucketName, dstPath, contents)
+	return nil
+}
+
+// Download file from the storage
+func (ms *MemStorage) Download(ctx context.Context, bucketName, srcPath, dstPath string) error {
+	contents, err := ms.Read(ctx, bucketName, srcPath)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dstPath, contents, 0644)
+}
+
+// Upload file to the storage
+func (ms *MemStorage) Upload(ctx context.Context, bucketName, dstPath, srcPath string) error {
+	contents, err := ioutil.ReadFile(srcPath)
+	if err != nil {
+		return err
+	}
+	ms.Write(bucketName, dstPath, contents)
+	return nil
+}
+
+// Read reads the specified file, returns storage.ErrObjectNotExist like GCS if it doesn't exist
+func (ms *MemStorage) Read(ctx context.Context, bucketName, filePath string) ([]byte, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	contents, ok := ms.buckets[bucketName][filePath]
+	if !ok {
+		return nil, storage.ErrObjectNotExist
+	}
+	return append([]byte{}, contents...), nil
+}
+
+// NewReader creates a new Reader of a file.
+func (ms *MemStorage) NewReader(ctx context.Context, bucketName, filePath string) (io.ReadCloser, error) {
+	contents, err := ms.Read(ctx, bucketName, filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(contents)), nil
+}
+
+// names returns the paths of all files in the bucket, sorted
+func (ms *MemStorage) names(bucketName string) []string {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	var names []string
+	for name := range ms.buckets[bucketName] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
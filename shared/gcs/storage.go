@@ -0,0 +1,140 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// storage.go defines the storage backends behind the functions of this package, so that they can be used
+// with GCS, a local directory or in memory
+
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Storage contains a set of functions for storage operations, all paths are relative to the bucket
+type Storage interface {
+	Exists(ctx context.Context, bucketName, storagePath string) (bool, error)
+	ListChildrenFiles(ctx context.Context, bucketName, storagePath string, opts *ListOptions) ([]string, error)
+	ListDirectChildren(ctx context.Context, bucketName, storagePath string, opts *ListOptions) ([]string, error)
+	Copy(ctx context.Context, srcBucketName, srcPath, dstBucketName, dstPath string) error
+	Download(ctx context.Context, bucketName, srcPath, dstPath string) error
+	Upload(ctx context.Context, bucketName, dstPath, srcPath string) error
+	Read(ctx context.Context, bucketName, filePath string) ([]byte, error)
+	NewReader(ctx context.Context, bucketName, filePath string) (io.ReadCloser, error)
+}
+
+// ListOptions limits the listing of files, nil means no limit
+type ListOptions struct {
+	// PageSize is the number of items fetched by each request, the default of the storage if 0
+	PageSize int
+	// MaxResults stops the listing after this number of items, all items are listed if 0
+	MaxResults int
+}
+
+// maxResults returns the maximum number of items listed, 0 if there is no limit
+func (opts *ListOptions) maxResults() int {
+	if nil == opts {
+		return 0
+	}
+	return opts.MaxResults
+}
+
+// pageSize returns the number of items fetched by each request, 0 for the default of the storage
+func (opts *ListOptions) pageSize() int {
+	if nil == opts {
+		return 0
+	}
+	return opts.PageSize
+}
+
+var (
+	// backend is used by the functions of this package, set by Authenticate or SetStorage
+	backend Storage
+
+	// in-memory storages by name, so that all mem:// URLs with the same name share the same storage
+	memStorages     = make(map[string]*MemStorage)
+	memStoragesLock sync.Mutex
+)
+
+// SetStorage sets the storage used by the functions of this package
+func SetStorage(s Storage) {
+	backend = s
+}
+
+// NewStorage returns the storage for the given URL, depending on its scheme:
+// "gs://" (or no URL) for GCS, authenticated with the given service account file if not empty,
+// "file:///path/to/dir" for a local directory, where buckets are sub directories,
+// "mem://name" for an in-memory storage, which is the same for all URLs with the same name in the process.
+func NewStorage(ctx context.Context, storageURL, serviceAccount string) (Storage, error) {
+	if storageURL == "" {
+		return NewGCSStorage(ctx, serviceAccount)
+	}
+	u, err := url.Parse(storageURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "gs":
+		return NewGCSStorage(ctx, serviceAccount)
+	case "file":
+		return NewLocalStorage(filepath.FromSlash(u.Path)), nil
+	case "mem":
+		memStoragesLock.Lock()
+		defer memStoragesLock.Unlock()
+		if _, ok := memStorages[u.Host]; !ok {
+			memStorages[u.Host] = NewMemStorage()
+		}
+		return memStorages[u.Host], nil
+	default:
+		return nil, fmt.Errorf("unsupported storage URL %q, expecting gs://, file:// or mem://", storageURL)
+	}
+}
+
+// listNames lists the given object names under storagePath, like the listing of GCS does:
+// recursively lists all children files, otherwise lists direct children files and directories.
+// It stops after the maximum number of results of opts, or with an error if the context is done.
+func listNames(ctx context.Context, names []string, storagePath string, recursive bool, opts *ListOptions) ([]string, error) {
+	prefix := strings.TrimRight(storagePath, " /") + "/"
+	var children []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		child := name
+		if i := strings.Index(name[len(prefix):], "/"); !recursive && i >= 0 {
+			child = prefix + name[len(prefix):][:i]
+		}
+		if !seen[child] {
+			seen[child] = true
+			children = append(children, child)
+		}
+	}
+	sort.Strings(children)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if max := opts.maxResults(); max > 0 && len(children) > max {
+		children = children[:max]
+	}
+	return children, nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	localFile := filepath.Join(dir, "local.txt")
+	if err := ioutil.WriteFile(localFile, []byte("uploaded"), 0644); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+
+	for _, storageURL := range []string{"file://" + filepath.ToSlash(filepath.Join(dir, "buckets")), "mem://test"} {
+		s, err := NewStorage(ctx, storageURL, "")
+		if err != nil {
+			t.Fatalf("Cannot create storage %q: %v", storageURL, err)
+		}
+		for _, name := range []string{"logs/job/1/build-log.txt", "logs/job/1/artifacts/junit.xml", "logs/job/2/build-log.txt", "logs/job2/started.json"} {
+			if err := s.Upload(ctx, "bucket", name, localFile); err != nil {
+				t.Fatalf("%s: cannot upload %s: %v", storageURL, name, err)
+			}
+		}
+		if err := s.Copy(ctx, "bucket", "logs/job/1/build-log.txt", "other", "copy.txt"); err != nil {
+			t.Errorf("%s: cannot copy: %v", storageURL, err)
+		}
+
+		// Returns the listed names, or the error if any
+		list := func(names []string, err error) interface{} {
+			if err != nil {
+				return err
+			}
+			return names
+		}
+		// Returns whether the path exists, or the error if any
+		exists := func(exists bool, err error) interface{} {
+			if err != nil {
+				return err
+			}
+			return exists
+		}
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		var tests = []struct {
+			name     string
+			call     func() interface{}
+			expected interface{}
+		}{
+			{"file exists", func() interface{} { return exists(s.Exists(ctx, "bucket", "logs/job/2/build-log.txt")) }, true},
+			{"directory exists", func() interface{} { return exists(s.Exists(ctx, "bucket", "logs/job/1/")) }, true},
+			{"missing path", func() interface{} { return exists(s.Exists(ctx, "bucket", "logs/job/3")) }, false},
+			{"exists with cancelled context", func() interface{} { return exists(s.Exists(cancelled, "bucket", "logs/job/3")) },
+				context.Canceled},
+			{"children files", func() interface{} { return list(s.ListChildrenFiles(ctx, "bucket", "logs/job", nil)) },
+				[]string{"logs/job/1/artifacts/junit.xml", "logs/job/1/build-log.txt", "logs/job/2/build-log.txt"}},
+			{"direct children", func() interface{} { return list(s.ListDirectChildren(ctx, "bucket", "logs/job/1", nil)) },
+				[]string{"logs/job/1/artifacts", "logs/job/1/build-log.txt"}},
+			{"no children", func() interface{} { return list(s.ListDirectChildren(ctx, "bucket", "logs/job/3", nil)) }, []string(nil)},
+			{"max results", func() interface{} {
+				return list(s.ListChildrenFiles(ctx, "bucket", "logs/job", &ListOptions{PageSize: 1, MaxResults: 2}))
+			}, []string{"logs/job/1/artifacts/junit.xml", "logs/job/1/build-log.txt"}},
+			{"cancelled context", func() interface{} { return list(s.ListDirectChildren(cancelled, "bucket", "logs/job", nil)) },
+				context.Canceled},
+			{"read copy", func() interface{} {
+				contents, err := s.Read(ctx, "other", "copy.txt")
+				return string(contents) + fmt.Sprint(err)
+			}, "uploaded<nil>"},
+			{"read missing file", func() interface{} {
+				_, err := s.Read(ctx, "bucket", "logs/job/3/build-log.txt")
+				return IsNotExist(err)
+			}, true},
+			{"download", func() interface{} {
+				dst := filepath.Join(dir, "downloaded.txt")
+				if err := s.Download(ctx, "bucket", "logs/job2/started.json", dst); err != nil {
+					return err
+				}
+				contents, _ := ioutil.ReadFile(dst)
+				return string(contents)
+			}, "uploaded"},
+		}
+		for _, test := range tests {
+			if res := test.call(); !reflect.DeepEqual(res, test.expected) {
+				t.Errorf("%s: %s: expected %v, got %v", storageURL, test.name, test.expected, res)
+			}
+		}
+	}
+}
+
+func TestNewStorage(t *testing.T) {
+	ctx := context.Background()
+	s1, _ := NewStorage(ctx, "mem://shared", "")
+	s2, _ := NewStorage(ctx, "mem://shared", "")
+	if s1 != s2 {
+		t.Error("Expected the same in-memory storage for the same name")
+	}
+	if _, err := NewStorage(ctx, "s3://bucket", ""); err == nil {
+		t.Error("Expected an error for an unsupported scheme")
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// app.go provides the authentication as a Github App installation, instead of with a personal token
+
+package ghutil
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	githubAPIURL = "https://api.github.com/"
+	// Media type of the Github Apps API, still in preview
+	appsMediaType = "application/vnd.github.machine-man-preview+json"
+	// Github rejects JWTs valid for more than 10 minutes
+	appJWTDuration = 10 * time.Minute
+	// Installation tokens are valid for 1 hour, they're refreshed when expiring in less than this
+	appTokenRefreshMargin = 5 * time.Minute
+)
+
+// GithubAppConfig is the content of the yaml file configuring the authentication as a Github App installation,
+// with the keys app-id, installation-id and private-key-file. A relative private-key-file is relative to the file.
+type GithubAppConfig struct {
+	AppID          int64  `yaml:"app-id"`
+	InstallationID int64  `yaml:"installation-id"`
+	PrivateKeyFile string `yaml:"private-key-file"`
+}
+
+// AppTokenSource is an oauth2.TokenSource returning installation tokens of a Github App. Each token is requested
+// with a JWT signed by the private key of the app.
+type AppTokenSource struct {
+	AppID          int64
+	InstallationID int64
+	PrivateKey     *rsa.PrivateKey
+	// BaseURL is the URL of the Github API, with a trailing slash
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewGithubAppClient authenticates to github as the Github App installation configured in the given file,
+// see GithubAppConfig, and returns a handle. The installation tokens are refreshed before they expire.
+func NewGithubAppClient(appConfigFile string) (*GithubClient, error) {
+	ts, err := newAppTokenSource(appConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	return &GithubClient{Client: github.NewClient(oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, ts))), app: ts}, nil
+}
+
+// NewCachedGithubAppClient authenticates to github like NewGithubAppClient, and caches the responses in the
+// given storage, see CachingTransport
+func NewCachedGithubAppClient(appConfigFile string, storage CacheStorage) (*GithubClient, error) {
+	ts, err := newAppTokenSource(appConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	cache := NewCachingTransport(nil, storage)
+	httpClient := oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: cache}),
+		oauth2.ReuseTokenSource(nil, ts))
+	return &GithubClient{Client: github.NewClient(httpClient), cache: cache, app: ts}, nil
+}
+
+// newAppTokenSource returns the token source of the Github App installation configured in the given file
+func newAppTokenSource(appConfigFile string) (*AppTokenSource, error) {
+	b, err := ioutil.ReadFile(appConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	var config GithubAppConfig
+	if err := yaml.UnmarshalStrict(b, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", appConfigFile, err)
+	}
+	if config.AppID == 0 || config.InstallationID == 0 || config.PrivateKeyFile == "" {
+		return nil, fmt.Errorf("app-id, installation-id and private-key-file must all be set in %s", appConfigFile)
+	}
+	keyFile := config.PrivateKeyFile
+	if !filepath.IsAbs(keyFile) {
+		keyFile = filepath.Join(filepath.Dir(appConfigFile), keyFile)
+	}
+	b, err = ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePrivateKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse the private key %s: %v", keyFile, err)
+	}
+	return &AppTokenSource{
+		AppID:          config.AppID,
+		InstallationID: config.InstallationID,
+		PrivateKey:     key,
+		BaseURL:        githubAPIURL,
+		HTTPClient:     http.DefaultClient,
+	}, nil
+}
+
+// parsePrivateKey parses a PEM encoded RSA private key, in the PKCS #1 format Github provides or in PKCS #8
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA private key")
+	}
+	return key, nil
+}
+
+// Token requests a new installation token, implementing oauth2.TokenSource. The token expires a bit before Github
+// says it does, so that oauth2.ReuseTokenSource refreshes it before any request fails.
+func (ts *AppTokenSource) Token() (*oauth2.Token, error) {
+	var res struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := ts.do(http.MethodPost, fmt.Sprintf("app/installations/%d/access_tokens", ts.InstallationID),
+		http.StatusCreated, &res); err != nil {
+		return nil, fmt.Errorf("cannot get an installation token: %v", err)
+	}
+	return &oauth2.Token{AccessToken: res.Token, Expiry: res.ExpiresAt.Add(-appTokenRefreshMargin)}, nil
+}
+
+// appSlug returns the slug of the app, its name in URLs
+func (ts *AppTokenSource) appSlug() (string, error) {
+	var res struct {
+		Slug string `json:"slug"`
+	}
+	if err := ts.do(http.MethodGet, "app", http.StatusOK, &res); err != nil {
+		return "", fmt.Errorf("cannot get the app: %v", err)
+	}
+	return res.Slug, nil
+}
+
+// do sends a request authenticated as the app, and decodes its JSON response
+func (ts *AppTokenSource) do(method, path string, expectedStatus int, v interface{}) error {
+	jwt, err := ts.jwt()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, ts.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", appsMediaType)
+	resp, err := ts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != expectedStatus {
+		return fmt.Errorf("request failed with status '%s': %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// jwt returns a JSON Web Token identifying the app, signed with its private key
+func (ts *AppTokenSource) jwt() (string, error) {
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	// Issued a bit in the past, in case the clocks are not in sync.
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTDuration - time.Minute).Unix(),
+		"iss": ts.AppID,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, ts.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghutil
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+// writeAppConfig writes the config of app 1 installed as 42 in the given dir, with a new private key,
+// and returns the path of the config file and the key.
+func writeAppConfig(t *testing.T, dir string) (string, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Cannot generate key: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(filepath.Join(dir, "key.pem"), keyPEM, 0600); err != nil {
+		t.Fatalf("Cannot write key: %v", err)
+	}
+	configFile := filepath.Join(dir, "app.yaml")
+	if err := ioutil.WriteFile(configFile, []byte("app-id: 1\ninstallation-id: 42\nprivate-key-file: key.pem\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config: %v", err)
+	}
+	return configFile, key
+}
+
+// newFakeTokenServer returns a server issuing installation tokens expiring after the given duration,
+// to requests with a JWT of app 1 signed with the given key, and a function returning the tokens issued.
+func newFakeTokenServer(t *testing.T, key *rsa.PrivateKey, expiresIn time.Duration) (*httptest.Server, func() []string) {
+	var tokens []string
+	checkJWT := func(r *http.Request) bool {
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			return false
+		}
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			return false
+		}
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return false
+		}
+		b, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]int64
+		if err := json.Unmarshal(b, &claims); err != nil {
+			return false
+		}
+		now := time.Now().Unix()
+		return claims["iss"] == 1 && claims["iat"] <= now && claims["exp"] > now
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/app/installations/42/access_tokens":
+			if !checkJWT(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			tokens = append(tokens, fmt.Sprintf("token-%d", len(tokens)+1))
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": %q, "expires_at": %q}`, tokens[len(tokens)-1], time.Now().Add(expiresIn).Format(time.RFC3339))
+		case r.Method == http.MethodGet && r.URL.Path == "/app":
+			if !checkJWT(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"id": 1, "slug": "knative-bot"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/users/knative-bot[bot]":
+			if len(tokens) == 0 || r.Header.Get("Authorization") != "Bearer "+tokens[len(tokens)-1] {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"id": 7, "login": "knative-bot[bot]"}`)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, func() []string { return tokens }
+}
+
+func TestGithubAppClient(t *testing.T) {
+	var tests = []struct {
+		name      string
+		expiresIn time.Duration
+		tokens    int
+	}{
+		{"valid token", time.Hour, 1},
+		{"expiring token", 2 * time.Minute, 3},
+	}
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "ghutil-app")
+		if err != nil {
+			t.Fatalf("Cannot create temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		configFile, key := writeAppConfig(t, dir)
+		server, tokens := newFakeTokenServer(t, key, test.expiresIn)
+
+		ts, err := newAppTokenSource(configFile)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		ts.BaseURL = server.URL + "/"
+		client := github.NewClient(oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, ts)))
+		client.BaseURL, _ = url.Parse(server.URL + "/")
+		gc := &GithubClient{Client: client, app: ts}
+
+		for i := 0; i < 3; i++ {
+			user, err := gc.GetGithubUser()
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", test.name, err)
+			}
+			if user.GetLogin() != "knative-bot[bot]" || user.GetID() != 7 {
+				t.Errorf("%s: expected the bot user of the app, got %v", test.name, user)
+			}
+		}
+		if len(tokens()) != test.tokens {
+			t.Errorf("%s: expected %d tokens issued, got %v", test.name, test.tokens, tokens())
+		}
+		server.Close()
+	}
+}
+
+func TestNewAppTokenSourceErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghutil-app")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad.pem"), []byte("not a key"), 0600); err != nil {
+		t.Fatalf("Cannot write key: %v", err)
+	}
+
+	var tests = []struct {
+		config string
+		err    string
+	}{
+		{"app-id: 1\nprivate-key-file: bad.pem\n", "app-id, installation-id and private-key-file must all be set"},
+		{"app-id: 1\ninstallation-id: 42\nprivate-key: bad.pem\n", "cannot parse"},
+		{"app-id: 1\ninstallation-id: 42\nprivate-key-file: bad.pem\n", "cannot parse the private key"},
+	}
+	for _, test := range tests {
+		configFile := filepath.Join(dir, "app.yaml")
+		if err := ioutil.WriteFile(configFile, []byte(test.config), 0644); err != nil {
+			t.Fatalf("Cannot write config: %v", err)
+		}
+		if _, err := newAppTokenSource(configFile); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expected error containing %q for config %q, got %v", test.err, test.config, err)
+		}
+	}
+}
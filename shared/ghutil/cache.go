@@ -0,0 +1,236 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// cache.go provides a cache of Github responses, revalidated with their ETag so that unchanged resources don't use rate limit
+
+package ghutil
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// CacheStorage stores the cached responses, by group of related resources invalidated together
+type CacheStorage interface {
+	Get(group, key string) ([]byte, bool)
+	Set(group, key string, value []byte)
+	DeleteGroup(group string)
+}
+
+// CacheStats contains the counters of a cache
+type CacheStats struct {
+	// Hits is the number of requests answered from the cache, as the resource was not modified
+	Hits int64
+	// Misses is the number of cacheable requests answered by Github, as the resource was not cached or was modified
+	Misses int64
+}
+
+// CachingTransport is an http.RoundTripper caching the responses of GET requests. A cached response is revalidated
+// with its ETag on each request, Github replying 304 Not Modified without using rate limit if the resource didn't change.
+// Any successful write to a repository invalidates all cached responses of the repository, as Github may otherwise
+// consider the previous responses still valid for a while.
+type CachingTransport struct {
+	Transport http.RoundTripper
+	Storage   CacheStorage
+
+	hits   int64
+	misses int64
+}
+
+// NewCachingTransport returns a CachingTransport sending the requests through the given transport
+// (http.DefaultTransport if nil), and storing the responses in the given storage
+func NewCachingTransport(transport http.RoundTripper, storage CacheStorage) *CachingTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &CachingTransport{Transport: transport, Storage: storage}
+}
+
+// Stats returns the counters of the cache
+func (ct *CachingTransport) Stats() CacheStats {
+	return CacheStats{Hits: atomic.LoadInt64(&ct.hits), Misses: atomic.LoadInt64(&ct.misses)}
+}
+
+// RoundTrip implements http.RoundTripper
+func (ct *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	group := cacheGroup(req)
+	if req.Method != http.MethodGet {
+		resp, err := ct.Transport.RoundTrip(req)
+		if err == nil && resp.StatusCode < http.StatusBadRequest && req.Method != http.MethodHead {
+			ct.Storage.DeleteGroup(group)
+		}
+		return resp, err
+	}
+
+	key := cacheKey(req)
+	var cached *http.Response
+	if value, ok := ct.Storage.Get(group, key); ok {
+		if resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(value)), req); err == nil {
+			cached = resp
+		}
+	}
+	if cached != nil && cached.Header.Get("ETag") != "" {
+		// Don't modify the given request, as required for a RoundTripper.
+		revalidation := new(http.Request)
+		*revalidation = *req
+		revalidation.Header = make(http.Header, len(req.Header)+1)
+		for name, values := range req.Header {
+			revalidation.Header[name] = values
+		}
+		revalidation.Header.Set("If-None-Match", cached.Header.Get("ETag"))
+		req = revalidation
+	}
+	resp, err := ct.Transport.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		atomic.AddInt64(&ct.hits, 1)
+		resp.Body.Close()
+		// Keep the rate limit of the latest response.
+		for name, values := range resp.Header {
+			if strings.HasPrefix(name, "X-Ratelimit-") {
+				cached.Header[name] = values
+			}
+		}
+		return cached, nil
+	}
+	atomic.AddInt64(&ct.misses, 1)
+	if resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "" {
+		// DumpResponse reads the body, and replaces it with a copy.
+		if value, err := httputil.DumpResponse(resp, true); err == nil {
+			ct.Storage.Set(group, key, value)
+		}
+	}
+	return resp, nil
+}
+
+// cacheGroup returns the group of the resource requested: the repository for "/repos/org/repo/..." URLs,
+// the path of the resource otherwise
+func cacheGroup(req *http.Request) string {
+	parts := strings.SplitN(strings.Trim(req.URL.Path, "/"), "/", 4)
+	if len(parts) >= 3 && parts[0] == "repos" {
+		return req.URL.Host + "/repos/" + parts[1] + "/" + parts[2]
+	}
+	return req.URL.Host + req.URL.Path
+}
+
+// cacheKey returns the key of the cached response of the request. Responses depend on the requested media type,
+// and on who requests them, so the same cache can be shared by clients with different tokens.
+func cacheKey(req *http.Request) string {
+	return strings.Join([]string{req.URL.String(), req.Header.Get("Accept"), hash(req.Header.Get("Authorization"))}, "\n")
+}
+
+// MemoryCacheStorage is a CacheStorage keeping the responses in memory
+type MemoryCacheStorage struct {
+	mutex  sync.Mutex
+	groups map[string]map[string][]byte
+}
+
+// NewMemoryCacheStorage returns an empty MemoryCacheStorage
+func NewMemoryCacheStorage() *MemoryCacheStorage {
+	return &MemoryCacheStorage{groups: make(map[string]map[string][]byte)}
+}
+
+// Get returns the cached value of the key, if any
+func (s *MemoryCacheStorage) Get(group, key string) ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, ok := s.groups[group][key]
+	return value, ok
+}
+
+// Set caches the value of the key
+func (s *MemoryCacheStorage) Set(group, key string, value []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.groups[group]; !ok {
+		s.groups[group] = make(map[string][]byte)
+	}
+	s.groups[group][key] = value
+}
+
+// DeleteGroup removes the cached values of all keys in the group
+func (s *MemoryCacheStorage) DeleteGroup(group string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.groups, group)
+}
+
+// DiskCacheStorage is a CacheStorage keeping the responses in files, so that they can be reused by later runs
+// The files of a group are in a dir named after the hash of the group, and named after the hash of their key
+type DiskCacheStorage struct {
+	Dir string
+}
+
+// NewDiskCacheStorage returns a DiskCacheStorage keeping the responses in the given dir, creating it if needed
+func NewDiskCacheStorage(dir string) (*DiskCacheStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCacheStorage{Dir: dir}, nil
+}
+
+// Get returns the cached value of the key, if any
+func (s *DiskCacheStorage) Get(group, key string) ([]byte, bool) {
+	value, err := ioutil.ReadFile(s.path(group, key))
+	return value, err == nil
+}
+
+// Set caches the value of the key. Errors are only logged, as the response is then requested again next time.
+func (s *DiskCacheStorage) Set(group, key string, value []byte) {
+	path := s.path(group, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("error caching response: %v", err)
+		return
+	}
+	// Write to a temporary file first, so that concurrent reads never get a partial response.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, value, 0644); err != nil {
+		log.Printf("error caching response: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("error caching response: %v", err)
+	}
+}
+
+// DeleteGroup removes the cached values of all keys in the group
+func (s *DiskCacheStorage) DeleteGroup(group string) {
+	if err := os.RemoveAll(filepath.Join(s.Dir, hash(group))); err != nil {
+		log.Printf("error invalidating cached responses: %v", err)
+	}
+}
+
+func (s *DiskCacheStorage) path(group, key string) string {
+	return filepath.Join(s.Dir, hash(group), hash(key))
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
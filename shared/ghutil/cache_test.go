@@ -0,0 +1,136 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// newCacheTestClient returns a client caching the responses of a server listing the comments of
+// knative/serving#1, the server to close, and a function returning the number of full responses the server sent.
+func newCacheTestClient(t *testing.T, storage CacheStorage) (*GithubClient, *httptest.Server, func() int) {
+	comments := []string{"first"}
+	// Github may keep answering 304 for a while after a write, the ETag isn't updated when a comment is added.
+	etag := `"v1"`
+	sent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/knative/serving/issues/1/comments" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			sent++
+			w.Header().Set("ETag", etag)
+			fmt.Fprint(w, "[")
+			for i, comment := range comments {
+				if i > 0 {
+					fmt.Fprint(w, ",")
+				}
+				fmt.Fprintf(w, `{"id": %d, "body": %q}`, i+1, comment)
+			}
+			fmt.Fprint(w, "]")
+		case http.MethodPost:
+			comments = append(comments, "second")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id": %d, "body": "second"}`, len(comments))
+		}
+	}))
+	cache := NewCachingTransport(nil, storage)
+	client := github.NewClient(&http.Client{Transport: cache})
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return &GithubClient{Client: client, cache: cache}, server, func() int { return sent }
+}
+
+func TestCachingTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghutil-cache")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	diskStorage, err := NewDiskCacheStorage(dir)
+	if err != nil {
+		t.Fatalf("Cannot create disk storage: %v", err)
+	}
+
+	var tests = []struct {
+		name    string
+		storage CacheStorage
+	}{
+		{"memory", NewMemoryCacheStorage()},
+		{"disk", diskStorage},
+	}
+	for _, test := range tests {
+		client, server, sent := newCacheTestClient(t, test.storage)
+		listComments := func(expected int) {
+			comments, err := client.ListComments("knative", "serving", 1)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", test.name, err)
+			}
+			if len(comments) != expected {
+				t.Errorf("%s: expected %d comments, got %d", test.name, expected, len(comments))
+			}
+		}
+
+		listComments(1)
+		listComments(1)
+		if stats := client.CacheStats(); stats != (CacheStats{Hits: 1, Misses: 1}) || sent() != 1 {
+			t.Errorf("%s: expected 1 hit and 1 miss with 1 response sent, got %+v with %d responses sent", test.name, stats, sent())
+		}
+
+		if _, err := client.CreateComment("knative", "serving", 1, "second"); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		listComments(2)
+		listComments(2)
+		if stats := client.CacheStats(); stats != (CacheStats{Hits: 2, Misses: 2}) || sent() != 2 {
+			t.Errorf("%s: expected 2 hits and 2 misses with 2 responses sent, got %+v with %d responses sent", test.name, stats, sent())
+		}
+		server.Close()
+	}
+}
+
+func TestCacheGroup(t *testing.T) {
+	var tests = []struct {
+		url   string
+		group string
+	}{
+		{"https://api.github.com/repos/knative/serving/issues/1/comments?page=2", "api.github.com/repos/knative/serving"},
+		{"https://api.github.com/repos/knative/serving", "api.github.com/repos/knative/serving"},
+		{"https://api.github.com/orgs/knative/repos", "api.github.com/orgs/knative/repos"},
+		{"https://api.github.com/user", "api.github.com/user"},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+		if group := cacheGroup(req); group != test.group {
+			t.Errorf("Expected group %q for %s, got %q", test.group, test.url, group)
+		}
+	}
+}
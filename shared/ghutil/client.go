@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"strings"
 	"time"
 
@@ -33,16 +34,23 @@ import (
 const (
 	maxRetryCount = 5
 	tokenReserve  = 50
+	// Wait before retrying after a secondary rate limit error, if Github doesn't say how long
+	defaultRetryAfter = time.Minute
+	// Wait before retrying after a server error, multiplied by the number of retries so far
+	serverErrorBackoff = 5 * time.Second
 )
 
 var (
 	ctx = context.Background()
+	// sleep is replaced in tests, so that they don't wait
+	sleep = time.Sleep
 )
 
 // GithubOperations contains a set of functions for Github operations
 type GithubOperations interface {
 	GetGithubUser() (*github.User, error)
 	ListRepos(org string) ([]string, error)
+	ListBranches(org, repo string) ([]*github.Branch, error)
 	ListIssuesByRepo(org, repo string, labels []string) ([]*github.Issue, error)
 	CreateIssue(org, repo, title, body string) (*github.Issue, error)
 	CloseIssue(org, repo string, issueNumber int) error
@@ -59,16 +67,63 @@ type GithubOperations interface {
 	ListCommits(org, repo string, ID int) ([]*github.RepositoryCommit, error)
 	ListFiles(org, repo string, ID int) ([]*github.CommitFile, error)
 	CreatePullRequest(org, repo, head, base, title, body string) (*github.PullRequest, error)
+	ListReviews(org, repo string, ID int) ([]*github.PullRequestReview, error)
+	CreateReview(org, repo string, ID int, review *github.PullRequestReviewRequest) (*github.PullRequestReview, error)
+	CreateStatus(org, repo, SHA string, status *github.RepoStatus) (*github.RepoStatus, error)
+	ListStatuses(org, repo, ref string) ([]*github.RepoStatus, error)
+	CreateCheckRun(org, repo string, opt github.CreateCheckRunOptions) (*github.CheckRun, error)
+	UpdateCheckRun(org, repo string, checkRunID int64, opt github.UpdateCheckRunOptions) (*github.CheckRun, error)
+	GetRef(org, repo, ref string) (*github.Reference, error)
+	CreateRef(org, repo, ref, SHA string) (*github.Reference, error)
+	UpdateRef(org, repo, ref, SHA string, force bool) (*github.Reference, error)
+	GetGitCommit(org, repo, SHA string) (*github.Commit, error)
+	GetBlob(org, repo, SHA string) ([]byte, error)
+	CreateBlob(org, repo string, content []byte) (string, error)
+	CreateTree(org, repo, baseTree string, entries []github.TreeEntry) (*github.Tree, error)
+	CreateGitCommit(org, repo, message, tree string, parents []string) (*github.Commit, error)
+	GetFileContent(org, repo, path, ref string) ([]byte, error)
 }
 
 // GithubClient provides methods to perform github operations
 // It implements all functions in GithubOperations
 type GithubClient struct {
 	Client *github.Client
+
+	cache *CachingTransport
+	app   *AppTokenSource
 }
 
 // NewGithubClient explicitly authenticates to github with giving token and returns a handle
 func NewGithubClient(tokenFilePath string) (*GithubClient, error) {
+	httpClient, err := newOAuthClient(ctx, tokenFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return &GithubClient{Client: github.NewClient(httpClient)}, nil
+}
+
+// NewCachedGithubClient authenticates to github like NewGithubClient, and caches the responses in the given storage,
+// see CachingTransport
+func NewCachedGithubClient(tokenFilePath string, storage CacheStorage) (*GithubClient, error) {
+	cache := NewCachingTransport(nil, storage)
+	httpClient, err := newOAuthClient(context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: cache}), tokenFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return &GithubClient{Client: github.NewClient(httpClient), cache: cache}, nil
+}
+
+// CacheStats returns the counters of the cache of the client, zero if it has none
+func (gc *GithubClient) CacheStats() CacheStats {
+	if gc.cache == nil {
+		return CacheStats{}
+	}
+	return gc.cache.Stats()
+}
+
+// newOAuthClient returns an http client authenticating to github with the token in the given file,
+// sending the requests through the http client of the given context, if any
+func newOAuthClient(ctx context.Context, tokenFilePath string) (*http.Client, error) {
 	b, err := ioutil.ReadFile(tokenFilePath)
 	if err != nil {
 		return nil, err
@@ -76,39 +131,60 @@ func NewGithubClient(tokenFilePath string) (*GithubClient, error) {
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: strings.TrimSpace(string(b))},
 	)
-
-	return &GithubClient{github.NewClient(oauth2.NewClient(ctx, ts))}, nil
+	return oauth2.NewClient(ctx, ts), nil
 }
 
 // GetGithubUser gets current authenticated user
 func (gc *GithubClient) GetGithubUser() (*github.User, error) {
+	login := ""
+	// An app installation isn't a user, it acts as the bot user named after the app
+	if gc.app != nil {
+		slug, err := gc.app.appSlug()
+		if err != nil {
+			return nil, err
+		}
+		login = slug + "[bot]"
+	}
 	var res *github.User
-	_, err := gc.retry(
+	_, err := retry(
 		"getting current user",
 		maxRetryCount,
 		func() (*github.Response, error) {
 			var resp *github.Response
 			var err error
-			res, resp, err = gc.Client.Users.Get(ctx, "")
+			res, resp, err = gc.Client.Users.Get(ctx, login)
 			return resp, err
 		},
 	)
 	return res, err
 }
 
-func (gc *GithubClient) waitForRateReset(r *github.Rate) {
+func waitForRateReset(r *github.Rate) {
 	if r.Remaining <= tokenReserve {
 		sleepDuration := time.Until(r.Reset.Time) + (time.Second * 10)
 		if sleepDuration > 0 {
 			log.Printf("--Rate Limiting-- GitHub tokens reached minimum reserve %d. Sleeping %ds until reset.\n", tokenReserve, sleepDuration)
-			time.Sleep(sleepDuration)
+			sleep(sleepDuration)
 		}
 	}
 }
 
 // Github API has a rate limit, retry waits until rate limit reset if request failed with RateLimitError,
-// then retry maxRetries times until succeed
-func (gc *GithubClient) retry(message string, maxRetries int, call func() (*github.Response, error)) (*github.Response, error) {
+// or as long as Github asks if it failed with AbuseRateLimitError (secondary rate limit), then retry
+// maxRetries times until succeed. Server errors aren't retried, as Github may have processed the request
+// (like creating an issue) before failing. It's shared by the REST and GraphQL clients.
+func retry(message string, maxRetries int, call func() (*github.Response, error)) (*github.Response, error) {
+	return retryRequest(message, maxRetries, false, call)
+}
+
+// retryRead is retry for the requests without side effects, like the GraphQL queries, also retrying them
+// a bit later each time if they failed with a server error, as they can safely be sent again
+func retryRead(message string, maxRetries int, call func() (*github.Response, error)) (*github.Response, error) {
+	return retryRequest(message, maxRetries, true, call)
+}
+
+// retryRequest implements retry and retryRead, retrying after server errors only if retryServerErrors is set
+func retryRequest(message string, maxRetries int, retryServerErrors bool, call func() (*github.Response, error)) (*github.Response, error) {
 	var err error
 	var resp *github.Response
 
@@ -118,7 +194,18 @@ func (gc *GithubClient) retry(message string, maxRetries int, call func() (*gith
 		}
 		switch err := err.(type) {
 		case *github.RateLimitError:
-			gc.waitForRateReset(&err.Rate)
+			waitForRateReset(&err.Rate)
+		case *github.AbuseRateLimitError:
+			retryAfter := defaultRetryAfter
+			if nil != err.RetryAfter {
+				retryAfter = *err.RetryAfter
+			}
+			sleep(retryAfter)
+		case *github.ErrorResponse:
+			if !retryServerErrors || nil == err.Response || err.Response.StatusCode < http.StatusInternalServerError {
+				return resp, err
+			}
+			sleep(time.Duration(retryCount+1) * serverErrorBackoff)
 		default:
 			return resp, err
 		}
@@ -142,7 +229,7 @@ func (gc *GithubClient) depaginate(message string, maxRetries int, options *gith
 	options.PerPage = 100
 	lastPage := 1
 	for ; options.Page <= lastPage; options.Page++ {
-		resp, err := gc.retry(message, maxRetries, wrapper)
+		resp, err := retry(message, maxRetries, wrapper)
 		if err != nil {
 			return allItems, fmt.Errorf("error while depaginating page %d/%d: %v", options.Page, lastPage, err)
 		}
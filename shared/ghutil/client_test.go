@@ -0,0 +1,204 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghutil
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/knative/test-infra/shared/ghutil/fakeghserver"
+)
+
+// newFakeServerClient returns a client of a new fake Github server, with 2 items per page and
+// 5 issues in knative/serving, the odd ones labeled flaky, and a pull request #6 with 3 files.
+func newFakeServerClient() (*GithubClient, *fakeghserver.FakeGithubServer) {
+	server := fakeghserver.NewFakeGithubServer("knative-bot")
+	server.SetPageSize(2)
+	for i := 1; i <= 5; i++ {
+		if i%2 == 1 {
+			server.AddIssue("knative", "serving", "issue", "flaky")
+		} else {
+			server.AddIssue("knative", "serving", "issue")
+		}
+	}
+	server.AddPullRequest("knative", "serving", "bot:fix", "master", "fix")
+	server.AddCommit("knative", "serving", 6, "abc", "a.go", "b.go")
+	server.AddCommit("knative", "serving", 6, "def", "c.go")
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return &GithubClient{Client: client}, server
+}
+
+func TestGithubClientAgainstFakeServer(t *testing.T) {
+	var sleeps []time.Duration
+	// Rounded up to seconds, as the rate limit reset time is truncated to seconds
+	sleep = func(d time.Duration) { sleeps = append(sleeps, (d + time.Second - 1).Truncate(time.Second)) }
+	defer func() { sleep = time.Sleep }()
+
+	var tests = []struct {
+		name     string
+		setup    func(server *fakeghserver.FakeGithubServer)
+		call     func(gc *GithubClient) (interface{}, error)
+		expected interface{}
+		requests []string
+		sleeps   []time.Duration
+		err      bool
+	}{
+		{
+			name: "all pages",
+			call: func(gc *GithubClient) (interface{}, error) {
+				issues, err := gc.ListIssuesByRepo("knative", "serving", nil)
+				return len(issues), err
+			},
+			expected: 5,
+			requests: []string{
+				"GET /repos/knative/serving/issues?page=1&per_page=100&state=all",
+				"GET /repos/knative/serving/issues?page=2&per_page=100&state=all",
+				"GET /repos/knative/serving/issues?page=3&per_page=100&state=all",
+			},
+		},
+		{
+			name: "filtered pages",
+			call: func(gc *GithubClient) (interface{}, error) {
+				issues, err := gc.ListIssuesByRepo("knative", "serving", []string{"flaky"})
+				var numbers []int
+				for _, issue := range issues {
+					numbers = append(numbers, issue.GetNumber())
+				}
+				return numbers, err
+			},
+			expected: []int{1, 3, 5},
+			requests: []string{
+				"GET /repos/knative/serving/issues?labels=flaky&page=1&per_page=100&state=all",
+				"GET /repos/knative/serving/issues?labels=flaky&page=2&per_page=100&state=all",
+			},
+		},
+		{
+			name: "pull request files",
+			call: func(gc *GithubClient) (interface{}, error) {
+				files, err := gc.ListFiles("knative", "serving", 6)
+				return len(files), err
+			},
+			expected: 3,
+			requests: []string{
+				"GET /repos/knative/serving/pulls/6/files?page=1&per_page=100",
+				"GET /repos/knative/serving/pulls/6/files?page=2&per_page=100",
+			},
+		},
+		{
+			// The comment may have been created before the error, sending it again could duplicate it
+			name: "no retry after server errors",
+			setup: func(server *fakeghserver.FakeGithubServer) {
+				server.InjectServerErrors(http.StatusBadGateway, 1)
+			},
+			call: func(gc *GithubClient) (interface{}, error) {
+				comment, err := gc.CreateComment("knative", "serving", 1, "hello")
+				return comment.GetBody(), err
+			},
+			requests: []string{
+				"POST /repos/knative/serving/issues/1/comments",
+			},
+			err: true,
+		},
+		{
+			name: "not found isn't retried",
+			call: func(gc *GithubClient) (interface{}, error) {
+				return gc.GetPullRequest("knative", "serving", 42)
+			},
+			requests: []string{"GET /repos/knative/serving/pulls/42"},
+			err:      true,
+		},
+		{
+			name: "missing ref",
+			call: func(gc *GithubClient) (interface{}, error) {
+				ref, err := gc.GetRef("knative", "serving", "heads/missing")
+				return nil == ref, err
+			},
+			expected: true,
+			requests: []string{"GET /repos/knative/serving/git/refs/heads/missing"},
+		},
+		{
+			name: "retry after rate limit reset",
+			setup: func(server *fakeghserver.FakeGithubServer) {
+				server.InjectRateLimit(1, time.Now().Add(-5*time.Second))
+			},
+			call: func(gc *GithubClient) (interface{}, error) {
+				return nil, gc.AddLabelsToIssue("knative", "serving", 2, []string{"flaky"})
+			},
+			requests: []string{
+				"POST /repos/knative/serving/issues/2/labels",
+				"POST /repos/knative/serving/issues/2/labels",
+			},
+			sleeps: []time.Duration{5 * time.Second},
+		},
+		{
+			name: "retry after secondary rate limit",
+			setup: func(server *fakeghserver.FakeGithubServer) {
+				server.InjectSecondaryRateLimit(1, 30*time.Second)
+			},
+			call: func(gc *GithubClient) (interface{}, error) {
+				return nil, gc.RemoveLabelForIssue("knative", "serving", 1, "flaky")
+			},
+			requests: []string{
+				"DELETE /repos/knative/serving/issues/1/labels/flaky",
+				"DELETE /repos/knative/serving/issues/1/labels/flaky",
+			},
+			sleeps: []time.Duration{30 * time.Second},
+		},
+	}
+	for _, test := range tests {
+		gc, server := newFakeServerClient()
+		sleeps = nil
+		if test.setup != nil {
+			test.setup(server)
+		}
+		res, err := test.call(gc)
+		if test.err != (err != nil) {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+		}
+		if !test.err && !reflect.DeepEqual(res, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, res)
+		}
+		if requests := server.Requests(); !reflect.DeepEqual(requests, test.requests) {
+			t.Errorf("%s: expected requests %v, got %v", test.name, test.requests, requests)
+		}
+		if !reflect.DeepEqual(sleeps, test.sleeps) {
+			t.Errorf("%s: expected sleeps %v, got %v", test.name, test.sleeps, sleeps)
+		}
+		server.Close()
+	}
+}
+
+func TestFakeServerRateLimitHeaders(t *testing.T) {
+	gc, server := newFakeServerClient()
+	defer server.Close()
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	server.SetRateLimit(2, reset)
+
+	_, resp, err := gc.Client.Users.Get(ctx, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rate := resp.Rate
+	if rate.Limit != 5000 || rate.Remaining != 1 || !rate.Reset.Time.Equal(reset) {
+		t.Errorf("Expected 1 of 5000 requests remaining until %v, got %v", reset, rate)
+	}
+}
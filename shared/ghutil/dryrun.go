@@ -0,0 +1,478 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// dryrun.go provides a GithubOperations recording the writes as a plan instead of sending them to Github
+
+package ghutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	// Numbers and IDs of the objects created in dry run mode start from this, so that they don't look like
+	// the ones of existing objects
+	dryRunFirstNumber = 1000000
+)
+
+// PlannedOperation is a write to Github recorded in dry run mode
+type PlannedOperation struct {
+	Operation string                 `json:"operation"`
+	Org       string                 `json:"org"`
+	Repo      string                 `json:"repo"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+func (op PlannedOperation) String() string {
+	b, _ := json.Marshal(op.Args)
+	return fmt.Sprintf("%s in %s/%s: %s", op.Operation, op.Org, op.Repo, b)
+}
+
+// DryRunGithubClient wraps a GithubOperations: reads go through to it, while writes are logged and recorded
+// as a plan instead of being sent. Writes return plausible objects, so that callers can go on as if they were sent,
+// and the pull requests, refs and git objects created in dry run mode are returned by later reads.
+// It implements all functions in GithubOperations
+type DryRunGithubClient struct {
+	client GithubOperations
+
+	mutex        sync.Mutex
+	plan         []PlannedOperation
+	nextNumber   int
+	user         *github.User
+	pullRequests map[string]*github.PullRequest // pull requests created in dry run mode, map of "org/repo/number": PR
+	refs         map[string]string              // refs created or updated in dry run mode, map of "org/repo/ref": commit SHA
+	blobs        map[string][]byte              // blobs created in dry run mode, map of SHA: content
+	trees        map[string]*github.Tree        // trees created in dry run mode, by SHA
+	gitCommits   map[string]*github.Commit      // commits created in dry run mode, by SHA
+}
+
+// NewDryRunGithubClient returns a DryRunGithubClient wrapping the given client
+func NewDryRunGithubClient(client GithubOperations) *DryRunGithubClient {
+	return &DryRunGithubClient{
+		client:       client,
+		nextNumber:   dryRunFirstNumber,
+		pullRequests: make(map[string]*github.PullRequest),
+		refs:         make(map[string]string),
+		blobs:        make(map[string][]byte),
+		trees:        make(map[string]*github.Tree),
+		gitCommits:   make(map[string]*github.Commit),
+	}
+}
+
+// Plan returns the writes recorded so far, in order
+func (dc *DryRunGithubClient) Plan() []PlannedOperation {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	return append([]PlannedOperation{}, dc.plan...)
+}
+
+// WritePlan writes the writes recorded so far as a JSON array
+func (dc *DryRunGithubClient) WritePlan(w io.Writer) error {
+	b, err := json.MarshalIndent(dc.Plan(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// record logs and records a write, and returns a number for the object it creates, if any
+func (dc *DryRunGithubClient) record(operation, org, repo string, args map[string]interface{}) int {
+	op := PlannedOperation{Operation: operation, Org: org, Repo: repo, Args: args}
+	log.Printf("[dry run] %s", op)
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	dc.plan = append(dc.plan, op)
+	dc.nextNumber++
+	return dc.nextNumber
+}
+
+// author returns the authenticated user, as the author of the objects created
+func (dc *DryRunGithubClient) author() *github.User {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	if nil == dc.user {
+		if user, err := dc.client.GetGithubUser(); nil == err {
+			dc.user = user
+		}
+	}
+	return dc.user
+}
+
+// GetGithubUser gets current authenticated user
+func (dc *DryRunGithubClient) GetGithubUser() (*github.User, error) {
+	return dc.client.GetGithubUser()
+}
+
+// ListRepos lists repos under org
+func (dc *DryRunGithubClient) ListRepos(org string) ([]string, error) {
+	return dc.client.ListRepos(org)
+}
+
+// ListBranches lists branches within given repo
+func (dc *DryRunGithubClient) ListBranches(org, repo string) ([]*github.Branch, error) {
+	return dc.client.ListBranches(org, repo)
+}
+
+// ListIssuesByRepo lists issues within given repo, filters by labels if provided
+func (dc *DryRunGithubClient) ListIssuesByRepo(org, repo string, labels []string) ([]*github.Issue, error) {
+	return dc.client.ListIssuesByRepo(org, repo, labels)
+}
+
+// CreateIssue records the creation of an issue, and returns it
+func (dc *DryRunGithubClient) CreateIssue(org, repo, title, body string) (*github.Issue, error) {
+	number := dc.record("CreateIssue", org, repo, map[string]interface{}{"title": title, "body": body})
+	return &github.Issue{
+		Number:        &number,
+		Title:         &title,
+		Body:          &body,
+		State:         github.String(string(IssueOpenState)),
+		User:          dc.author(),
+		URL:           github.String(fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d", org, repo, number)),
+		HTMLURL:       github.String(fmt.Sprintf("https://github.com/%s/%s/issues/%d", org, repo, number)),
+		RepositoryURL: github.String(fmt.Sprintf("https://api.github.com/repos/%s/%s", org, repo)),
+	}, nil
+}
+
+// CloseIssue records the closing of an issue
+func (dc *DryRunGithubClient) CloseIssue(org, repo string, issueNumber int) error {
+	dc.record("CloseIssue", org, repo, map[string]interface{}{"number": issueNumber})
+	return nil
+}
+
+// ReopenIssue records the reopening of an issue
+func (dc *DryRunGithubClient) ReopenIssue(org, repo string, issueNumber int) error {
+	dc.record("ReopenIssue", org, repo, map[string]interface{}{"number": issueNumber})
+	return nil
+}
+
+// ListComments gets all comments from issue
+func (dc *DryRunGithubClient) ListComments(org, repo string, issueNumber int) ([]*github.IssueComment, error) {
+	return dc.client.ListComments(org, repo, issueNumber)
+}
+
+// GetComment gets comment by comment ID
+func (dc *DryRunGithubClient) GetComment(org, repo string, commentID int64) (*github.IssueComment, error) {
+	return dc.client.GetComment(org, repo, commentID)
+}
+
+// CreateComment records the creation of a comment, and returns it
+func (dc *DryRunGithubClient) CreateComment(org, repo string, issueNumber int, commentBody string) (*github.IssueComment, error) {
+	ID := int64(dc.record("CreateComment", org, repo, map[string]interface{}{"number": issueNumber, "body": commentBody}))
+	return &github.IssueComment{
+		ID:      &ID,
+		Body:    &commentBody,
+		User:    dc.author(),
+		HTMLURL: github.String(fmt.Sprintf("https://github.com/%s/%s/issues/%d#issuecomment-%d", org, repo, issueNumber, ID)),
+	}, nil
+}
+
+// EditComment records the edition of a comment
+func (dc *DryRunGithubClient) EditComment(org, repo string, commentID int64, commentBody string) error {
+	dc.record("EditComment", org, repo, map[string]interface{}{"comment_id": commentID, "body": commentBody})
+	return nil
+}
+
+// AddLabelsToIssue records the addition of labels to an issue
+func (dc *DryRunGithubClient) AddLabelsToIssue(org, repo string, issueNumber int, labels []string) error {
+	dc.record("AddLabelsToIssue", org, repo, map[string]interface{}{"number": issueNumber, "labels": labels})
+	return nil
+}
+
+// RemoveLabelForIssue records the removal of a label from an issue
+func (dc *DryRunGithubClient) RemoveLabelForIssue(org, repo string, issueNumber int, label string) error {
+	dc.record("RemoveLabelForIssue", org, repo, map[string]interface{}{"number": issueNumber, "label": label})
+	return nil
+}
+
+// GetPullRequest gets PullRequest by ID, including the ones created in dry run mode
+func (dc *DryRunGithubClient) GetPullRequest(org, repo string, ID int) (*github.PullRequest, error) {
+	dc.mutex.Lock()
+	PR, ok := dc.pullRequests[dryRunPullRequestKey(org, repo, ID)]
+	dc.mutex.Unlock()
+	if ok {
+		return PR, nil
+	}
+	return dc.client.GetPullRequest(org, repo, ID)
+}
+
+// EditPullRequest records the edition of a PullRequest, and returns it as it would be
+func (dc *DryRunGithubClient) EditPullRequest(org, repo string, ID int, title, body string) (*github.PullRequest, error) {
+	PR, err := dc.GetPullRequest(org, repo, ID)
+	if nil != err {
+		return nil, err
+	}
+	dc.record("EditPullRequest", org, repo, map[string]interface{}{"number": ID, "title": title, "body": body})
+	edited := *PR
+	edited.Title = &title
+	edited.Body = &body
+	return &edited, nil
+}
+
+// ListPullRequests lists pull requests within given repo, filters by head user and branch name if
+// provided as "user:ref-name", and by base name if provided, i.e. "master"
+func (dc *DryRunGithubClient) ListPullRequests(org, repo, head, base string) ([]*github.PullRequest, error) {
+	return dc.client.ListPullRequests(org, repo, head, base)
+}
+
+// ListCommits lists commits from a pull request
+func (dc *DryRunGithubClient) ListCommits(org, repo string, ID int) ([]*github.RepositoryCommit, error) {
+	return dc.client.ListCommits(org, repo, ID)
+}
+
+// ListFiles lists files from a pull request
+func (dc *DryRunGithubClient) ListFiles(org, repo string, ID int) ([]*github.CommitFile, error) {
+	return dc.client.ListFiles(org, repo, ID)
+}
+
+// CreatePullRequest records the creation of a PullRequest, and returns it
+func (dc *DryRunGithubClient) CreatePullRequest(org, repo, head, base, title, body string) (*github.PullRequest, error) {
+	number := dc.record("CreatePullRequest", org, repo, map[string]interface{}{"head": head, "base": base, "title": title, "body": body})
+	// head is either "branch" or "user:branch"
+	headRef := head
+	if parts := strings.SplitN(head, ":", 2); len(parts) == 2 {
+		headRef = parts[1]
+	}
+	PR := &github.PullRequest{
+		Number:              &number,
+		Title:               &title,
+		Body:                &body,
+		State:               github.String(string(PullRequestOpenState)),
+		User:                dc.author(),
+		MaintainerCanModify: github.Bool(true),
+		Head:                &github.PullRequestBranch{Label: &head, Ref: &headRef},
+		Base:                &github.PullRequestBranch{Label: github.String(org + ":" + base), Ref: &base},
+		HTMLURL:             github.String(fmt.Sprintf("https://github.com/%s/%s/pull/%d", org, repo, number)),
+	}
+	dc.mutex.Lock()
+	dc.pullRequests[dryRunPullRequestKey(org, repo, number)] = PR
+	dc.mutex.Unlock()
+	return PR, nil
+}
+
+// ListReviews lists the reviews of a pull request
+func (dc *DryRunGithubClient) ListReviews(org, repo string, ID int) ([]*github.PullRequestReview, error) {
+	return dc.client.ListReviews(org, repo, ID)
+}
+
+// CreateReview records the creation of a review, and returns it
+func (dc *DryRunGithubClient) CreateReview(org, repo string, ID int, review *github.PullRequestReviewRequest) (*github.PullRequestReview, error) {
+	reviewID := int64(dc.record("CreateReview", org, repo, map[string]interface{}{"number": ID, "review": review}))
+	return &github.PullRequestReview{
+		ID:       &reviewID,
+		User:     dc.author(),
+		Body:     review.Body,
+		CommitID: review.CommitID,
+		State:    github.String("PENDING"),
+	}, nil
+}
+
+// CreateStatus records the creation of a commit status, and returns it
+func (dc *DryRunGithubClient) CreateStatus(org, repo, SHA string, status *github.RepoStatus) (*github.RepoStatus, error) {
+	statusID := int64(dc.record("CreateStatus", org, repo, map[string]interface{}{"sha": SHA, "status": status}))
+	created := *status
+	created.ID = &statusID
+	created.Creator = dc.author()
+	return &created, nil
+}
+
+// ListStatuses lists the statuses of the given ref (a commit SHA, branch or tag), latest first
+func (dc *DryRunGithubClient) ListStatuses(org, repo, ref string) ([]*github.RepoStatus, error) {
+	return dc.client.ListStatuses(org, repo, ref)
+}
+
+// CreateCheckRun records the creation of a check run, and returns it
+func (dc *DryRunGithubClient) CreateCheckRun(org, repo string, opt github.CreateCheckRunOptions) (*github.CheckRun, error) {
+	checkRunID := int64(dc.record("CreateCheckRun", org, repo, map[string]interface{}{"check_run": opt}))
+	return &github.CheckRun{
+		ID:         &checkRunID,
+		Name:       &opt.Name,
+		HeadSHA:    &opt.HeadSHA,
+		Status:     opt.Status,
+		Conclusion: opt.Conclusion,
+		Output:     opt.Output,
+	}, nil
+}
+
+// UpdateCheckRun records the update of a check run, and returns it with the given fields
+func (dc *DryRunGithubClient) UpdateCheckRun(org, repo string, checkRunID int64, opt github.UpdateCheckRunOptions) (*github.CheckRun, error) {
+	dc.record("UpdateCheckRun", org, repo, map[string]interface{}{"check_run_id": checkRunID, "check_run": opt})
+	return &github.CheckRun{
+		ID:         &checkRunID,
+		Name:       &opt.Name,
+		HeadSHA:    opt.HeadSHA,
+		Status:     opt.Status,
+		Conclusion: opt.Conclusion,
+		Output:     opt.Output,
+	}, nil
+}
+
+// GetRef gets a ref, i.e. "heads/master", including the ones created or updated in dry run mode,
+// returns nil if it doesn't exist
+func (dc *DryRunGithubClient) GetRef(org, repo, ref string) (*github.Reference, error) {
+	dc.mutex.Lock()
+	SHA, ok := dc.refs[dryRunRefKey(org, repo, ref)]
+	dc.mutex.Unlock()
+	if ok {
+		return dryRunReference(ref, SHA), nil
+	}
+	return dc.client.GetRef(org, repo, ref)
+}
+
+// CreateRef records the creation of a ref, and returns it
+func (dc *DryRunGithubClient) CreateRef(org, repo, ref, SHA string) (*github.Reference, error) {
+	dc.record("CreateRef", org, repo, map[string]interface{}{"ref": ref, "sha": SHA})
+	dc.mutex.Lock()
+	dc.refs[dryRunRefKey(org, repo, ref)] = SHA
+	dc.mutex.Unlock()
+	return dryRunReference(ref, SHA), nil
+}
+
+// UpdateRef records the update of a ref, and returns it
+func (dc *DryRunGithubClient) UpdateRef(org, repo, ref, SHA string, force bool) (*github.Reference, error) {
+	dc.record("UpdateRef", org, repo, map[string]interface{}{"ref": ref, "sha": SHA, "force": force})
+	dc.mutex.Lock()
+	dc.refs[dryRunRefKey(org, repo, ref)] = SHA
+	dc.mutex.Unlock()
+	return dryRunReference(ref, SHA), nil
+}
+
+// GetGitCommit gets a git commit by SHA, including the ones created in dry run mode
+func (dc *DryRunGithubClient) GetGitCommit(org, repo, SHA string) (*github.Commit, error) {
+	dc.mutex.Lock()
+	commit, ok := dc.gitCommits[SHA]
+	dc.mutex.Unlock()
+	if ok {
+		return commit, nil
+	}
+	return dc.client.GetGitCommit(org, repo, SHA)
+}
+
+// GetBlob gets the content of the blob with the given SHA, including the blobs created in dry run mode
+func (dc *DryRunGithubClient) GetBlob(org, repo, SHA string) ([]byte, error) {
+	dc.mutex.Lock()
+	content, ok := dc.blobs[SHA]
+	dc.mutex.Unlock()
+	if ok {
+		return content, nil
+	}
+	return dc.client.GetBlob(org, repo, SHA)
+}
+
+// CreateBlob records the creation of a blob, and returns a SHA for it
+func (dc *DryRunGithubClient) CreateBlob(org, repo string, content []byte) (string, error) {
+	SHA := dryRunSHA(dc.record("CreateBlob", org, repo, map[string]interface{}{"content": string(content)}))
+	dc.mutex.Lock()
+	dc.blobs[SHA] = append([]byte{}, content...)
+	dc.mutex.Unlock()
+	return SHA, nil
+}
+
+// CreateTree records the creation of a tree, and returns it
+func (dc *DryRunGithubClient) CreateTree(org, repo, baseTree string, entries []github.TreeEntry) (*github.Tree, error) {
+	SHA := dryRunSHA(dc.record("CreateTree", org, repo, map[string]interface{}{"base_tree": baseTree, "entries": entries}))
+	tree := &github.Tree{SHA: &SHA, Entries: entries}
+	dc.mutex.Lock()
+	dc.trees[SHA] = tree
+	dc.mutex.Unlock()
+	return tree, nil
+}
+
+// CreateGitCommit records the creation of a commit, and returns it
+func (dc *DryRunGithubClient) CreateGitCommit(org, repo, message, tree string, parents []string) (*github.Commit, error) {
+	SHA := dryRunSHA(dc.record("CreateGitCommit", org, repo, map[string]interface{}{"message": message, "tree": tree, "parents": parents}))
+	commit := &github.Commit{SHA: &SHA, Message: &message, Tree: &github.Tree{SHA: &tree}}
+	for i := range parents {
+		commit.Parents = append(commit.Parents, github.Commit{SHA: &parents[i]})
+	}
+	dc.mutex.Lock()
+	dc.gitCommits[SHA] = commit
+	dc.mutex.Unlock()
+	return commit, nil
+}
+
+// GetFileContent gets the content of a file at the given ref (a commit SHA, branch or tag), including the refs
+// and commits created in dry run mode. Files that a commit created in dry run mode doesn't change are read
+// at its first parent, as its tree is created on top of the tree of its parent
+func (dc *DryRunGithubClient) GetFileContent(org, repo, path, ref string) ([]byte, error) {
+	dc.mutex.Lock()
+	SHA := ref
+	for _, name := range []string{ref, "heads/" + ref, "tags/" + ref} {
+		if refSHA, ok := dc.refs[dryRunRefKey(org, repo, name)]; ok {
+			SHA = refSHA
+			break
+		}
+	}
+	commit, ok := dc.gitCommits[SHA]
+	var tree *github.Tree
+	if ok {
+		tree = dc.trees[commit.GetTree().GetSHA()]
+	}
+	dc.mutex.Unlock()
+	if !ok {
+		return dc.client.GetFileContent(org, repo, path, SHA)
+	}
+	if nil != tree {
+		for _, entry := range tree.Entries {
+			if entry.GetPath() != path {
+				continue
+			}
+			switch {
+			case nil != entry.Content:
+				return []byte(entry.GetContent()), nil
+			case nil != entry.SHA:
+				// The blob was either created in dry run mode, or already exists on Github
+				return dc.GetBlob(org, repo, entry.GetSHA())
+			default:
+				return nil, fmt.Errorf("file '%s' is removed at '%s'", path, ref)
+			}
+		}
+	}
+	if len(commit.Parents) == 0 {
+		return nil, fmt.Errorf("file '%s' doesn't exist at '%s'", path, ref)
+	}
+	return dc.GetFileContent(org, repo, path, commit.Parents[0].GetSHA())
+}
+
+// dryRunPullRequestKey returns the key of a pull request in the given repo
+func dryRunPullRequestKey(org, repo string, number int) string {
+	return fmt.Sprintf("%s/%s/%d", org, repo, number)
+}
+
+// dryRunRefKey returns the key of a ref in the given repo, whether it starts with "refs/" or not
+func dryRunRefKey(org, repo, ref string) string {
+	return fmt.Sprintf("%s/%s/%s", org, repo, strings.TrimPrefix(ref, "refs/"))
+}
+
+// dryRunReference returns a ref pointing to the given commit SHA, as returned by Github
+func dryRunReference(ref, SHA string) *github.Reference {
+	return &github.Reference{
+		Ref:    github.String("refs/" + strings.TrimPrefix(ref, "refs/")),
+		Object: &github.GitObject{Type: github.String("commit"), SHA: &SHA},
+	}
+}
+
+// dryRunSHA returns a SHA for the git object created in dry run mode with the given number
+func dryRunSHA(number int) string {
+	return fmt.Sprintf("%040x", number)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDryRunGithubClient(t *testing.T) {
+	gc, server := newFakeServerClient()
+	defer server.Close()
+	dc := NewDryRunGithubClient(gc)
+	var _ GithubOperations = dc
+
+	// Reads go through
+	issues, err := dc.ListIssuesByRepo("knative", "serving", []string{"flaky"})
+	if err != nil || len(issues) != 3 {
+		t.Fatalf("Expected 3 flaky issues, got %d, error %v", len(issues), err)
+	}
+
+	// Writes return objects that later steps can use
+	issue, err := dc.CreateIssue("knative", "serving", "flaky test", "it's flaky")
+	if err != nil {
+		t.Fatalf("Unexpected error creating issue: %v", err)
+	}
+	if issue.GetNumber() <= 0 || issue.GetTitle() != "flaky test" || issue.GetUser().GetLogin() != "knative-bot" {
+		t.Errorf("Expected an issue by knative-bot with a number, got %v", issue)
+	}
+	if !strings.HasSuffix(issue.GetRepositoryURL(), "/repos/knative/serving") {
+		t.Errorf("Expected the repository URL of knative/serving, got %q", issue.GetRepositoryURL())
+	}
+	comment, err := dc.CreateComment("knative", "serving", issue.GetNumber(), "hello")
+	if err != nil || comment.GetID() == 0 || comment.GetBody() != "hello" {
+		t.Errorf("Expected a comment with an ID, got %v, error %v", comment, err)
+	}
+	dc.EditComment("knative", "serving", comment.GetID(), "bye")
+	dc.AddLabelsToIssue("knative", "serving", issue.GetNumber(), []string{"flaky"})
+	dc.CloseIssue("knative", "serving", 1)
+	PR, err := dc.CreatePullRequest("knative", "serving", "bot:bump", "master", "bump", "")
+	if err != nil || PR.GetHead().GetRef() != "bump" || PR.GetBase().GetRef() != "master" {
+		t.Errorf("Expected a pull request from bump to master, got %v, error %v", PR, err)
+	}
+	// Editing a pull request created in dry run mode doesn't read it from Github
+	edited, err := dc.EditPullRequest("knative", "serving", PR.GetNumber(), "bump again", "")
+	if err != nil || edited.GetTitle() != "bump again" || edited.GetNumber() != PR.GetNumber() {
+		t.Errorf("Expected the edited pull request, got %v, error %v", edited, err)
+	}
+	// Pull requests created in dry run mode only exist in their repo
+	if other, err := dc.GetPullRequest("knative", "eventing", PR.GetNumber()); err == nil {
+		t.Errorf("Expected no pull request %d in knative/eventing, got %v", PR.GetNumber(), other)
+	}
+
+	expectedRequests := []string{
+		"GET /repos/knative/serving/issues?labels=flaky&page=1&per_page=100&state=all",
+		"GET /repos/knative/serving/issues?labels=flaky&page=2&per_page=100&state=all",
+		"GET /user",
+		fmt.Sprintf("GET /repos/knative/eventing/pulls/%d", PR.GetNumber()),
+	}
+	if requests := server.Requests(); !reflect.DeepEqual(requests, expectedRequests) {
+		t.Errorf("Expected only reads to be sent, got %v", requests)
+	}
+
+	var operations []string
+	for _, op := range dc.Plan() {
+		operations = append(operations, op.Operation)
+	}
+	expectedOperations := []string{"CreateIssue", "CreateComment", "EditComment", "AddLabelsToIssue", "CloseIssue", "CreatePullRequest", "EditPullRequest"}
+	if !reflect.DeepEqual(operations, expectedOperations) {
+		t.Errorf("Expected plan %v, got %v", expectedOperations, operations)
+	}
+
+	var buf bytes.Buffer
+	if err := dc.WritePlan(&buf); err != nil {
+		t.Fatalf("Unexpected error writing plan: %v", err)
+	}
+	var plan []PlannedOperation
+	if err := json.Unmarshal(buf.Bytes(), &plan); err != nil {
+		t.Fatalf("Expected the plan as JSON, got %q: %v", buf.String(), err)
+	}
+	if len(plan) != len(expectedOperations) || plan[2].Args["body"] != "bye" || plan[4].Repo != "serving" {
+		t.Errorf("Expected the plan to have the arguments of the writes, got %s", buf.String())
+	}
+}
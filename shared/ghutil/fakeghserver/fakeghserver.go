@@ -0,0 +1,721 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// fakeghserver.go fakes the Github REST API over HTTP, so that the real GithubClient can be tested against it,
+// including its pagination, retries and rate limit handling
+
+package fakeghserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	defaultPageSize  = 30
+	defaultRateLimit = 5000
+)
+
+// FakeGithubServer is an httptest server faking the Github REST API, for the issues, comments, labels, pull requests,
+// files, commits, branches and repos of the GithubOperations. The server reports its rate limit in the response headers,
+// paginates the lists, and can be told to fail the next requests.
+// Point a github.Client at it by setting its BaseURL to URL + "/".
+type FakeGithubServer struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	user      *github.User
+	pageSize  int
+	rateLimit int
+	remaining int
+	reset     time.Time
+	repos     map[string]*repoData // map of "org/repo": repo data
+	nextID    int64
+	faults    []func(w http.ResponseWriter)
+	requests  []string
+}
+
+// repoData is the content of a repository, issues and pull requests share their numbers like on Github
+type repoData struct {
+	issues     map[int]*github.Issue
+	comments   map[int][]*github.IssueComment // map of issue number: comments
+	pulls      map[int]*github.PullRequest
+	files      map[int][]*github.CommitFile       // map of PR number: files
+	commits    map[int][]*github.RepositoryCommit // map of PR number: commits
+	branches   []*github.Branch
+	nextNumber int
+}
+
+// NewFakeGithubServer starts a FakeGithubServer authenticating all requests as the given user
+// The caller should Close it when done.
+func NewFakeGithubServer(login string) *FakeGithubServer {
+	s := &FakeGithubServer{
+		user:      &github.User{ID: github.Int64(1), Login: github.String(login)},
+		pageSize:  defaultPageSize,
+		rateLimit: defaultRateLimit,
+		remaining: defaultRateLimit,
+		reset:     time.Now().Add(time.Hour),
+		repos:     make(map[string]*repoData),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// SetPageSize sets the maximum number of items per page of the lists, so that tests get several pages
+// with few items
+func (s *FakeGithubServer) SetPageSize(size int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pageSize = size
+}
+
+// SetRateLimit sets the number of requests remaining until the given reset time, after which the server
+// allows the default 5000 requests per hour again. Requests beyond it fail with a rate limit error.
+func (s *FakeGithubServer) SetRateLimit(remaining int, reset time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.remaining = remaining
+	s.reset = reset
+}
+
+// InjectServerErrors makes the next count requests fail with the given status, like 502
+func (s *FakeGithubServer) InjectServerErrors(status, count int) {
+	s.injectFaults(count, func(w http.ResponseWriter) {
+		writeJSON(w, status, map[string]string{"message": http.StatusText(status)})
+	})
+}
+
+// InjectRateLimit makes the next count requests fail with a rate limit error, as if no requests remained
+// until the given reset time
+func (s *FakeGithubServer) InjectRateLimit(count int, reset time.Time) {
+	s.injectFaults(count, func(w http.ResponseWriter) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		writeJSON(w, http.StatusForbidden, map[string]string{
+			"message":           "API rate limit exceeded for user ID 1.",
+			"documentation_url": "https://developer.github.com/v3/#rate-limiting",
+		})
+	})
+}
+
+// InjectSecondaryRateLimit makes the next count requests fail with a secondary (abuse) rate limit error,
+// asking to retry after the given duration, rounded to seconds
+func (s *FakeGithubServer) InjectSecondaryRateLimit(count int, retryAfter time.Duration) {
+	s.injectFaults(count, func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		writeJSON(w, http.StatusForbidden, map[string]string{
+			"message":           "You have triggered an abuse detection mechanism. Please wait a few minutes before you try again.",
+			"documentation_url": "https://developer.github.com/v3/#abuse-rate-limits",
+		})
+	})
+}
+
+func (s *FakeGithubServer) injectFaults(count int, fault func(w http.ResponseWriter)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := 0; i < count; i++ {
+		s.faults = append(s.faults, fault)
+	}
+}
+
+// Requests returns the requests received so far, like "GET /repos/org/repo/issues?page=2"
+func (s *FakeGithubServer) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// AddBranch adds a branch to the repo, creating the repo if needed
+func (s *FakeGithubServer) AddBranch(org, repo, name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	rd := s.repo(org, repo)
+	rd.branches = append(rd.branches, &github.Branch{Name: github.String(name)})
+}
+
+// AddIssue adds an open issue with the given labels to the repo, creating the repo if needed
+func (s *FakeGithubServer) AddIssue(org, repo, title string, labels ...string) *github.Issue {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.createIssue(org, repo, s.user, title, "", labels)
+}
+
+// AddComment adds a comment of the given user to the issue or pull request
+func (s *FakeGithubServer) AddComment(org, repo string, number int, login, body string) *github.IssueComment {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.createComment(org, repo, number, &github.User{Login: github.String(login)}, body)
+}
+
+// AddPullRequest adds an open pull request to the repo, from head ("user:branch") to base ("master"),
+// creating the repo if needed
+func (s *FakeGithubServer) AddPullRequest(org, repo, head, base, title string) *github.PullRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.createPullRequest(org, repo, head, base, title, "")
+}
+
+// AddCommit adds a commit changing the given files to the pull request
+func (s *FakeGithubServer) AddCommit(org, repo string, number int, SHA string, filenames ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	rd := s.repo(org, repo)
+	rd.commits[number] = append(rd.commits[number], &github.RepositoryCommit{SHA: github.String(SHA)})
+	for _, filename := range filenames {
+		rd.files[number] = append(rd.files[number], &github.CommitFile{Filename: github.String(filename)})
+	}
+}
+
+// repo returns the data of the repo, creating it if needed
+func (s *FakeGithubServer) repo(org, repo string) *repoData {
+	name := org + "/" + repo
+	if _, ok := s.repos[name]; !ok {
+		s.repos[name] = &repoData{
+			issues:     make(map[int]*github.Issue),
+			comments:   make(map[int][]*github.IssueComment),
+			pulls:      make(map[int]*github.PullRequest),
+			files:      make(map[int][]*github.CommitFile),
+			commits:    make(map[int][]*github.RepositoryCommit),
+			nextNumber: 1,
+		}
+	}
+	return s.repos[name]
+}
+
+func (s *FakeGithubServer) getNextID() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *FakeGithubServer) createIssue(org, repo string, user *github.User, title, body string, labels []string) *github.Issue {
+	rd := s.repo(org, repo)
+	number := rd.nextNumber
+	rd.nextNumber++
+	now := time.Now()
+	issue := &github.Issue{
+		ID:            github.Int64(s.getNextID()),
+		Number:        github.Int(number),
+		Title:         github.String(title),
+		Body:          github.String(body),
+		State:         github.String("open"),
+		User:          user,
+		CreatedAt:     &now,
+		URL:           github.String(fmt.Sprintf("%s/repos/%s/%s/issues/%d", s.URL, org, repo, number)),
+		RepositoryURL: github.String(fmt.Sprintf("%s/repos/%s/%s", s.URL, org, repo)),
+	}
+	for _, label := range labels {
+		issue.Labels = append(issue.Labels, github.Label{Name: github.String(label)})
+	}
+	rd.issues[number] = issue
+	return issue
+}
+
+func (s *FakeGithubServer) createComment(org, repo string, number int, user *github.User, body string) *github.IssueComment {
+	rd := s.repo(org, repo)
+	now := time.Now()
+	comment := &github.IssueComment{
+		ID:        github.Int64(s.getNextID()),
+		Body:      github.String(body),
+		User:      user,
+		CreatedAt: &now,
+	}
+	rd.comments[number] = append(rd.comments[number], comment)
+	return comment
+}
+
+func (s *FakeGithubServer) createPullRequest(org, repo, head, base, title, body string) *github.PullRequest {
+	rd := s.repo(org, repo)
+	number := rd.nextNumber
+	rd.nextNumber++
+	now := time.Now()
+	headRef := head
+	if parts := strings.SplitN(head, ":", 2); len(parts) == 2 {
+		headRef = parts[1]
+	}
+	pr := &github.PullRequest{
+		ID:        github.Int64(s.getNextID()),
+		Number:    github.Int(number),
+		Title:     github.String(title),
+		Body:      github.String(body),
+		State:     github.String("open"),
+		User:      s.user,
+		CreatedAt: &now,
+		Head:      &github.PullRequestBranch{Label: github.String(head), Ref: github.String(headRef)},
+		Base:      &github.PullRequestBranch{Label: github.String(org + ":" + base), Ref: github.String(base)},
+	}
+	rd.pulls[number] = pr
+	return pr
+}
+
+// handle serves a request, after checking the injected faults and the rate limit
+func (s *FakeGithubServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+
+	if time.Now().After(s.reset) {
+		s.remaining = s.rateLimit
+		s.reset = time.Now().Add(time.Hour)
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.rateLimit))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(s.reset.Unix(), 10))
+	if len(s.faults) > 0 {
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.remaining))
+		fault := s.faults[0]
+		s.faults = s.faults[1:]
+		fault(w)
+		return
+	}
+	if s.remaining == 0 {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		writeJSON(w, http.StatusForbidden, map[string]string{
+			"message":           "API rate limit exceeded for user ID 1.",
+			"documentation_url": "https://developer.github.com/v3/#rate-limiting",
+		})
+		return
+	}
+	s.remaining--
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.remaining))
+
+	for _, route := range routes {
+		if params, ok := match(route.pattern, r.URL.Path); ok && route.method == r.Method {
+			route.handler(s, w, r, params)
+			return
+		}
+	}
+	notFound(w)
+}
+
+// route is an endpoint of the API, its pattern has "*" for each parameter of the path
+type route struct {
+	method  string
+	pattern string
+	handler func(s *FakeGithubServer, w http.ResponseWriter, r *http.Request, params []string)
+}
+
+var routes = []route{
+	{http.MethodGet, "/user", (*FakeGithubServer).getUser},
+	{http.MethodGet, "/users/*/repos", (*FakeGithubServer).listRepos},
+	{http.MethodGet, "/repos/*/*/branches", (*FakeGithubServer).listBranches},
+	{http.MethodGet, "/repos/*/*/issues", (*FakeGithubServer).listIssues},
+	{http.MethodPost, "/repos/*/*/issues", (*FakeGithubServer).postIssue},
+	{http.MethodPatch, "/repos/*/*/issues/*", (*FakeGithubServer).editIssue},
+	{http.MethodGet, "/repos/*/*/issues/comments/*", (*FakeGithubServer).getComment},
+	{http.MethodPatch, "/repos/*/*/issues/comments/*", (*FakeGithubServer).editComment},
+	{http.MethodGet, "/repos/*/*/issues/*/comments", (*FakeGithubServer).listComments},
+	{http.MethodPost, "/repos/*/*/issues/*/comments", (*FakeGithubServer).postComment},
+	{http.MethodPost, "/repos/*/*/issues/*/labels", (*FakeGithubServer).addLabels},
+	{http.MethodDelete, "/repos/*/*/issues/*/labels/*", (*FakeGithubServer).removeLabel},
+	{http.MethodGet, "/repos/*/*/pulls", (*FakeGithubServer).listPullRequests},
+	{http.MethodPost, "/repos/*/*/pulls", (*FakeGithubServer).postPullRequest},
+	{http.MethodGet, "/repos/*/*/pulls/*", (*FakeGithubServer).getPullRequest},
+	{http.MethodPatch, "/repos/*/*/pulls/*", (*FakeGithubServer).editPullRequest},
+	{http.MethodGet, "/repos/*/*/pulls/*/files", (*FakeGithubServer).listFiles},
+	{http.MethodGet, "/repos/*/*/pulls/*/commits", (*FakeGithubServer).listCommits},
+}
+
+// match returns the parameters of the path if it matches the pattern
+func match(pattern, path string) ([]string, bool) {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+	var params []string
+	for i, part := range patternParts {
+		if part == "*" {
+			params = append(params, pathParts[i])
+		} else if part != pathParts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func (s *FakeGithubServer) getUser(w http.ResponseWriter, r *http.Request, params []string) {
+	writeJSON(w, http.StatusOK, s.user)
+}
+
+func (s *FakeGithubServer) listRepos(w http.ResponseWriter, r *http.Request, params []string) {
+	var names []string
+	for name := range s.repos {
+		if strings.HasPrefix(name, params[0]+"/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var repos []interface{}
+	for _, name := range names {
+		repos = append(repos, &github.Repository{Name: github.String(strings.SplitN(name, "/", 2)[1])})
+	}
+	s.writePage(w, r, repos)
+}
+
+func (s *FakeGithubServer) listBranches(w http.ResponseWriter, r *http.Request, params []string) {
+	var branches []interface{}
+	for _, branch := range s.repo(params[0], params[1]).branches {
+		branches = append(branches, branch)
+	}
+	s.writePage(w, r, branches)
+}
+
+func (s *FakeGithubServer) listIssues(w http.ResponseWriter, r *http.Request, params []string) {
+	rd := s.repo(params[0], params[1])
+	state := r.URL.Query().Get("state")
+	var labels []string
+	if l := r.URL.Query().Get("labels"); l != "" {
+		labels = strings.Split(l, ",")
+	}
+	var issues []interface{}
+	for _, number := range sortedNumbers(rd.issues) {
+		issue := rd.issues[number]
+		if (state == "" && issue.GetState() != "open") || (state != "" && state != "all" && issue.GetState() != state) {
+			continue
+		}
+		if hasLabels(issue, labels) {
+			issues = append(issues, issue)
+		}
+	}
+	s.writePage(w, r, issues)
+}
+
+func (s *FakeGithubServer) postIssue(w http.ResponseWriter, r *http.Request, params []string) {
+	var req github.IssueRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	var labels []string
+	if req.Labels != nil {
+		labels = *req.Labels
+	}
+	writeJSON(w, http.StatusCreated, s.createIssue(params[0], params[1], s.user, req.GetTitle(), req.GetBody(), labels))
+}
+
+func (s *FakeGithubServer) editIssue(w http.ResponseWriter, r *http.Request, params []string) {
+	issue := s.findIssue(params)
+	if issue == nil {
+		notFound(w)
+		return
+	}
+	var req github.IssueRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Title != nil {
+		issue.Title = req.Title
+	}
+	if req.Body != nil {
+		issue.Body = req.Body
+	}
+	if req.State != nil {
+		issue.State = req.State
+	}
+	writeJSON(w, http.StatusOK, issue)
+}
+
+func (s *FakeGithubServer) getComment(w http.ResponseWriter, r *http.Request, params []string) {
+	comment := s.findComment(params)
+	if comment == nil {
+		notFound(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, comment)
+}
+
+func (s *FakeGithubServer) editComment(w http.ResponseWriter, r *http.Request, params []string) {
+	comment := s.findComment(params)
+	if comment == nil {
+		notFound(w)
+		return
+	}
+	var req github.IssueComment
+	if !readJSON(w, r, &req) {
+		return
+	}
+	comment.Body = req.Body
+	writeJSON(w, http.StatusOK, comment)
+}
+
+func (s *FakeGithubServer) listComments(w http.ResponseWriter, r *http.Request, params []string) {
+	number, _ := strconv.Atoi(params[2])
+	var comments []interface{}
+	for _, comment := range s.repo(params[0], params[1]).comments[number] {
+		comments = append(comments, comment)
+	}
+	s.writePage(w, r, comments)
+}
+
+func (s *FakeGithubServer) postComment(w http.ResponseWriter, r *http.Request, params []string) {
+	number, _ := strconv.Atoi(params[2])
+	if s.findIssue(params) == nil && s.findPullRequest(params) == nil {
+		notFound(w)
+		return
+	}
+	var req github.IssueComment
+	if !readJSON(w, r, &req) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.createComment(params[0], params[1], number, s.user, req.GetBody()))
+}
+
+func (s *FakeGithubServer) addLabels(w http.ResponseWriter, r *http.Request, params []string) {
+	issue := s.findIssue(params)
+	if issue == nil {
+		notFound(w)
+		return
+	}
+	var labels []string
+	if !readJSON(w, r, &labels) {
+		return
+	}
+	for _, label := range labels {
+		if !hasLabels(issue, []string{label}) {
+			issue.Labels = append(issue.Labels, github.Label{Name: github.String(label)})
+		}
+	}
+	writeJSON(w, http.StatusOK, issue.Labels)
+}
+
+func (s *FakeGithubServer) removeLabel(w http.ResponseWriter, r *http.Request, params []string) {
+	issue := s.findIssue(params)
+	if issue == nil {
+		notFound(w)
+		return
+	}
+	for i, label := range issue.Labels {
+		if label.GetName() == params[3] {
+			issue.Labels = append(issue.Labels[:i], issue.Labels[i+1:]...)
+			writeJSON(w, http.StatusOK, issue.Labels)
+			return
+		}
+	}
+	notFound(w)
+}
+
+func (s *FakeGithubServer) listPullRequests(w http.ResponseWriter, r *http.Request, params []string) {
+	rd := s.repo(params[0], params[1])
+	query := r.URL.Query()
+	var pulls []interface{}
+	for _, number := range sortedPullNumbers(rd.pulls) {
+		pr := rd.pulls[number]
+		state := query.Get("state")
+		if (state == "" && pr.GetState() != "open") || (state != "" && state != "all" && pr.GetState() != state) {
+			continue
+		}
+		if (query.Get("head") == "" || query.Get("head") == pr.Head.GetLabel()) &&
+			(query.Get("base") == "" || query.Get("base") == pr.Base.GetRef()) {
+			pulls = append(pulls, pr)
+		}
+	}
+	s.writePage(w, r, pulls)
+}
+
+func (s *FakeGithubServer) postPullRequest(w http.ResponseWriter, r *http.Request, params []string) {
+	var req github.NewPullRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.GetHead() == "" || req.GetBase() == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"message": "Validation Failed"})
+		return
+	}
+	pr := s.createPullRequest(params[0], params[1], req.GetHead(), req.GetBase(), req.GetTitle(), req.GetBody())
+	pr.MaintainerCanModify = req.MaintainerCanModify
+	writeJSON(w, http.StatusCreated, pr)
+}
+
+func (s *FakeGithubServer) getPullRequest(w http.ResponseWriter, r *http.Request, params []string) {
+	pr := s.findPullRequest(params)
+	if pr == nil {
+		notFound(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, pr)
+}
+
+func (s *FakeGithubServer) editPullRequest(w http.ResponseWriter, r *http.Request, params []string) {
+	pr := s.findPullRequest(params)
+	if pr == nil {
+		notFound(w)
+		return
+	}
+	var req struct {
+		Title *string `json:"title"`
+		Body  *string `json:"body"`
+		State *string `json:"state"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Title != nil {
+		pr.Title = req.Title
+	}
+	if req.Body != nil {
+		pr.Body = req.Body
+	}
+	if req.State != nil {
+		pr.State = req.State
+	}
+	writeJSON(w, http.StatusOK, pr)
+}
+
+func (s *FakeGithubServer) listFiles(w http.ResponseWriter, r *http.Request, params []string) {
+	if s.findPullRequest(params) == nil {
+		notFound(w)
+		return
+	}
+	number, _ := strconv.Atoi(params[2])
+	var files []interface{}
+	for _, file := range s.repo(params[0], params[1]).files[number] {
+		files = append(files, file)
+	}
+	s.writePage(w, r, files)
+}
+
+func (s *FakeGithubServer) listCommits(w http.ResponseWriter, r *http.Request, params []string) {
+	if s.findPullRequest(params) == nil {
+		notFound(w)
+		return
+	}
+	number, _ := strconv.Atoi(params[2])
+	var commits []interface{}
+	for _, commit := range s.repo(params[0], params[1]).commits[number] {
+		commits = append(commits, commit)
+	}
+	s.writePage(w, r, commits)
+}
+
+// findIssue returns the issue of the org, repo and number params, if any
+func (s *FakeGithubServer) findIssue(params []string) *github.Issue {
+	number, _ := strconv.Atoi(params[2])
+	return s.repo(params[0], params[1]).issues[number]
+}
+
+// findPullRequest returns the pull request of the org, repo and number params, if any
+func (s *FakeGithubServer) findPullRequest(params []string) *github.PullRequest {
+	number, _ := strconv.Atoi(params[2])
+	return s.repo(params[0], params[1]).pulls[number]
+}
+
+// findComment returns the comment of the org, repo and comment ID params, if any
+func (s *FakeGithubServer) findComment(params []string) *github.IssueComment {
+	ID, _ := strconv.ParseInt(params[2], 10, 64)
+	for _, comments := range s.repo(params[0], params[1]).comments {
+		for _, comment := range comments {
+			if comment.GetID() == ID {
+				return comment
+			}
+		}
+	}
+	return nil
+}
+
+// writePage writes the page of the items requested by the "page" and "per_page" query parameters,
+// with the Link header to the next and last pages like Github
+func (s *FakeGithubServer) writePage(w http.ResponseWriter, r *http.Request, items []interface{}) {
+	query := r.URL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 || perPage > s.pageSize {
+		perPage = s.pageSize
+	}
+	lastPage := (len(items) + perPage - 1) / perPage
+	if page < lastPage {
+		link := func(page int) string {
+			u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+			q := r.URL.Query()
+			q.Set("page", strconv.Itoa(page))
+			q.Set("per_page", strconv.Itoa(perPage))
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next", <%s>; rel="last"`, link(page+1), link(lastPage)))
+	}
+	res := []interface{}{}
+	if start := (page - 1) * perPage; start < len(items) {
+		end := start + perPage
+		if end > len(items) {
+			end = len(items)
+		}
+		res = items[start:end]
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func hasLabels(issue *github.Issue, labels []string) bool {
+	for _, label := range labels {
+		found := false
+		for _, l := range issue.Labels {
+			if l.GetName() == label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedNumbers(issues map[int]*github.Issue) []int {
+	var res []int
+	for number := range issues {
+		res = append(res, number)
+	}
+	sort.Ints(res)
+	return res
+}
+
+func sortedPullNumbers(pulls map[int]*github.PullRequest) []int {
+	var res []int
+	for number := range pulls {
+		res = append(res, number)
+	}
+	sort.Ints(res)
+	return res
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Problems parsing JSON"})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func notFound(w http.ResponseWriter) {
+	writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+}
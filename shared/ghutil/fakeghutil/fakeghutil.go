@@ -27,15 +27,23 @@ import (
 	"github.com/knative/test-infra/shared/ghutil"
 )
 
-// FakeGithubClient is a faked client, implements all functions of ghutil.GithubOperations
+// FakeGithubClient is a faked client, implements all functions of ghutil.GithubOperations and ghutil.GithubGraphQLOperations
 type FakeGithubClient struct {
 	User         *github.User
 	Repos        []string
+	Branches     map[string][]*github.Branch            // map of repo: branches
 	Issues       map[string]map[int]*github.Issue       // map of repo: map of issueNumber: issues
 	Comments     map[int]map[int64]*github.IssueComment // map of issueNumber: map of commentID: comments
 	PullRequests map[string]map[int]*github.PullRequest // map of repo: map of PullRequest Number: pullrequests
 	PRCommits    map[int][]*github.RepositoryCommit     // map of PR number: slice of commits
 	CommitFiles  map[string][]*github.CommitFile        // map of commit SHA: slice of files
+	Reviews      map[int][]*github.PullRequestReview    // map of PR number: slice of reviews
+	Statuses     map[string][]*github.RepoStatus        // map of commit SHA: slice of statuses, latest first
+	CheckRuns    map[int64]*github.CheckRun             // map of check run ID: check runs
+	Blobs        map[string][]byte                      // map of blob SHA: content
+	Trees        map[string]map[string]string           // map of tree SHA: map of file path: blob SHA
+	GitCommits   map[string]*github.Commit              // map of commit SHA: git commits
+	Refs         map[string]map[string]string           // map of repo: map of ref: commit SHA
 
 	NextNumber int    // number to be assigned to next newly created issue/comment
 	BaseURL    string // base URL of Github
@@ -44,11 +52,19 @@ type FakeGithubClient struct {
 // NewFakeGithubClient creates a FakeGithubClient and initialize it's maps
 func NewFakeGithubClient() *FakeGithubClient {
 	return &FakeGithubClient{
+		Branches:     make(map[string][]*github.Branch),
 		Issues:       make(map[string]map[int]*github.Issue),
 		Comments:     make(map[int]map[int64]*github.IssueComment),
 		PullRequests: make(map[string]map[int]*github.PullRequest),
 		PRCommits:    make(map[int][]*github.RepositoryCommit),
 		CommitFiles:  make(map[string][]*github.CommitFile),
+		Reviews:      make(map[int][]*github.PullRequestReview),
+		Statuses:     make(map[string][]*github.RepoStatus),
+		CheckRuns:    make(map[int64]*github.CheckRun),
+		Blobs:        make(map[string][]byte),
+		Trees:        make(map[string]map[string]string),
+		GitCommits:   make(map[string]*github.Commit),
+		Refs:         make(map[string]map[string]string),
 		BaseURL:      "fakeurl",
 	}
 }
@@ -63,6 +79,17 @@ func (fgc *FakeGithubClient) ListRepos(org string) ([]string, error) {
 	return fgc.Repos, nil
 }
 
+// ListBranches lists branches within given repo
+func (fgc *FakeGithubClient) ListBranches(org, repo string) ([]*github.Branch, error) {
+	return fgc.Branches[repo], nil
+}
+
+// AddBranch adds a branch to repo
+// This is complementary of mocking ListBranches, so that repos can have branches
+func (fgc *FakeGithubClient) AddBranch(org, repo, name string) {
+	fgc.Branches[repo] = append(fgc.Branches[repo], &github.Branch{Name: &name})
+}
+
 // ListIssuesByRepo lists issues within given repo, filters by labels if provided
 func (fgc *FakeGithubClient) ListIssuesByRepo(org, repo string, labels []string) ([]*github.Issue, error) {
 	var issues []*github.Issue
@@ -300,6 +327,146 @@ func (fgc *FakeGithubClient) CreatePullRequest(org, repo, head, base, title, bod
 	return PR, nil
 }
 
+// ListReviews lists the reviews of a pull request
+func (fgc *FakeGithubClient) ListReviews(org, repo string, ID int) ([]*github.PullRequestReview, error) {
+	if _, err := fgc.GetPullRequest(org, repo, ID); nil != err {
+		return nil, err
+	}
+	return fgc.Reviews[ID], nil
+}
+
+// CreateReview creates a review of a pull request, submitted with the event of the review ("APPROVE",
+// "REQUEST_CHANGES" or "COMMENT"), or left pending without it
+func (fgc *FakeGithubClient) CreateReview(org, repo string, ID int, review *github.PullRequestReviewRequest) (*github.PullRequestReview, error) {
+	if _, err := fgc.GetPullRequest(org, repo, ID); nil != err {
+		return nil, err
+	}
+	states := map[string]string{
+		"":                "PENDING",
+		"APPROVE":         "APPROVED",
+		"REQUEST_CHANGES": "CHANGES_REQUESTED",
+		"COMMENT":         "COMMENTED",
+	}
+	state, ok := states[review.GetEvent()]
+	if !ok {
+		return nil, fmt.Errorf("invalid review event '%s'", review.GetEvent())
+	}
+	reviewID := int64(fgc.getNextNumber())
+	newReview := &github.PullRequestReview{
+		ID:       &reviewID,
+		User:     fgc.User,
+		Body:     review.Body,
+		CommitID: review.CommitID,
+		State:    &state,
+	}
+	fgc.Reviews[ID] = append(fgc.Reviews[ID], newReview)
+	return newReview, nil
+}
+
+// CreateStatus creates a status for the given commit SHA
+func (fgc *FakeGithubClient) CreateStatus(org, repo, SHA string, status *github.RepoStatus) (*github.RepoStatus, error) {
+	statusID := int64(fgc.getNextNumber())
+	newStatus := *status
+	newStatus.ID = &statusID
+	newStatus.Creator = fgc.User
+	fgc.Statuses[SHA] = append([]*github.RepoStatus{&newStatus}, fgc.Statuses[SHA]...)
+	return &newStatus, nil
+}
+
+// ListStatuses lists the statuses of the given commit SHA, latest first
+func (fgc *FakeGithubClient) ListStatuses(org, repo, ref string) ([]*github.RepoStatus, error) {
+	return fgc.Statuses[ref], nil
+}
+
+// CreateCheckRun creates a check run, with any number of annotations in its output
+func (fgc *FakeGithubClient) CreateCheckRun(org, repo string, opt github.CreateCheckRunOptions) (*github.CheckRun, error) {
+	checkRunID := int64(fgc.getNextNumber())
+	status := "queued"
+	if nil != opt.Status {
+		status = *opt.Status
+	}
+	checkRun := &github.CheckRun{
+		ID:          &checkRunID,
+		Name:        &opt.Name,
+		HeadSHA:     &opt.HeadSHA,
+		ExternalID:  opt.ExternalID,
+		HTMLURL:     opt.DetailsURL,
+		Status:      &status,
+		Conclusion:  opt.Conclusion,
+		StartedAt:   opt.StartedAt,
+		CompletedAt: opt.CompletedAt,
+	}
+	updateCheckRunOutput(checkRun, opt.Output)
+	fgc.CheckRuns[checkRunID] = checkRun
+	return checkRun, nil
+}
+
+// UpdateCheckRun updates a check run, the annotations in its output are added to the existing ones
+func (fgc *FakeGithubClient) UpdateCheckRun(org, repo string, checkRunID int64, opt github.UpdateCheckRunOptions) (*github.CheckRun, error) {
+	checkRun, ok := fgc.CheckRuns[checkRunID]
+	if !ok {
+		return nil, fmt.Errorf("check run not exist: '%d'", checkRunID)
+	}
+	checkRun.Name = &opt.Name
+	if nil != opt.HeadSHA {
+		checkRun.HeadSHA = opt.HeadSHA
+	}
+	if nil != opt.ExternalID {
+		checkRun.ExternalID = opt.ExternalID
+	}
+	if nil != opt.DetailsURL {
+		checkRun.HTMLURL = opt.DetailsURL
+	}
+	if nil != opt.Status {
+		checkRun.Status = opt.Status
+	}
+	if nil != opt.Conclusion {
+		checkRun.Conclusion = opt.Conclusion
+	}
+	if nil != opt.CompletedAt {
+		checkRun.CompletedAt = opt.CompletedAt
+	}
+	updateCheckRunOutput(checkRun, opt.Output)
+	return checkRun, nil
+}
+
+// ListIssuesWithComments lists issues within given repo with their comments, filters by labels if provided
+func (fgc *FakeGithubClient) ListIssuesWithComments(org, repo string, labels []string) ([]*ghutil.IssueWithComments, error) {
+	issues, err := fgc.ListIssuesByRepo(org, repo, labels)
+	if nil != err {
+		return nil, err
+	}
+	sort.Slice(issues, func(i, j int) bool { return *issues[i].Number < *issues[j].Number })
+	res := make([]*ghutil.IssueWithComments, len(issues))
+	for i, issue := range issues {
+		comments, err := fgc.ListComments(org, repo, *issue.Number)
+		if nil != err {
+			return nil, err
+		}
+		sort.Slice(comments, func(i, j int) bool { return *comments[i].ID < *comments[j].ID })
+		res[i] = &ghutil.IssueWithComments{Issue: issue, Comments: comments}
+	}
+	return res, nil
+}
+
+// ListPullRequestsWithFiles lists pull requests within given repo with their files and commits, filters by state
+func (fgc *FakeGithubClient) ListPullRequestsWithFiles(org, repo string, state ghutil.PullRequestState) ([]*ghutil.PullRequestWithFiles, error) {
+	var res []*ghutil.PullRequestWithFiles
+	for _, PR := range fgc.PullRequests[repo] {
+		if ghutil.PullRequestAllState != state && "" != state && *PR.State != string(state) {
+			continue
+		}
+		commits := fgc.PRCommits[*PR.Number]
+		var files []*github.CommitFile
+		for _, commit := range commits {
+			files = append(files, fgc.CommitFiles[*commit.SHA]...)
+		}
+		res = append(res, &ghutil.PullRequestWithFiles{PullRequest: PR, Files: files, Commits: commits})
+	}
+	sort.Slice(res, func(i, j int) bool { return *res[i].PullRequest.Number < *res[j].PullRequest.Number })
+	return res, nil
+}
+
 // AddFileToCommit adds file to commit
 // This is complementary of mocking CreatePullRequest, so that newly created pull request can have files
 func (fgc *FakeGithubClient) AddFileToCommit(org, repo, SHA, filename, patch string) error {
@@ -353,6 +520,23 @@ func (fgc *FakeGithubClient) updateIssueState(org, repo string, state ghutil.Iss
 	return nil
 }
 
+// updateCheckRunOutput replaces the title, summary and text of the output of the check run with the given ones,
+// and adds the given annotations to the existing ones
+func updateCheckRunOutput(checkRun *github.CheckRun, output *github.CheckRunOutput) {
+	if nil == output {
+		return
+	}
+	if nil == checkRun.Output {
+		checkRun.Output = &github.CheckRunOutput{}
+	}
+	checkRun.Output.Title = output.Title
+	checkRun.Output.Summary = output.Summary
+	checkRun.Output.Text = output.Text
+	checkRun.Output.Annotations = append(checkRun.Output.Annotations, output.Annotations...)
+	count := len(checkRun.Output.Annotations)
+	checkRun.Output.AnnotationsCount = &count
+}
+
 func (fgc *FakeGithubClient) getNextNumber() int {
 	fgc.NextNumber++
 	return fgc.NextNumber
@@ -0,0 +1,253 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// gitdata.go fakes the git objects of GithubClient with an in-memory tree of files per commit
+
+package fakeghutil
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// GetRef gets a ref, i.e. "heads/master", returns nil if it doesn't exist
+func (fgc *FakeGithubClient) GetRef(org, repo, ref string) (*github.Reference, error) {
+	ref = strings.TrimPrefix(ref, "refs/")
+	SHA, ok := fgc.Refs[repo][ref]
+	if !ok {
+		return nil, nil
+	}
+	return newReference(ref, SHA), nil
+}
+
+// CreateRef creates a ref pointing to the given commit SHA
+func (fgc *FakeGithubClient) CreateRef(org, repo, ref, SHA string) (*github.Reference, error) {
+	ref = strings.TrimPrefix(ref, "refs/")
+	if _, ok := fgc.Refs[repo][ref]; ok {
+		return nil, fmt.Errorf("ref %s already exists", ref)
+	}
+	if _, ok := fgc.GitCommits[SHA]; !ok {
+		return nil, fmt.Errorf("commit %s not exist", SHA)
+	}
+	if _, ok := fgc.Refs[repo]; !ok {
+		fgc.Refs[repo] = make(map[string]string)
+	}
+	fgc.Refs[repo][ref] = SHA
+	return newReference(ref, SHA), nil
+}
+
+// UpdateRef points an existing ref to the given commit SHA, force is required unless it's a fast forward
+func (fgc *FakeGithubClient) UpdateRef(org, repo, ref, SHA string, force bool) (*github.Reference, error) {
+	ref = strings.TrimPrefix(ref, "refs/")
+	oldSHA, ok := fgc.Refs[repo][ref]
+	if !ok {
+		return nil, fmt.Errorf("ref %s not exist", ref)
+	}
+	if _, ok := fgc.GitCommits[SHA]; !ok {
+		return nil, fmt.Errorf("commit %s not exist", SHA)
+	}
+	if !force && !fgc.isAncestor(oldSHA, SHA) {
+		return nil, fmt.Errorf("update of ref %s is not a fast forward", ref)
+	}
+	fgc.Refs[repo][ref] = SHA
+	return newReference(ref, SHA), nil
+}
+
+// GetGitCommit gets a git commit by SHA
+func (fgc *FakeGithubClient) GetGitCommit(org, repo, SHA string) (*github.Commit, error) {
+	commit, ok := fgc.GitCommits[SHA]
+	if !ok {
+		return nil, fmt.Errorf("commit %s not exist", SHA)
+	}
+	return commit, nil
+}
+
+// GetBlob gets the content of the blob with the given SHA
+func (fgc *FakeGithubClient) GetBlob(org, repo, SHA string) ([]byte, error) {
+	content, ok := fgc.Blobs[SHA]
+	if !ok {
+		return nil, fmt.Errorf("blob %s not exist", SHA)
+	}
+	return append([]byte{}, content...), nil
+}
+
+// CreateBlob creates a blob with the given content, and returns its SHA
+func (fgc *FakeGithubClient) CreateBlob(org, repo string, content []byte) (string, error) {
+	SHA := hashObject("blob", content)
+	fgc.Blobs[SHA] = append([]byte{}, content...)
+	return SHA, nil
+}
+
+// CreateTree creates a tree from the given file entries on top of the base tree. An entry with content creates
+// a blob, and an entry with neither SHA nor content removes the file from the base tree
+func (fgc *FakeGithubClient) CreateTree(org, repo, baseTree string, entries []github.TreeEntry) (*github.Tree, error) {
+	files := make(map[string]string)
+	if baseTree != "" {
+		base, ok := fgc.Trees[baseTree]
+		if !ok {
+			return nil, fmt.Errorf("tree %s not exist", baseTree)
+		}
+		for path, SHA := range base {
+			files[path] = SHA
+		}
+	}
+	for _, entry := range entries {
+		switch {
+		case nil != entry.SHA:
+			if _, ok := fgc.Blobs[entry.GetSHA()]; !ok {
+				return nil, fmt.Errorf("blob %s not exist", entry.GetSHA())
+			}
+			files[entry.GetPath()] = entry.GetSHA()
+		case nil != entry.Content:
+			files[entry.GetPath()], _ = fgc.CreateBlob(org, repo, []byte(entry.GetContent()))
+		default:
+			delete(files, entry.GetPath())
+		}
+	}
+	return fgc.addTree(files), nil
+}
+
+// CreateGitCommit creates a commit of the given tree SHA with the given parent SHAs
+func (fgc *FakeGithubClient) CreateGitCommit(org, repo, message, tree string, parents []string) (*github.Commit, error) {
+	if _, ok := fgc.Trees[tree]; !ok {
+		return nil, fmt.Errorf("tree %s not exist", tree)
+	}
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "tree %s\n", tree)
+	commit := &github.Commit{Message: &message, Tree: &github.Tree{SHA: &tree}}
+	for _, parent := range parents {
+		if _, ok := fgc.GitCommits[parent]; !ok {
+			return nil, fmt.Errorf("commit %s not exist", parent)
+		}
+		fmt.Fprintf(&content, "parent %s\n", parent)
+		commit.Parents = append(commit.Parents, github.Commit{SHA: github.String(parent)})
+	}
+	fmt.Fprintf(&content, "\n%s", message)
+	commit.SHA = github.String(hashObject("commit", content.Bytes()))
+	fgc.GitCommits[commit.GetSHA()] = commit
+	return commit, nil
+}
+
+// GetFileContent gets the content of a file at the given ref (a commit SHA, branch or tag)
+func (fgc *FakeGithubClient) GetFileContent(org, repo, path, ref string) ([]byte, error) {
+	if ref == "" {
+		ref = "master"
+	}
+	SHA, ok := ref, false
+	if _, ok = fgc.GitCommits[ref]; !ok {
+		for _, name := range []string{ref, "heads/" + ref, "tags/" + ref} {
+			if SHA, ok = fgc.Refs[repo][strings.TrimPrefix(name, "refs/")]; ok {
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("ref %s not exist", ref)
+	}
+	blobSHA, ok := fgc.Trees[fgc.GitCommits[SHA].GetTree().GetSHA()][path]
+	if !ok {
+		return nil, fmt.Errorf("file %s not exist at %s", path, ref)
+	}
+	return fgc.Blobs[blobSHA], nil
+}
+
+// AddFilesToBranch commits the given files, map of path: content, on top of the branch, creating it if it doesn't
+// exist, and returns the SHA of the commit
+// This is complementary of mocking GetFileContent, so that repos can have files
+func (fgc *FakeGithubClient) AddFilesToBranch(org, repo, branch, message string, files map[string][]byte) (string, error) {
+	var baseTree string
+	var parents []string
+	if ref, _ := fgc.GetRef(org, repo, "heads/"+branch); nil != ref {
+		parent := ref.GetObject().GetSHA()
+		baseTree = fgc.GitCommits[parent].GetTree().GetSHA()
+		parents = append(parents, parent)
+	}
+	var entries []github.TreeEntry
+	for path, content := range files {
+		entries = append(entries, github.TreeEntry{Path: github.String(path), Content: github.String(string(content))})
+	}
+	tree, err := fgc.CreateTree(org, repo, baseTree, entries)
+	if nil != err {
+		return "", err
+	}
+	commit, err := fgc.CreateGitCommit(org, repo, message, tree.GetSHA(), parents)
+	if nil != err {
+		return "", err
+	}
+	if nil == parents {
+		_, err = fgc.CreateRef(org, repo, "heads/"+branch, commit.GetSHA())
+	} else {
+		_, err = fgc.UpdateRef(org, repo, "heads/"+branch, commit.GetSHA(), false)
+	}
+	return commit.GetSHA(), err
+}
+
+// addTree stores a tree of the given files, map of path: blob SHA, and returns it
+func (fgc *FakeGithubClient) addTree(files map[string]string) *github.Tree {
+	var paths []string
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var content bytes.Buffer
+	tree := &github.Tree{}
+	for _, path := range paths {
+		fmt.Fprintf(&content, "%s %s\n", path, files[path])
+		tree.Entries = append(tree.Entries, github.TreeEntry{
+			Path: github.String(path),
+			Mode: github.String("100644"),
+			Type: github.String("blob"),
+			SHA:  github.String(files[path]),
+		})
+	}
+	tree.SHA = github.String(hashObject("tree", content.Bytes()))
+	fgc.Trees[tree.GetSHA()] = files
+	return tree
+}
+
+// isAncestor returns true if the commit ancestor is the commit SHA or one of its ancestors
+func (fgc *FakeGithubClient) isAncestor(ancestor, SHA string) bool {
+	if ancestor == SHA {
+		return true
+	}
+	for _, parent := range fgc.GitCommits[SHA].Parents {
+		if fgc.isAncestor(ancestor, parent.GetSHA()) {
+			return true
+		}
+	}
+	return false
+}
+
+func newReference(ref, SHA string) *github.Reference {
+	return &github.Reference{
+		Ref:    github.String("refs/" + ref),
+		Object: &github.GitObject{Type: github.String("commit"), SHA: github.String(SHA)},
+	}
+}
+
+// hashObject returns the SHA of a git object of the given kind, i.e. "blob", with the given content
+func hashObject(kind string, content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", kind, len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
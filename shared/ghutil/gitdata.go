@@ -0,0 +1,259 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// gitdata.go provides generic functions related to git objects: blobs, trees, commits and refs,
+// so that commits can be made without a local git checkout
+
+package ghutil
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	// Mode and type of the tree entries of regular files
+	fileMode      = "100644"
+	blobEntryType = "blob"
+)
+
+// GetRef gets a ref, i.e. "heads/master" or "tags/v0.6.0", returns nil if it doesn't exist
+func (gc *GithubClient) GetRef(org, repo, ref string) (*github.Reference, error) {
+	var res *github.Reference
+	resp, err := retry(
+		fmt.Sprintf("getting ref '%s'", ref),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Git.GetRef(ctx, org, repo, ref)
+			return resp, err
+		},
+	)
+	// Github returns the refs starting with ref when none matches exactly, which go-github reports as an error
+	if nil != err && nil != resp && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusOK) {
+		return nil, nil
+	}
+	return res, err
+}
+
+// CreateRef creates a ref, i.e. "heads/branch", pointing to the given commit SHA
+func (gc *GithubClient) CreateRef(org, repo, ref, SHA string) (*github.Reference, error) {
+	reference := &github.Reference{Ref: &ref, Object: &github.GitObject{SHA: &SHA}}
+	var res *github.Reference
+	_, err := retry(
+		fmt.Sprintf("creating ref '%s' at '%s'", ref, SHA),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Git.CreateRef(ctx, org, repo, reference)
+			return resp, err
+		},
+	)
+	return res, err
+}
+
+// UpdateRef points an existing ref to the given commit SHA, force is required unless it's a fast forward
+func (gc *GithubClient) UpdateRef(org, repo, ref, SHA string, force bool) (*github.Reference, error) {
+	reference := &github.Reference{Ref: &ref, Object: &github.GitObject{SHA: &SHA}}
+	var res *github.Reference
+	_, err := retry(
+		fmt.Sprintf("updating ref '%s' to '%s'", ref, SHA),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Git.UpdateRef(ctx, org, repo, reference, force)
+			return resp, err
+		},
+	)
+	return res, err
+}
+
+// GetGitCommit gets a git commit by SHA, with the SHA of its tree and its parents
+func (gc *GithubClient) GetGitCommit(org, repo, SHA string) (*github.Commit, error) {
+	var res *github.Commit
+	_, err := retry(
+		fmt.Sprintf("getting commit '%s'", SHA),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Git.GetCommit(ctx, org, repo, SHA)
+			return resp, err
+		},
+	)
+	return res, err
+}
+
+// GetBlob gets the content of the blob with the given SHA
+func (gc *GithubClient) GetBlob(org, repo, SHA string) ([]byte, error) {
+	var res []byte
+	_, err := retry(
+		fmt.Sprintf("getting blob '%s'", SHA),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Git.GetBlobRaw(ctx, org, repo, SHA)
+			return resp, err
+		},
+	)
+	return res, err
+}
+
+// CreateBlob creates a blob with the given content, and returns its SHA
+func (gc *GithubClient) CreateBlob(org, repo string, content []byte) (string, error) {
+	blob := &github.Blob{
+		Content:  github.String(base64.StdEncoding.EncodeToString(content)),
+		Encoding: github.String("base64"),
+	}
+	var res *github.Blob
+	_, err := retry(
+		fmt.Sprintf("creating blob of %d bytes", len(content)),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Git.CreateBlob(ctx, org, repo, blob)
+			return resp, err
+		},
+	)
+	return res.GetSHA(), err
+}
+
+// CreateTree creates a tree from the given entries on top of the base tree, or from the entries only if
+// the base tree is empty. An entry replaces the one with the same path in the base tree
+func (gc *GithubClient) CreateTree(org, repo, baseTree string, entries []github.TreeEntry) (*github.Tree, error) {
+	var res *github.Tree
+	_, err := retry(
+		fmt.Sprintf("creating tree of %d entries on top of '%s'", len(entries), baseTree),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Git.CreateTree(ctx, org, repo, baseTree, entries)
+			return resp, err
+		},
+	)
+	return res, err
+}
+
+// CreateGitCommit creates a commit of the given tree SHA with the given parent SHAs, authored by the
+// authenticated user. It doesn't update any ref
+func (gc *GithubClient) CreateGitCommit(org, repo, message, tree string, parents []string) (*github.Commit, error) {
+	commit := &github.Commit{Message: &message, Tree: &github.Tree{SHA: &tree}}
+	for i := range parents {
+		commit.Parents = append(commit.Parents, github.Commit{SHA: &parents[i]})
+	}
+	var res *github.Commit
+	_, err := retry(
+		fmt.Sprintf("creating commit of tree '%s'", tree),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Git.CreateCommit(ctx, org, repo, commit)
+			return resp, err
+		},
+	)
+	return res, err
+}
+
+// GetFileContent gets the content of a file at the given ref (a commit SHA, branch or tag)
+func (gc *GithubClient) GetFileContent(org, repo, path, ref string) ([]byte, error) {
+	var file *github.RepositoryContent
+	_, err := retry(
+		fmt.Sprintf("getting content of '%s' at '%s'", path, ref),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			file, _, resp, err = gc.Client.Repositories.GetContents(ctx, org, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+			return resp, err
+		},
+	)
+	if nil != err {
+		return nil, err
+	}
+	if nil == file {
+		return nil, fmt.Errorf("'%s' is a directory", path)
+	}
+	content, err := file.GetContent()
+	return []byte(content), err
+}
+
+// CommitFiles commits the given files, map of path: content, on top of the base branch, and points the branch
+// to the commit, creating the branch if it doesn't exist. The branch is force updated, so that a bot can keep
+// a single branch for its pull requests. It works with any GithubOperations, without a local git checkout
+func CommitFiles(gc GithubOperations, org, repo, base, branch, message string, files map[string][]byte) (*github.Commit, error) {
+	baseRef, err := gc.GetRef(org, repo, "heads/"+base)
+	if nil != err {
+		return nil, err
+	}
+	if nil == baseRef {
+		return nil, fmt.Errorf("branch '%s' doesn't exist in '%s/%s'", base, org, repo)
+	}
+	parentSHA := baseRef.GetObject().GetSHA()
+	parent, err := gc.GetGitCommit(org, repo, parentSHA)
+	if nil != err {
+		return nil, err
+	}
+
+	var paths []string
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var entries []github.TreeEntry
+	for _, path := range paths {
+		SHA, err := gc.CreateBlob(org, repo, files[path])
+		if nil != err {
+			return nil, err
+		}
+		entries = append(entries, github.TreeEntry{
+			Path: github.String(path),
+			Mode: github.String(fileMode),
+			Type: github.String(blobEntryType),
+			SHA:  github.String(SHA),
+		})
+	}
+	tree, err := gc.CreateTree(org, repo, parent.GetTree().GetSHA(), entries)
+	if nil != err {
+		return nil, err
+	}
+	commit, err := gc.CreateGitCommit(org, repo, message, tree.GetSHA(), []string{parentSHA})
+	if nil != err {
+		return nil, err
+	}
+
+	ref := "heads/" + branch
+	branchRef, err := gc.GetRef(org, repo, ref)
+	if nil != err {
+		return nil, err
+	}
+	if nil == branchRef {
+		_, err = gc.CreateRef(org, repo, ref, commit.GetSHA())
+	} else {
+		_, err = gc.UpdateRef(org, repo, ref, commit.GetSHA(), true)
+	}
+	return commit, err
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// External test package, as fakeghutil imports ghutil
+package ghutil_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/knative/test-infra/shared/ghutil"
+	"github.com/knative/test-infra/shared/ghutil/fakeghutil"
+)
+
+func TestCommitFiles(t *testing.T) {
+	fgc := fakeghutil.NewFakeGithubClient()
+	baseSHA, err := fgc.AddFilesToBranch("knative", "serving", "master", "initial commit", map[string][]byte{
+		"README.md":         []byte("# Knative Serving"),
+		"config/prow.yaml":  []byte("image: prow:v1"),
+		"config/tests.yaml": []byte("image: tests:v1"),
+	})
+	if err != nil {
+		t.Fatalf("Cannot add files: %v", err)
+	}
+
+	var tests = []struct {
+		name    string
+		base    string
+		files   map[string][]byte
+		content map[string]string // map of path: content expected at the branch
+		err     bool
+	}{
+		{"new branch", "master", map[string][]byte{"config/prow.yaml": []byte("image: prow:v2")},
+			map[string]string{"config/prow.yaml": "image: prow:v2", "README.md": "# Knative Serving"}, false},
+		{"existing branch reset to base", "master", map[string][]byte{"config/tests.yaml": []byte("image: tests:v2")},
+			map[string]string{"config/prow.yaml": "image: prow:v1", "config/tests.yaml": "image: tests:v2"}, false},
+		{"missing base", "release-0.7", map[string][]byte{"README.md": nil}, nil, true},
+	}
+	for _, test := range tests {
+		commit, err := ghutil.CommitFiles(fgc, "knative", "serving", test.base, "autobump", test.name, test.files)
+		if test.err != (err != nil) {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+		}
+		if err != nil {
+			continue
+		}
+		if len(commit.Parents) != 1 || commit.Parents[0].GetSHA() != baseSHA {
+			t.Errorf("%s: expected a commit on top of %s, got parents %v", test.name, baseSHA, commit.Parents)
+		}
+		for path, expected := range test.content {
+			content, err := fgc.GetFileContent("knative", "serving", path, "autobump")
+			if err != nil || string(content) != expected {
+				t.Errorf("%s: expected %q in %s, got %q, error %v", test.name, expected, path, content, err)
+			}
+		}
+	}
+
+	// The base branch isn't changed
+	if content, _ := fgc.GetFileContent("knative", "serving", "config/prow.yaml", "master"); string(content) != "image: prow:v1" {
+		t.Errorf("Expected master to be unchanged, got %q", content)
+	}
+	if content, _ := fgc.GetFileContent("knative", "serving", "config/prow.yaml", baseSHA); string(content) != "image: prow:v1" {
+		t.Errorf("Expected the content at commit %s, got %q", baseSHA, content)
+	}
+}
+
+func TestCommitFilesDryRun(t *testing.T) {
+	fgc := fakeghutil.NewFakeGithubClient()
+	baseSHA, err := fgc.AddFilesToBranch("knative", "serving", "master", "initial commit", map[string][]byte{
+		"README.md":        []byte("# Knative Serving"),
+		"config/prow.yaml": []byte("image: prow:v1"),
+	})
+	if err != nil {
+		t.Fatalf("Cannot add files: %v", err)
+	}
+	dc := ghutil.NewDryRunGithubClient(fgc)
+
+	// The second commit is on top of the branch created by the first one, which only exists in dry run mode
+	for _, version := range []string{"v2", "v3"} {
+		if _, err := ghutil.CommitFiles(dc, "knative", "serving", "master", "autobump", "bump to "+version,
+			map[string][]byte{"config/prow.yaml": []byte("image: prow:" + version)}); err != nil {
+			t.Fatalf("Unexpected error committing %s in dry run mode: %v", version, err)
+		}
+	}
+	ref, err := dc.GetRef("knative", "serving", "heads/autobump")
+	if err != nil || nil == ref {
+		t.Fatalf("Expected the branch created in dry run mode, got %v, error %v", ref, err)
+	}
+	commit, err := dc.GetGitCommit("knative", "serving", ref.GetObject().GetSHA())
+	if err != nil || commit.GetMessage() != "bump to v3" || commit.Parents[0].GetSHA() != baseSHA {
+		t.Errorf("Expected the last commit on top of %s, got %v, error %v", baseSHA, commit, err)
+	}
+	for path, expected := range map[string]string{"config/prow.yaml": "image: prow:v3", "README.md": "# Knative Serving"} {
+		if content, err := dc.GetFileContent("knative", "serving", path, "autobump"); err != nil || string(content) != expected {
+			t.Errorf("Expected %q in %s, got %q, error %v", expected, path, content, err)
+		}
+	}
+
+	// Files of a commit created in dry run mode can point to blobs that already exist on Github
+	blobSHA, err := fgc.CreateBlob("knative", "serving", []byte("moved"))
+	if err != nil {
+		t.Fatalf("Cannot create blob: %v", err)
+	}
+	tree, _ := dc.CreateTree("knative", "serving", "", []github.TreeEntry{{Path: github.String("README.md"), SHA: &blobSHA}})
+	moved, _ := dc.CreateGitCommit("knative", "serving", "move", tree.GetSHA(), []string{baseSHA})
+	if content, err := dc.GetFileContent("knative", "serving", "README.md", moved.GetSHA()); err != nil || string(content) != "moved" {
+		t.Errorf("Expected the content of the existing blob, got %q, error %v", content, err)
+	}
+
+	var operations []string
+	for _, op := range dc.Plan() {
+		operations = append(operations, op.Operation)
+	}
+	expected := "CreateBlob CreateTree CreateGitCommit CreateRef CreateBlob CreateTree CreateGitCommit UpdateRef CreateTree CreateGitCommit"
+	if strings.Join(operations, " ") != expected {
+		t.Errorf("Expected plan %q, got %q", expected, strings.Join(operations, " "))
+	}
+	if ref, _ := fgc.GetRef("knative", "serving", "heads/autobump"); nil != ref {
+		t.Errorf("Expected the branch not to be created, got %v", ref)
+	}
+}
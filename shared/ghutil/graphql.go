@@ -0,0 +1,596 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// graphql.go defines a client of the Github GraphQL API, reading issues, pull requests and branches in bulk
+
+package ghutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	githubGraphQLURL = "https://api.github.com/graphql"
+)
+
+const (
+	// Fragments of the queries, reading the nested lists of issues and pull requests
+	commentsFragment = `
+fragment comments on IssueCommentConnection {
+  pageInfo { hasNextPage endCursor }
+  nodes { databaseId body url createdAt author { login } }
+}`
+	filesFragment = `
+fragment files on PullRequestChangedFileConnection {
+  pageInfo { hasNextPage endCursor }
+  nodes { path additions deletions }
+}`
+	commitsFragment = `
+fragment commits on PullRequestCommitConnection {
+  pageInfo { hasNextPage endCursor }
+  nodes { commit { oid message } }
+}`
+
+	// Queries, reading up to 100 items per list (the maximum allowed by Github), except for the pull requests
+	// as each of them comes with up to 100 files and commits
+	issuesQuery = `
+query($owner: String!, $name: String!, $labels: [String!], $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    issues(first: 100, after: $cursor, labels: $labels) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        number title body state url createdAt closedAt
+        author { login }
+        labels(first: 100) { nodes { name } }
+        comments(first: 100) { ...comments }
+      }
+    }
+  }
+}` + commentsFragment
+	issueCommentsQuery = `
+query($owner: String!, $name: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    issue(number: $number) {
+      comments(first: 100, after: $cursor) { ...comments }
+    }
+  }
+}` + commentsFragment
+	pullRequestsQuery = `
+query($owner: String!, $name: String!, $states: [PullRequestState!], $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequests(first: 25, after: $cursor, states: $states) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        number title body state url createdAt closedAt mergedAt headRefName baseRefName
+        author { login }
+        files(first: 100) { ...files }
+        commits(first: 100) { ...commits }
+      }
+    }
+  }
+}` + filesFragment + commitsFragment
+	pullRequestFilesQuery = `
+query($owner: String!, $name: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      files(first: 100, after: $cursor) { ...files }
+    }
+  }
+}` + filesFragment
+	pullRequestCommitsQuery = `
+query($owner: String!, $name: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      commits(first: 100, after: $cursor) { ...commits }
+    }
+  }
+}` + commitsFragment
+	branchesQuery = `
+query($owner: String!, $name: String!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    refs(refPrefix: "refs/heads/", first: 100, after: $cursor) {
+      pageInfo { hasNextPage endCursor }
+      nodes { name target { oid } }
+    }
+  }
+}`
+)
+
+// IssueWithComments is an issue read in bulk, with its comments
+type IssueWithComments struct {
+	Issue    *github.Issue
+	Comments []*github.IssueComment
+}
+
+// PullRequestWithFiles is a pull request read in bulk, with its files and commits
+// Files only have their name and number of additions and deletions, GraphQL doesn't provide their patch
+type PullRequestWithFiles struct {
+	PullRequest *github.PullRequest
+	Files       []*github.CommitFile
+	Commits     []*github.RepositoryCommit
+}
+
+// GithubGraphQLOperations contains a set of functions reading Github data in bulk, where an issue or pull request
+// comes with its comments, files and commits instead of requiring a call for each of them
+type GithubGraphQLOperations interface {
+	ListIssuesWithComments(org, repo string, labels []string) ([]*IssueWithComments, error)
+	ListPullRequestsWithFiles(org, repo string, state PullRequestState) ([]*PullRequestWithFiles, error)
+	ListBranches(org, repo string) ([]*github.Branch, error)
+}
+
+// GithubGraphQLClient provides methods to read github data through the GraphQL API
+// It implements all functions in GithubGraphQLOperations
+type GithubGraphQLClient struct {
+	HTTPClient *http.Client
+	URL        string
+}
+
+// NewGithubGraphQLClient explicitly authenticates to github with giving token and returns a handle
+func NewGithubGraphQLClient(tokenFilePath string) (*GithubGraphQLClient, error) {
+	httpClient, err := newOAuthClient(ctx, tokenFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return &GithubGraphQLClient{HTTPClient: httpClient, URL: githubGraphQLURL}, nil
+}
+
+// Nodes of the GraphQL responses
+type graphQLPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type graphQLActor struct {
+	Login string `json:"login"`
+}
+
+type graphQLComments struct {
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		DatabaseID int64         `json:"databaseId"`
+		Body       string        `json:"body"`
+		URL        string        `json:"url"`
+		CreatedAt  time.Time     `json:"createdAt"`
+		Author     *graphQLActor `json:"author"`
+	} `json:"nodes"`
+}
+
+type graphQLFiles struct {
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		Path      string `json:"path"`
+		Additions int    `json:"additions"`
+		Deletions int    `json:"deletions"`
+	} `json:"nodes"`
+}
+
+type graphQLCommits struct {
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		Commit struct {
+			OID     string `json:"oid"`
+			Message string `json:"message"`
+		} `json:"commit"`
+	} `json:"nodes"`
+}
+
+type graphQLIssue struct {
+	Number    int           `json:"number"`
+	Title     string        `json:"title"`
+	Body      string        `json:"body"`
+	State     string        `json:"state"`
+	URL       string        `json:"url"`
+	CreatedAt time.Time     `json:"createdAt"`
+	ClosedAt  *time.Time    `json:"closedAt"`
+	Author    *graphQLActor `json:"author"`
+	Labels    struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"labels"`
+	Comments graphQLComments `json:"comments"`
+}
+
+type graphQLPullRequest struct {
+	Number      int            `json:"number"`
+	Title       string         `json:"title"`
+	Body        string         `json:"body"`
+	State       string         `json:"state"`
+	URL         string         `json:"url"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	ClosedAt    *time.Time     `json:"closedAt"`
+	MergedAt    *time.Time     `json:"mergedAt"`
+	HeadRefName string         `json:"headRefName"`
+	BaseRefName string         `json:"baseRefName"`
+	Author      *graphQLActor  `json:"author"`
+	Files       graphQLFiles   `json:"files"`
+	Commits     graphQLCommits `json:"commits"`
+}
+
+type graphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// ListIssuesWithComments lists issues within given repo with their comments, filters by labels if provided
+func (gqc *GithubGraphQLClient) ListIssuesWithComments(org, repo string, labels []string) ([]*IssueWithComments, error) {
+	var res []*IssueWithComments
+	variables := map[string]interface{}{"owner": org, "name": repo, "labels": nil}
+	if len(labels) > 0 {
+		variables["labels"] = labels
+	}
+	err := paginate(variables, func() (graphQLPageInfo, error) {
+		var data struct {
+			Repository struct {
+				Issues struct {
+					PageInfo graphQLPageInfo `json:"pageInfo"`
+					Nodes    []graphQLIssue  `json:"nodes"`
+				} `json:"issues"`
+			} `json:"repository"`
+		}
+		if err := gqc.query(fmt.Sprintf("listing issues with label '%v'", labels), issuesQuery, variables, &data); err != nil {
+			return graphQLPageInfo{}, err
+		}
+		for _, node := range data.Repository.Issues.Nodes {
+			comments, err := gqc.listRemainingComments(org, repo, node.Number, node.Comments)
+			if err != nil {
+				return graphQLPageInfo{}, err
+			}
+			res = append(res, &IssueWithComments{Issue: node.toIssue(), Comments: comments})
+		}
+		return data.Repository.Issues.PageInfo, nil
+	})
+	return res, err
+}
+
+// ListPullRequestsWithFiles lists pull requests within given repo with their files and commits, filters by state
+func (gqc *GithubGraphQLClient) ListPullRequestsWithFiles(org, repo string, state PullRequestState) ([]*PullRequestWithFiles, error) {
+	var res []*PullRequestWithFiles
+	variables := map[string]interface{}{"owner": org, "name": repo, "states": nil}
+	switch state {
+	case PullRequestOpenState:
+		variables["states"] = []string{"OPEN"}
+	case PullRequestCloseState:
+		variables["states"] = []string{"CLOSED", "MERGED"}
+	}
+	err := paginate(variables, func() (graphQLPageInfo, error) {
+		var data struct {
+			Repository struct {
+				PullRequests struct {
+					PageInfo graphQLPageInfo      `json:"pageInfo"`
+					Nodes    []graphQLPullRequest `json:"nodes"`
+				} `json:"pullRequests"`
+			} `json:"repository"`
+		}
+		if err := gqc.query(fmt.Sprintf("listing Pull Requests with state '%s'", state), pullRequestsQuery, variables, &data); err != nil {
+			return graphQLPageInfo{}, err
+		}
+		for _, node := range data.Repository.PullRequests.Nodes {
+			files, err := gqc.listRemainingFiles(org, repo, node.Number, node.Files)
+			if err != nil {
+				return graphQLPageInfo{}, err
+			}
+			commits, err := gqc.listRemainingCommits(org, repo, node.Number, node.Commits)
+			if err != nil {
+				return graphQLPageInfo{}, err
+			}
+			res = append(res, &PullRequestWithFiles{PullRequest: node.toPullRequest(), Files: files, Commits: commits})
+		}
+		return data.Repository.PullRequests.PageInfo, nil
+	})
+	return res, err
+}
+
+// ListBranches lists branches within given repo, with the SHA of their head commit
+func (gqc *GithubGraphQLClient) ListBranches(org, repo string) ([]*github.Branch, error) {
+	var res []*github.Branch
+	variables := map[string]interface{}{"owner": org, "name": repo}
+	err := paginate(variables, func() (graphQLPageInfo, error) {
+		var data struct {
+			Repository struct {
+				Refs struct {
+					PageInfo graphQLPageInfo `json:"pageInfo"`
+					Nodes    []struct {
+						Name   string `json:"name"`
+						Target struct {
+							OID string `json:"oid"`
+						} `json:"target"`
+					} `json:"nodes"`
+				} `json:"refs"`
+			} `json:"repository"`
+		}
+		if err := gqc.query(fmt.Sprintf("listing branches of '%s/%s'", org, repo), branchesQuery, variables, &data); err != nil {
+			return graphQLPageInfo{}, err
+		}
+		for _, node := range data.Repository.Refs.Nodes {
+			res = append(res, &github.Branch{
+				Name:   github.String(node.Name),
+				Commit: &github.RepositoryCommit{SHA: github.String(node.Target.OID)},
+			})
+		}
+		return data.Repository.Refs.PageInfo, nil
+	})
+	return res, err
+}
+
+// listRemainingComments returns the given first page of comments of an issue, followed by the next pages
+func (gqc *GithubGraphQLClient) listRemainingComments(org, repo string, number int, first graphQLComments) ([]*github.IssueComment, error) {
+	var res []*github.IssueComment
+	page := first
+	variables := map[string]interface{}{"owner": org, "name": repo, "number": number}
+	for {
+		for _, node := range page.Nodes {
+			res = append(res, &github.IssueComment{
+				ID:        github.Int64(node.DatabaseID),
+				Body:      github.String(node.Body),
+				HTMLURL:   github.String(node.URL),
+				CreatedAt: timePtr(node.CreatedAt),
+				User:      node.Author.toUser(),
+			})
+		}
+		if !page.PageInfo.HasNextPage {
+			return res, nil
+		}
+		variables["cursor"] = page.PageInfo.EndCursor
+		var data struct {
+			Repository struct {
+				Issue struct {
+					Comments graphQLComments `json:"comments"`
+				} `json:"issue"`
+			} `json:"repository"`
+		}
+		if err := gqc.query(fmt.Sprintf("listing comments of issue '%d'", number), issueCommentsQuery, variables, &data); err != nil {
+			return res, err
+		}
+		page = data.Repository.Issue.Comments
+	}
+}
+
+// listRemainingFiles returns the given first page of files of a pull request, followed by the next pages
+func (gqc *GithubGraphQLClient) listRemainingFiles(org, repo string, number int, first graphQLFiles) ([]*github.CommitFile, error) {
+	var res []*github.CommitFile
+	page := first
+	variables := map[string]interface{}{"owner": org, "name": repo, "number": number}
+	for {
+		for _, node := range page.Nodes {
+			res = append(res, &github.CommitFile{
+				Filename:  github.String(node.Path),
+				Additions: github.Int(node.Additions),
+				Deletions: github.Int(node.Deletions),
+				Changes:   github.Int(node.Additions + node.Deletions),
+			})
+		}
+		if !page.PageInfo.HasNextPage {
+			return res, nil
+		}
+		variables["cursor"] = page.PageInfo.EndCursor
+		var data struct {
+			Repository struct {
+				PullRequest struct {
+					Files graphQLFiles `json:"files"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		}
+		if err := gqc.query(fmt.Sprintf("listing files in Pull Requests '%d'", number), pullRequestFilesQuery, variables, &data); err != nil {
+			return res, err
+		}
+		page = data.Repository.PullRequest.Files
+	}
+}
+
+// listRemainingCommits returns the given first page of commits of a pull request, followed by the next pages
+func (gqc *GithubGraphQLClient) listRemainingCommits(org, repo string, number int, first graphQLCommits) ([]*github.RepositoryCommit, error) {
+	var res []*github.RepositoryCommit
+	page := first
+	variables := map[string]interface{}{"owner": org, "name": repo, "number": number}
+	for {
+		for _, node := range page.Nodes {
+			res = append(res, &github.RepositoryCommit{
+				SHA:    github.String(node.Commit.OID),
+				Commit: &github.Commit{Message: github.String(node.Commit.Message)},
+			})
+		}
+		if !page.PageInfo.HasNextPage {
+			return res, nil
+		}
+		variables["cursor"] = page.PageInfo.EndCursor
+		var data struct {
+			Repository struct {
+				PullRequest struct {
+					Commits graphQLCommits `json:"commits"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		}
+		if err := gqc.query(fmt.Sprintf("listing commits in Pull Requests '%d'", number), pullRequestCommitsQuery, variables, &data); err != nil {
+			return res, err
+		}
+		page = data.Repository.PullRequest.Commits
+	}
+}
+
+// paginate calls page until it returns the last page, setting the cursor of the next page in the query variables
+func paginate(variables map[string]interface{}, page func() (graphQLPageInfo, error)) error {
+	variables["cursor"] = nil
+	for {
+		info, err := page()
+		if err != nil {
+			return err
+		}
+		if !info.HasNextPage {
+			return nil
+		}
+		variables["cursor"] = info.EndCursor
+	}
+}
+
+// query runs the GraphQL query with the given variables and decodes its data in the given value,
+// retrying the same way as the REST calls when the rate limit is reached, and after server errors
+func (gqc *GithubGraphQLClient) query(message, query string, variables map[string]interface{}, data interface{}) error {
+	_, err := retryRead(
+		message,
+		maxRetryCount,
+		func() (*github.Response, error) {
+			return nil, gqc.do(query, variables, data)
+		},
+	)
+	return err
+}
+
+// do sends the GraphQL query once, returning the errors retried by retryRead when Github refused it because of
+// the rate limit or failed on its side
+func (gqc *GithubGraphQLClient) do(query string, variables map[string]interface{}, data interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	resp, err := gqc.HTTPClient.Post(gqc.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	rate := parseRate(resp)
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp, rate, content)
+	}
+	var res struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []graphQLError  `json:"errors"`
+	}
+	if err := json.Unmarshal(content, &res); err != nil {
+		return fmt.Errorf("cannot decode GraphQL response: %v", err)
+	}
+	if len(res.Errors) > 0 {
+		var messages []string
+		for _, e := range res.Errors {
+			if e.Type == "RATE_LIMITED" {
+				return &github.RateLimitError{Rate: rate, Response: resp, Message: e.Message}
+			}
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("GraphQL query failed: %s", strings.Join(messages, "; "))
+	}
+	return json.Unmarshal(res.Data, data)
+}
+
+// responseError returns the error of a failed GraphQL request, as go-github does for the REST calls:
+// a RateLimitError if the rate limit is reached, an AbuseRateLimitError for a secondary rate limit,
+// and an ErrorResponse for a server error
+func responseError(resp *http.Response, rate github.Rate, content []byte) error {
+	message := strings.TrimSpace(string(content))
+	var res struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(content, &res); err == nil && res.Message != "" {
+		message = res.Message
+	}
+	// Github asks to wait for Retry-After after a secondary rate limit, or until the rate limit reset
+	// if no request is remaining
+	limited := resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests
+	lowerMessage := strings.ToLower(message)
+	secondary := resp.Header.Get("Retry-After") != "" ||
+		strings.Contains(lowerMessage, "secondary rate limit") || strings.Contains(lowerMessage, "abuse")
+	switch {
+	case limited && secondary:
+		abuseErr := &github.AbuseRateLimitError{Response: resp, Message: message}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter := time.Duration(seconds) * time.Second
+			abuseErr.RetryAfter = &retryAfter
+		}
+		return abuseErr
+	case limited && resp.Header.Get("X-RateLimit-Remaining") == "0":
+		return &github.RateLimitError{Rate: rate, Response: resp, Message: message}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return &github.ErrorResponse{Response: resp, Message: message}
+	default:
+		return fmt.Errorf("GraphQL request failed with status '%s': %s", resp.Status, message)
+	}
+}
+
+// parseRate returns the rate limit given in the headers of the response
+func parseRate(resp *http.Response) github.Rate {
+	var rate github.Rate
+	rate.Limit, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	rate.Remaining, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		rate.Reset = github.Timestamp{Time: time.Unix(reset, 0)}
+	}
+	return rate
+}
+
+func (i graphQLIssue) toIssue() *github.Issue {
+	issue := &github.Issue{
+		Number:    github.Int(i.Number),
+		Title:     github.String(i.Title),
+		Body:      github.String(i.Body),
+		State:     github.String(strings.ToLower(i.State)),
+		HTMLURL:   github.String(i.URL),
+		CreatedAt: timePtr(i.CreatedAt),
+		ClosedAt:  i.ClosedAt,
+		User:      i.Author.toUser(),
+	}
+	for _, label := range i.Labels.Nodes {
+		issue.Labels = append(issue.Labels, github.Label{Name: github.String(label.Name)})
+	}
+	return issue
+}
+
+func (p graphQLPullRequest) toPullRequest() *github.PullRequest {
+	// Merged pull requests are closed ones for the REST API
+	state := strings.ToLower(p.State)
+	merged := state == "merged"
+	if merged {
+		state = string(PullRequestCloseState)
+	}
+	return &github.PullRequest{
+		Number:    github.Int(p.Number),
+		Title:     github.String(p.Title),
+		Body:      github.String(p.Body),
+		State:     github.String(state),
+		Merged:    github.Bool(merged),
+		HTMLURL:   github.String(p.URL),
+		CreatedAt: timePtr(p.CreatedAt),
+		ClosedAt:  p.ClosedAt,
+		MergedAt:  p.MergedAt,
+		User:      p.Author.toUser(),
+		Head:      &github.PullRequestBranch{Ref: github.String(p.HeadRefName)},
+		Base:      &github.PullRequestBranch{Ref: github.String(p.BaseRefName)},
+	}
+}
+
+// toUser returns the user of the actor, nil for deleted users
+func (a *graphQLActor) toUser() *github.User {
+	if a == nil {
+		return nil
+	}
+	return &github.User{Login: github.String(a.Login)}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
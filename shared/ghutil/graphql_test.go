@@ -0,0 +1,209 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// graphQLResponse is a canned response of the test server, returned for the queries containing Query,
+// with the given cursor.
+type graphQLResponse struct {
+	Query      string
+	Cursor     interface{}
+	Status     int
+	RetryAfter string
+	Body       string
+}
+
+// newGraphQLTestClient returns a client of a server replying to the queries with the given responses, in order,
+// the server to close, and a function returning the number of queries the server received.
+func newGraphQLTestClient(t *testing.T, responses []graphQLResponse) (*GithubGraphQLClient, *httptest.Server, func() int) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Cannot decode request: %v", err)
+		}
+		if count >= len(responses) {
+			t.Errorf("Unexpected query %q with variables %v", req.Query, req.Variables)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resp := responses[count]
+		count++
+		if !strings.Contains(req.Query, resp.Query) || !reflect.DeepEqual(req.Variables["cursor"], resp.Cursor) {
+			t.Errorf("Expected query with %q and cursor %v, got %q with variables %v", resp.Query, resp.Cursor, req.Query, req.Variables)
+		}
+		// Reset the rate limit in the past, so that retries don't wait.
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1000000000")
+		if resp.RetryAfter != "" {
+			w.Header().Set("Retry-After", resp.RetryAfter)
+		}
+		if resp.Status != 0 {
+			w.WriteHeader(resp.Status)
+		}
+		fmt.Fprint(w, resp.Body)
+	}))
+	return &GithubGraphQLClient{HTTPClient: server.Client(), URL: server.URL}, server, func() int { return count }
+}
+
+func TestListIssuesWithComments(t *testing.T) {
+	client, server, count := newGraphQLTestClient(t, []graphQLResponse{
+		{Query: "issues(", Body: `{"errors": [{"type": "RATE_LIMITED", "message": "API rate limit exceeded"}]}`},
+		{Query: "issues(", Body: `{"data": {"repository": {"issues": {
+			"pageInfo": {"hasNextPage": true, "endCursor": "i1"},
+			"nodes": [{"number": 1, "title": "flaky", "state": "OPEN", "author": {"login": "bot"},
+				"labels": {"nodes": [{"name": "flaky"}]},
+				"comments": {"pageInfo": {"hasNextPage": true, "endCursor": "c1"}, "nodes": [{"databaseId": 10, "body": "first"}]}}]}}}}`},
+		{Query: "issue(number", Cursor: "c1", Body: `{"data": {"repository": {"issue": {"comments": {
+			"pageInfo": {"hasNextPage": false}, "nodes": [{"databaseId": 11, "body": "second", "author": null}]}}}}}`},
+		{Query: "issues(", Cursor: "i1", Body: `{"data": {"repository": {"issues": {
+			"pageInfo": {"hasNextPage": false},
+			"nodes": [{"number": 2, "title": "fixed", "state": "CLOSED", "comments": {"nodes": []}}]}}}}`},
+	})
+	defer server.Close()
+	issues, err := client.ListIssuesWithComments("knative", "serving", []string{"flaky"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if count() != 4 {
+		t.Errorf("Expected 4 queries, got %d", count())
+	}
+	if len(issues) != 2 {
+		t.Fatalf("Expected 2 issues, got %d", len(issues))
+	}
+	first := issues[0]
+	if *first.Issue.Number != 1 || *first.Issue.State != "open" || *first.Issue.User.Login != "bot" || *first.Issue.Labels[0].Name != "flaky" {
+		t.Errorf("Unexpected first issue %v", first.Issue)
+	}
+	if len(first.Comments) != 2 || *first.Comments[0].ID != 10 || *first.Comments[1].Body != "second" || first.Comments[1].User != nil {
+		t.Errorf("Expected the comments of both pages, got %v", first.Comments)
+	}
+	if *issues[1].Issue.State != "closed" || len(issues[1].Comments) != 0 {
+		t.Errorf("Unexpected second issue %v with comments %v", issues[1].Issue, issues[1].Comments)
+	}
+}
+
+func TestListPullRequestsWithFiles(t *testing.T) {
+	client, server, _ := newGraphQLTestClient(t, []graphQLResponse{
+		{Query: "pullRequests(", Body: `{"data": {"repository": {"pullRequests": {
+			"pageInfo": {"hasNextPage": false},
+			"nodes": [{"number": 5, "state": "MERGED", "headRefName": "fix", "baseRefName": "master",
+				"files": {"pageInfo": {"hasNextPage": false}, "nodes": [{"path": "a.go", "additions": 3, "deletions": 1}]},
+				"commits": {"pageInfo": {"hasNextPage": true, "endCursor": "k1"}, "nodes": [{"commit": {"oid": "abc"}}]}}]}}}}`},
+		{Query: "commits(first: 100, after: $cursor)", Cursor: "k1", Body: `{"data": {"repository": {"pullRequest": {"commits": {
+			"pageInfo": {"hasNextPage": false}, "nodes": [{"commit": {"oid": "def", "message": "fix it"}}]}}}}}`},
+	})
+	defer server.Close()
+	prs, err := client.ListPullRequestsWithFiles("knative", "serving", PullRequestCloseState)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(prs) != 1 {
+		t.Fatalf("Expected 1 pull request, got %d", len(prs))
+	}
+	pr := prs[0]
+	if *pr.PullRequest.State != "closed" || !pr.PullRequest.GetMerged() || *pr.PullRequest.Head.Ref != "fix" {
+		t.Errorf("Expected a merged pull request from branch fix, got %v", pr.PullRequest)
+	}
+	if len(pr.Files) != 1 || *pr.Files[0].Filename != "a.go" || *pr.Files[0].Changes != 4 {
+		t.Errorf("Unexpected files %v", pr.Files)
+	}
+	if len(pr.Commits) != 2 || *pr.Commits[1].SHA != "def" || *pr.Commits[1].Commit.Message != "fix it" {
+		t.Errorf("Expected the commits of both pages, got %v", pr.Commits)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	var tests = []struct {
+		name     string
+		response graphQLResponse
+		err      string
+	}{
+		{"query error", graphQLResponse{Query: "refs(", Body: `{"errors": [{"type": "NOT_FOUND", "message": "Could not resolve to a Repository"}]}`},
+			"GraphQL query failed: Could not resolve to a Repository"},
+		{"request error", graphQLResponse{Query: "refs(", Status: http.StatusUnauthorized, Body: `{"message": "Bad credentials"}`},
+			"GraphQL request failed with status '401 Unauthorized': Bad credentials"},
+	}
+	for _, test := range tests {
+		client, server, _ := newGraphQLTestClient(t, []graphQLResponse{test.response})
+		if _, err := client.ListBranches("knative", "foo"); err == nil || err.Error() != test.err {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+		server.Close()
+	}
+}
+
+func TestGraphQLRetries(t *testing.T) {
+	var sleeps []time.Duration
+	sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	defer func() { sleep = time.Sleep }()
+
+	branches := graphQLResponse{Query: "refs(", Body: `{"data": {"repository": {"refs": {
+		"pageInfo": {"hasNextPage": false}, "nodes": [{"name": "master", "target": {"oid": "abc"}}]}}}}`}
+	serverError := graphQLResponse{Query: "refs(", Status: http.StatusBadGateway, Body: "bad gateway"}
+	var tests = []struct {
+		name      string
+		responses []graphQLResponse
+		sleeps    []time.Duration
+		err       bool
+	}{
+		{"server error", []graphQLResponse{serverError, branches}, []time.Duration{serverErrorBackoff}, false},
+		{"secondary rate limit", []graphQLResponse{{Query: "refs(", Status: http.StatusForbidden, RetryAfter: "3",
+			Body: `{"message": "You have exceeded a secondary rate limit"}`}, branches}, []time.Duration{3 * time.Second}, false},
+		{"secondary rate limit without retry after", []graphQLResponse{{Query: "refs(", Status: http.StatusForbidden,
+			Body: `{"message": "You have triggered an abuse detection mechanism"}`}, branches}, []time.Duration{defaultRetryAfter}, false},
+		// The rate limit reset of the test server is in the past, so there is no need to wait
+		{"rate limit", []graphQLResponse{{Query: "refs(", Status: http.StatusForbidden,
+			Body: `{"message": "API rate limit exceeded"}`}, branches}, nil, false},
+		{"server error until the last retry", []graphQLResponse{serverError, serverError, serverError, serverError, serverError, serverError},
+			[]time.Duration{serverErrorBackoff, 2 * serverErrorBackoff, 3 * serverErrorBackoff, 4 * serverErrorBackoff, 5 * serverErrorBackoff, 6 * serverErrorBackoff}, true},
+	}
+	for _, test := range tests {
+		sleeps = nil
+		client, server, count := newGraphQLTestClient(t, test.responses)
+		res, err := client.ListBranches("knative", "serving")
+		if test.err {
+			if errResp, ok := err.(*github.ErrorResponse); !ok || errResp.Response.StatusCode != http.StatusBadGateway {
+				t.Errorf("%s: expected the server error, got %v", test.name, err)
+			}
+		} else if err != nil || len(res) != 1 || res[0].GetName() != "master" {
+			t.Errorf("%s: expected the master branch, got %v, error %v", test.name, res, err)
+		}
+		if count() != len(test.responses) {
+			t.Errorf("%s: expected %d queries, got %d", test.name, len(test.responses), count())
+		}
+		if !reflect.DeepEqual(sleeps, test.sleeps) {
+			t.Errorf("%s: expected sleeps %v, got %v", test.name, test.sleeps, sleeps)
+		}
+		server.Close()
+	}
+}
@@ -100,7 +100,7 @@ func (gc *GithubClient) CreateIssue(org, repo, title, body string) (*github.Issu
 	}
 
 	var res *github.Issue
-	_, err := gc.retry(
+	_, err := retry(
 		fmt.Sprintf("creating issue '%s %s' '%s'", org, repo, title),
 		maxRetryCount,
 		func() (*github.Response, error) {
@@ -151,7 +151,7 @@ func (gc *GithubClient) ListComments(org, repo string, issueNumber int) ([]*gith
 // GetComment gets comment by comment ID
 func (gc *GithubClient) GetComment(org, repo string, commentID int64) (*github.IssueComment, error) {
 	var res *github.IssueComment
-	_, err := gc.retry(
+	_, err := retry(
 		fmt.Sprintf("getting comment '%s %s %d'", org, repo, commentID),
 		maxRetryCount,
 		func() (*github.Response, error) {
@@ -170,7 +170,7 @@ func (gc *GithubClient) CreateComment(org, repo string, issueNumber int, comment
 	comment := &github.IssueComment{
 		Body: &commentBody,
 	}
-	_, err := gc.retry(
+	_, err := retry(
 		fmt.Sprintf("commenting issue '%s %s %d'", org, repo, issueNumber),
 		maxRetryCount,
 		func() (*github.Response, error) {
@@ -188,7 +188,7 @@ func (gc *GithubClient) EditComment(org, repo string, commentID int64, commentBo
 	comment := &github.IssueComment{
 		Body: &commentBody,
 	}
-	_, err := gc.retry(
+	_, err := retry(
 		fmt.Sprintf("editing comment '%s %s %d'", org, repo, commentID),
 		maxRetryCount,
 		func() (*github.Response, error) {
@@ -201,7 +201,7 @@ func (gc *GithubClient) EditComment(org, repo string, commentID int64, commentBo
 
 // AddLabelsToIssue adds label on issue
 func (gc *GithubClient) AddLabelsToIssue(org, repo string, issueNumber int, labels []string) error {
-	_, err := gc.retry(
+	_, err := retry(
 		fmt.Sprintf("add labels '%v' to '%s %s %d'", labels, org, repo, issueNumber),
 		maxRetryCount,
 		func() (*github.Response, error) {
@@ -214,7 +214,7 @@ func (gc *GithubClient) AddLabelsToIssue(org, repo string, issueNumber int, labe
 
 // RemoveLabelForIssue removes given label for issue
 func (gc *GithubClient) RemoveLabelForIssue(org, repo string, issueNumber int, label string) error {
-	_, err := gc.retry(
+	_, err := retry(
 		fmt.Sprintf("remove label '%s' from '%s %s %d'", label, org, repo, issueNumber),
 		maxRetryCount,
 		func() (*github.Response, error) {
@@ -229,7 +229,7 @@ func (gc *GithubClient) updateIssueState(org, repo string, state IssueStateEnum,
 	issueRequest := &github.IssueRequest{
 		State: &stateString,
 	}
-	_, err := gc.retry(
+	_, err := retry(
 		fmt.Sprintf("applying '%s' action on issue '%s %s %d'", stateString, org, repo, issueNumber),
 		maxRetryCount,
 		func() (*github.Response, error) {
@@ -120,7 +120,7 @@ func (gc *GithubClient) ListFiles(org, repo string, ID int) ([]*github.CommitFil
 // GetPullRequest gets PullRequest by ID
 func (gc *GithubClient) GetPullRequest(org, repo string, ID int) (*github.PullRequest, error) {
 	var res *github.PullRequest
-	_, err := gc.retry(
+	_, err := retry(
 		fmt.Sprintf("Get PullRequest '%d'", ID),
 		maxRetryCount,
 		func() (*github.Response, error) {
@@ -143,7 +143,7 @@ func (gc *GithubClient) EditPullRequest(org, repo string, ID int, title, body st
 	PR.Title = &title
 	PR.Body = &body
 	var res *github.PullRequest
-	_, err = gc.retry(
+	_, err = retry(
 		fmt.Sprintf("Update PullRequest '%d', title: '%s'. body: '%s'", ID, title, body),
 		maxRetryCount,
 		func() (*github.Response, error) {
@@ -168,7 +168,7 @@ func (gc *GithubClient) CreatePullRequest(org, repo, head, base, title, body str
 	}
 
 	var res *github.PullRequest
-	_, err := gc.retry(
+	_, err := retry(
 		fmt.Sprintf("creating PullRequest from '%s' to '%s', title: '%s'. body: '%s'", head, base, title, body),
 		maxRetryCount,
 		func() (*github.Response, error) {
@@ -180,3 +180,45 @@ func (gc *GithubClient) CreatePullRequest(org, repo, head, base, title, body str
 	)
 	return res, err
 }
+
+// ListReviews lists the reviews of a pull request
+func (gc *GithubClient) ListReviews(org, repo string, ID int) ([]*github.PullRequestReview, error) {
+	options := &github.ListOptions{}
+	genericList, err := gc.depaginate(
+		fmt.Sprintf("listing reviews of Pull Request '%d'", ID),
+		maxRetryCount,
+		options,
+		func() ([]interface{}, *github.Response, error) {
+			page, resp, err := gc.Client.PullRequests.ListReviews(ctx, org, repo, ID, options)
+			var interfaceList []interface{}
+			if nil == err {
+				for _, review := range page {
+					interfaceList = append(interfaceList, review)
+				}
+			}
+			return interfaceList, resp, err
+		},
+	)
+	res := make([]*github.PullRequestReview, len(genericList))
+	for i, elem := range genericList {
+		res[i] = elem.(*github.PullRequestReview)
+	}
+	return res, err
+}
+
+// CreateReview creates a review of a pull request, submitted with the event of the review ("APPROVE",
+// "REQUEST_CHANGES" or "COMMENT"), or left pending without it
+func (gc *GithubClient) CreateReview(org, repo string, ID int, review *github.PullRequestReviewRequest) (*github.PullRequestReview, error) {
+	var res *github.PullRequestReview
+	_, err := retry(
+		fmt.Sprintf("creating review of Pull Request '%d'", ID),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.PullRequests.CreateReview(ctx, org, repo, ID, review)
+			return resp, err
+		},
+	)
+	return res, err
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// status.go provides generic functions related to commit statuses and check runs
+
+package ghutil
+
+import (
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	// Github rejects requests with more annotations than this, the others are added by further updates of the check run
+	maxAnnotationsPerRequest = 50
+)
+
+// CreateStatus creates a status for the given commit SHA, replacing the previous one with the same context
+func (gc *GithubClient) CreateStatus(org, repo, SHA string, status *github.RepoStatus) (*github.RepoStatus, error) {
+	var res *github.RepoStatus
+	_, err := retry(
+		fmt.Sprintf("creating status '%s' for commit '%s'", status.GetContext(), SHA),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Repositories.CreateStatus(ctx, org, repo, SHA, status)
+			return resp, err
+		},
+	)
+	return res, err
+}
+
+// ListStatuses lists the statuses of the given ref (a commit SHA, branch or tag), latest first
+func (gc *GithubClient) ListStatuses(org, repo, ref string) ([]*github.RepoStatus, error) {
+	options := &github.ListOptions{}
+	genericList, err := gc.depaginate(
+		fmt.Sprintf("listing statuses of '%s'", ref),
+		maxRetryCount,
+		options,
+		func() ([]interface{}, *github.Response, error) {
+			page, resp, err := gc.Client.Repositories.ListStatuses(ctx, org, repo, ref, options)
+			var interfaceList []interface{}
+			if nil == err {
+				for _, status := range page {
+					interfaceList = append(interfaceList, status)
+				}
+			}
+			return interfaceList, resp, err
+		},
+	)
+	res := make([]*github.RepoStatus, len(genericList))
+	for i, elem := range genericList {
+		res[i] = elem.(*github.RepoStatus)
+	}
+	return res, err
+}
+
+// CreateCheckRun creates a check run, with any number of annotations in its output
+// Check runs can only be created when authenticated as a Github App
+func (gc *GithubClient) CreateCheckRun(org, repo string, opt github.CreateCheckRunOptions) (*github.CheckRun, error) {
+	var annotations []*github.CheckRunAnnotation
+	if nil != opt.Output {
+		output := *opt.Output
+		output.Annotations, annotations = splitAnnotations(output.Annotations)
+		opt.Output = &output
+	}
+	var res *github.CheckRun
+	_, err := retry(
+		fmt.Sprintf("creating check run '%s' for commit '%s'", opt.Name, opt.HeadSHA),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Checks.CreateCheckRun(ctx, org, repo, opt)
+			return resp, err
+		},
+	)
+	if nil != err {
+		return res, err
+	}
+	return gc.addAnnotations(org, repo, res, annotations)
+}
+
+// UpdateCheckRun updates a check run, the annotations in its output are added to the existing ones
+func (gc *GithubClient) UpdateCheckRun(org, repo string, checkRunID int64, opt github.UpdateCheckRunOptions) (*github.CheckRun, error) {
+	var annotations []*github.CheckRunAnnotation
+	if nil != opt.Output {
+		output := *opt.Output
+		output.Annotations, annotations = splitAnnotations(output.Annotations)
+		opt.Output = &output
+	}
+	res, err := gc.updateCheckRun(org, repo, checkRunID, opt)
+	if nil != err {
+		return res, err
+	}
+	return gc.addAnnotations(org, repo, res, annotations)
+}
+
+// addAnnotations adds the given annotations to the check run, as many updates as needed
+func (gc *GithubClient) addAnnotations(org, repo string, checkRun *github.CheckRun, annotations []*github.CheckRunAnnotation) (*github.CheckRun, error) {
+	for len(annotations) > 0 {
+		// The title and summary are required with any output.
+		output := &github.CheckRunOutput{}
+		if nil != checkRun.Output {
+			output.Title = checkRun.Output.Title
+			output.Summary = checkRun.Output.Summary
+		}
+		output.Annotations, annotations = splitAnnotations(annotations)
+		var err error
+		checkRun, err = gc.updateCheckRun(org, repo, checkRun.GetID(), github.UpdateCheckRunOptions{
+			Name:   checkRun.GetName(),
+			Output: output,
+		})
+		if nil != err {
+			return checkRun, err
+		}
+	}
+	return checkRun, nil
+}
+
+func (gc *GithubClient) updateCheckRun(org, repo string, checkRunID int64, opt github.UpdateCheckRunOptions) (*github.CheckRun, error) {
+	var res *github.CheckRun
+	_, err := retry(
+		fmt.Sprintf("updating check run '%d'", checkRunID),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			res, resp, err = gc.Client.Checks.UpdateCheckRun(ctx, org, repo, checkRunID, opt)
+			return resp, err
+		},
+	)
+	return res, err
+}
+
+// splitAnnotations returns the annotations to send in one request, and the remaining ones
+func splitAnnotations(annotations []*github.CheckRunAnnotation) ([]*github.CheckRunAnnotation, []*github.CheckRunAnnotation) {
+	if len(annotations) <= maxAnnotationsPerRequest {
+		return annotations, nil
+	}
+	return annotations[:maxAnnotationsPerRequest], annotations[maxAnnotationsPerRequest:]
+}
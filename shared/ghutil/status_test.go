@@ -0,0 +1,122 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ghutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// checkRunRequest is a request received by the test server
+type checkRunRequest struct {
+	Method      string
+	Title       string
+	Annotations int
+}
+
+// newCheckRunTestClient returns a client of a server creating and updating the check run 1,
+// the server to close, and a function returning the requests received.
+func newCheckRunTestClient(t *testing.T) (*GithubClient, *httptest.Server, func() []checkRunRequest) {
+	var requests []checkRunRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opt github.UpdateCheckRunOptions
+		if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+			t.Errorf("Cannot decode request: %v", err)
+		}
+		if (r.Method != http.MethodPost || r.URL.Path != "/repos/knative/serving/check-runs") &&
+			(r.Method != http.MethodPatch || r.URL.Path != "/repos/knative/serving/check-runs/1") {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		request := checkRunRequest{Method: r.Method}
+		if nil != opt.Output {
+			request.Title = opt.Output.GetTitle()
+			request.Annotations = len(opt.Output.Annotations)
+		}
+		requests = append(requests, request)
+		fmt.Fprintf(w, `{"id": 1, "name": %q, "output": {"title": %q, "summary": "summary"}}`, opt.Name, request.Title)
+	}))
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return &GithubClient{Client: client}, server, func() []checkRunRequest { return requests }
+}
+
+func newAnnotations(count int) []*github.CheckRunAnnotation {
+	res := make([]*github.CheckRunAnnotation, count)
+	for i := range res {
+		res[i] = &github.CheckRunAnnotation{
+			Path:            github.String("main.go"),
+			StartLine:       github.Int(i + 1),
+			EndLine:         github.Int(i + 1),
+			AnnotationLevel: github.String("warning"),
+			Message:         github.String("not covered"),
+		}
+	}
+	return res
+}
+
+func TestCheckRunAnnotations(t *testing.T) {
+	var tests = []struct {
+		name        string
+		annotations int
+		update      bool
+		requests    []checkRunRequest
+	}{
+		{"create without output", -1, false, []checkRunRequest{{"POST", "", 0}}},
+		{"create with few annotations", 3, false, []checkRunRequest{{"POST", "coverage", 3}}},
+		{"create with many annotations", 120, false, []checkRunRequest{
+			{"POST", "coverage", 50}, {"PATCH", "coverage", 50}, {"PATCH", "coverage", 20}}},
+		{"update with many annotations", 100, true, []checkRunRequest{
+			{"PATCH", "coverage", 50}, {"PATCH", "coverage", 50}}},
+	}
+	for _, test := range tests {
+		client, server, requests := newCheckRunTestClient(t)
+		var output *github.CheckRunOutput
+		if test.annotations >= 0 {
+			output = &github.CheckRunOutput{
+				Title:       github.String("coverage"),
+				Summary:     github.String("summary"),
+				Annotations: newAnnotations(test.annotations),
+			}
+		}
+		var checkRun *github.CheckRun
+		var err error
+		if test.update {
+			checkRun, err = client.UpdateCheckRun("knative", "serving", 1, github.UpdateCheckRunOptions{Name: "coverage", Output: output})
+		} else {
+			checkRun, err = client.CreateCheckRun("knative", "serving", github.CreateCheckRunOptions{Name: "coverage", HeadSHA: "abc", Output: output})
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if checkRun.GetID() != 1 {
+			t.Errorf("%s: expected check run 1, got %v", test.name, checkRun)
+		}
+		if got := requests(); fmt.Sprint(got) != fmt.Sprint(test.requests) {
+			t.Errorf("%s: expected requests %v, got %v", test.name, test.requests, got)
+		}
+		if output != nil && len(output.Annotations) != test.annotations {
+			t.Errorf("%s: the annotations of the given output were modified", test.name)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// webhook.go receives Github webhook events, validates their signature and dispatches them to the handlers
+// registered for their type
+
+package webhook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	// Number of delivery IDs remembered to detect replays, Github doesn't redeliver events older than this anyway
+	maxDeliveries = 10000
+)
+
+// IssueCommentHandler handles issue_comment events, for comments on both issues and pull requests
+type IssueCommentHandler func(event *github.IssueCommentEvent) error
+
+// PullRequestHandler handles pull_request events
+type PullRequestHandler func(event *github.PullRequestEvent) error
+
+// PushHandler handles push events
+type PushHandler func(event *github.PushEvent) error
+
+// CheckRunHandler handles check_run events
+type CheckRunHandler func(event *github.CheckRunEvent) error
+
+// Server is an http.Handler receiving Github webhook events. Each event is validated with the secret of the webhook,
+// decoded and passed to the handlers registered for its type, in the order they were registered, until one fails.
+// An event is only handled once: a delivery already handled (i.e., a replay) is acknowledged without being handled
+// again. If its handling failed, a replay only calls the handlers that didn't succeed yet. Deliveries are remembered
+// in memory, so handlers should still be idempotent if the server may restart. Events without handlers are
+// acknowledged and ignored.
+// Handlers are called synchronously, while Github waits for the response for 10 seconds at most.
+type Server struct {
+	secret []byte
+
+	mutex                sync.Mutex
+	issueCommentHandlers []IssueCommentHandler
+	pullRequestHandlers  []PullRequestHandler
+	pushHandlers         []PushHandler
+	checkRunHandlers     []CheckRunHandler
+	deliveries           map[string]*deliveryState
+	deliveryOrder        []string
+}
+
+// deliveryState is the progress of the handling of a delivery
+type deliveryState struct {
+	// active is true while the delivery is handled, and once all its handlers succeeded
+	active bool
+	// handled is the number of handlers that succeeded, which aren't called again when the delivery is replayed
+	handled int
+}
+
+// NewServer returns a Server validating the events with the webhook secret in the given file
+func NewServer(secretFile string) (*Server, error) {
+	b, err := ioutil.ReadFile(secretFile)
+	if err != nil {
+		return nil, err
+	}
+	secret := strings.TrimSpace(string(b))
+	if secret == "" {
+		return nil, fmt.Errorf("webhook secret file %s is empty", secretFile)
+	}
+	return newServer([]byte(secret)), nil
+}
+
+func newServer(secret []byte) *Server {
+	return &Server{secret: secret, deliveries: make(map[string]*deliveryState)}
+}
+
+// OnIssueComment registers a handler of issue_comment events
+func (s *Server) OnIssueComment(handler IssueCommentHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.issueCommentHandlers = append(s.issueCommentHandlers, handler)
+}
+
+// OnPullRequest registers a handler of pull_request events
+func (s *Server) OnPullRequest(handler PullRequestHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pullRequestHandlers = append(s.pullRequestHandlers, handler)
+}
+
+// OnPush registers a handler of push events
+func (s *Server) OnPush(handler PushHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pushHandlers = append(s.pushHandlers, handler)
+}
+
+// OnCheckRun registers a handler of check_run events
+func (s *Server) OnCheckRun(handler CheckRunHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.checkRunHandlers = append(s.checkRunHandlers, handler)
+}
+
+// ServeHTTP validates, decodes and dispatches an event, implementing http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := github.ValidatePayload(r, s.secret)
+	if err != nil {
+		log.Printf("invalid webhook event: %v", err)
+		http.Error(w, "invalid signature or payload", http.StatusForbidden)
+		return
+	}
+	eventType := github.WebHookType(r)
+	deliveryID := github.DeliveryID(r)
+	if eventType == "" || deliveryID == "" {
+		http.Error(w, "missing event type or delivery ID", http.StatusBadRequest)
+		return
+	}
+	skip, ok := s.startDelivery(deliveryID)
+	if !ok {
+		log.Printf("ignoring replayed delivery %s of %s event", deliveryID, eventType)
+		fmt.Fprint(w, "replayed delivery ignored")
+		return
+	}
+	handled, err := s.dispatch(eventType, payload, skip)
+	s.finishDelivery(deliveryID, handled, err)
+	if err != nil {
+		// The details stay in the logs, as the response is shown to anyone who can see the webhook deliveries.
+		log.Printf("error handling delivery %s of %s event: %v", deliveryID, eventType, err)
+		http.Error(w, "error handling the event", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, "event handled")
+}
+
+// dispatch decodes the payload of the event, and passes it to the handlers of its type, skipping the given
+// number of handlers that already succeeded. Returns the number of handlers that succeeded, skipped ones included
+func (s *Server) dispatch(eventType string, payload []byte, skip int) (int, error) {
+	s.mutex.Lock()
+	issueCommentHandlers := s.issueCommentHandlers
+	pullRequestHandlers := s.pullRequestHandlers
+	pushHandlers := s.pushHandlers
+	checkRunHandlers := s.checkRunHandlers
+	s.mutex.Unlock()
+
+	switch eventType {
+	case "issue_comment", "pull_request", "push", "check_run":
+	default:
+		// Like ping, sent when the webhook is created.
+		return 0, nil
+	}
+	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		return skip, fmt.Errorf("cannot decode %s event: %v", eventType, err)
+	}
+	var handlers []func() error
+	switch event := event.(type) {
+	case *github.IssueCommentEvent:
+		for _, handler := range issueCommentHandlers {
+			handler := handler
+			handlers = append(handlers, func() error { return handler(event) })
+		}
+	case *github.PullRequestEvent:
+		for _, handler := range pullRequestHandlers {
+			handler := handler
+			handlers = append(handlers, func() error { return handler(event) })
+		}
+	case *github.PushEvent:
+		for _, handler := range pushHandlers {
+			handler := handler
+			handlers = append(handlers, func() error { return handler(event) })
+		}
+	case *github.CheckRunEvent:
+		for _, handler := range checkRunHandlers {
+			handler := handler
+			handlers = append(handlers, func() error { return handler(event) })
+		}
+	}
+	for i := skip; i < len(handlers); i++ {
+		if err := handlers[i](); err != nil {
+			return i, err
+		}
+	}
+	return len(handlers), nil
+}
+
+// startDelivery marks the delivery as active, and returns the number of its handlers that already succeeded,
+// or false if it's already active, i.e. being handled or handled successfully
+func (s *Server) startDelivery(deliveryID string) (int, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state, ok := s.deliveries[deliveryID]
+	if !ok {
+		state = &deliveryState{}
+		s.deliveries[deliveryID] = state
+		s.deliveryOrder = append(s.deliveryOrder, deliveryID)
+		// Forget the oldest deliveries.
+		for len(s.deliveryOrder) > maxDeliveries {
+			delete(s.deliveries, s.deliveryOrder[0])
+			s.deliveryOrder = s.deliveryOrder[1:]
+		}
+	} else if state.active {
+		return 0, false
+	}
+	state.active = true
+	return state.handled, true
+}
+
+// finishDelivery records the number of handlers of the delivery that succeeded, and lets the delivery be
+// handled again if replayed when one of them failed
+func (s *Server) finishDelivery(deliveryID string, handled int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if state, ok := s.deliveries[deliveryID]; ok {
+		state.handled = handled
+		state.active = err == nil
+	}
+}
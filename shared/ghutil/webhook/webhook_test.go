@@ -0,0 +1,210 @@
+/*
+Copyright 2019 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+const testSecret = "s3cr3t"
+
+// newEventRequest returns the delivery of the event of the given type, with the payload of its fixture,
+// signed with the given secret
+func newEventRequest(t *testing.T, eventType, deliveryID, secret string) *http.Request {
+	payload, err := ioutil.ReadFile(filepath.Join("testdata", eventType+".json"))
+	if err != nil {
+		payload = []byte(`{"zen": "Keep it logically awesome."}`)
+	}
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(payload)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("X-GitHub-Event", eventType)
+	req.Header.Set("X-GitHub-Delivery", deliveryID)
+	return req
+}
+
+// newTestServer returns a server recording the events it handles, failing them if fail is set
+func newTestServer(handled *[]string, fail *bool) *Server {
+	s := newServer([]byte(testSecret))
+	record := func(description string) error {
+		if *fail {
+			return fmt.Errorf("cannot handle %s", description)
+		}
+		*handled = append(*handled, description)
+		return nil
+	}
+	s.OnIssueComment(func(e *github.IssueCommentEvent) error {
+		return record(fmt.Sprintf("comment %q on %s#%d", e.Comment.GetBody(), e.Repo.GetFullName(), e.Issue.GetNumber()))
+	})
+	s.OnPullRequest(func(e *github.PullRequestEvent) error {
+		return record(fmt.Sprintf("PR %s#%d %s from %s", e.Repo.GetFullName(), e.GetNumber(), e.GetAction(), e.PullRequest.Head.GetRef()))
+	})
+	s.OnPush(func(e *github.PushEvent) error {
+		return record(fmt.Sprintf("push to %s of %s with %d commits", e.GetRef(), e.Repo.GetFullName(), len(e.Commits)))
+	})
+	s.OnCheckRun(func(e *github.CheckRunEvent) error {
+		return record(fmt.Sprintf("check run %s %s on %s", e.CheckRun.GetName(), e.GetAction(), e.CheckRun.GetHeadSHA()[:7]))
+	})
+	// A second handler of the same type is called after the first one.
+	s.OnPush(func(e *github.PushEvent) error {
+		return record("second push handler")
+	})
+	return s
+}
+
+func TestServeHTTP(t *testing.T) {
+	var tests = []struct {
+		name       string
+		eventType  string
+		deliveryID string
+		secret     string
+		fail       bool
+		status     int
+		handled    []string
+	}{
+		{"issue comment", "issue_comment", "1", testSecret, false, http.StatusOK,
+			[]string{`comment "/retest" on knative/serving#1234`}},
+		{"pull request", "pull_request", "2", testSecret, false, http.StatusOK,
+			[]string{"PR knative/serving#1234 opened from fix-autoscaler"}},
+		{"push", "push", "3", testSecret, false, http.StatusOK,
+			[]string{"push to refs/heads/release-0.7 of knative/serving with 1 commits", "second push handler"}},
+		{"check run", "check_run", "4", testSecret, false, http.StatusOK,
+			[]string{"check run coverage rerequested on 0123456"}},
+		{"event without handler", "ping", "5", testSecret, false, http.StatusOK, nil},
+		{"invalid signature", "push", "6", "wrong", false, http.StatusForbidden, nil},
+		{"missing delivery ID", "push", "", testSecret, false, http.StatusBadRequest, nil},
+		{"handler error", "push", "7", testSecret, true, http.StatusInternalServerError, nil},
+	}
+	for _, test := range tests {
+		var handled []string
+		fail := test.fail
+		s := newTestServer(&handled, &fail)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, newEventRequest(t, test.eventType, test.deliveryID, test.secret))
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d: %s", test.name, test.status, w.Code, w.Body.String())
+		}
+		if fmt.Sprint(handled) != fmt.Sprint(test.handled) {
+			t.Errorf("%s: expected %v handled, got %v", test.name, test.handled, handled)
+		}
+	}
+}
+
+func TestReplayProtection(t *testing.T) {
+	var handled []string
+	fail := false
+	s := newTestServer(&handled, &fail)
+	deliver := func(deliveryID string) int {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, newEventRequest(t, "issue_comment", deliveryID, testSecret))
+		return w.Code
+	}
+
+	deliver("a")
+	if code := deliver("a"); code != http.StatusOK || len(handled) != 1 {
+		t.Errorf("Expected the replayed delivery to be acknowledged and not handled, got status %d and %v handled", code, handled)
+	}
+	deliver("b")
+	if len(handled) != 2 {
+		t.Errorf("Expected another delivery of the same event to be handled, got %v handled", handled)
+	}
+
+	// A failed delivery is handled again when replayed, without the details of the error in the response.
+	fail = true
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, newEventRequest(t, "issue_comment", "c", testSecret))
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "cannot handle") {
+		t.Errorf("Expected the failed delivery to return status 500 with a generic message, got %d: %s", w.Code, w.Body.String())
+	}
+	fail = false
+	deliver("c")
+	if len(handled) != 3 {
+		t.Errorf("Expected the failed delivery to be handled when replayed, got %v handled", handled)
+	}
+}
+
+func TestReplayAfterPartialFailure(t *testing.T) {
+	var handled []string
+	fail := true
+	s := newServer([]byte(testSecret))
+	s.OnPush(func(e *github.PushEvent) error {
+		handled = append(handled, "first")
+		return nil
+	})
+	s.OnPush(func(e *github.PushEvent) error {
+		if fail {
+			return fmt.Errorf("second failed")
+		}
+		handled = append(handled, "second")
+		return nil
+	})
+	for _, expected := range []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, newEventRequest(t, "push", "a", testSecret))
+		if w.Code != expected {
+			t.Errorf("Expected status %d, got %d: %s", expected, w.Code, w.Body.String())
+		}
+		fail = false
+	}
+	// The handler that succeeded isn't called again when the failed delivery is replayed.
+	if fmt.Sprint(handled) != "[first second]" {
+		t.Errorf("Expected each handler to be called once, got %v", handled)
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	var tests = []struct {
+		content string
+		err     bool
+	}{
+		{testSecret + "\n", false},
+		{"  \n", true},
+	}
+	for _, test := range tests {
+		secretFile := filepath.Join(dir, "secret")
+		if err := ioutil.WriteFile(secretFile, []byte(test.content), 0600); err != nil {
+			t.Fatalf("Cannot write secret: %v", err)
+		}
+		s, err := NewServer(secretFile)
+		if test.err != (err != nil) {
+			t.Errorf("Expected error %v for secret %q, got %v", test.err, test.content, err)
+		}
+		if err == nil && string(s.secret) != testSecret {
+			t.Errorf("Expected secret %q, got %q", testSecret, s.secret)
+		}
+	}
+}
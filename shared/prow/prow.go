@@ -37,7 +37,7 @@ const (
 	// OrgName is the name of knative org
 	OrgName = "knative"
 
-	// BucketName is the gcs bucket for all knative builds
+	// BucketName is the gcs bucket for all knative builds, used by default for the jobs
 	BucketName = "knative-prow"
 	// Latest is the filename storing latest build number
 	Latest = "latest-build.txt"
@@ -63,14 +63,12 @@ const (
 // defined here so that it can be mocked for unit testing
 var logFatalf = log.Fatalf
 
-var ctx = context.Background()
-
 // Job struct represents a job directory in gcs.
 // gcs job StoragePath will be derived from Type if it's defined,
 type Job struct {
 	Name        string
 	Type        string
-	Bucket      string  // optional
+	Bucket      string  // optional, BucketName if not set
 	Repo        string  // optional
 	StoragePath string  // optional
 	PullID      int     // only for Presubmit jobs
@@ -82,7 +80,7 @@ type Build struct {
 	JobName     string
 	StoragePath string
 	BuildID     int
-	Bucket      string // optional
+	Bucket      string // optional, BucketName if not set
 	StartTime   *int64
 	FinishTime  *int64
 }
@@ -125,7 +123,7 @@ func GetLocalArtifactsDir() string {
 
 // Initialize wraps gcs authentication, have to be invoked before any other functions
 func Initialize(serviceAccount string) error {
-	return gcs.Authenticate(ctx, serviceAccount)
+	return gcs.Authenticate(context.Background(), serviceAccount)
 }
 
 // NewJob creates new job struct
@@ -152,14 +150,14 @@ func NewJob(jobName, jobType, repoName string, pullID int) *Job {
 }
 
 // PathExists checks if the storage path of a job exists in gcs or not
-func (j *Job) PathExists() bool {
-	return gcs.Exists(ctx, BucketName, j.StoragePath)
+func (j *Job) PathExists(ctx context.Context) (bool, error) {
+	return gcs.Exists(ctx, bucketOrDefault(j.Bucket), j.StoragePath)
 }
 
 // GetLatestBuildNumber gets the latest build number for job
-func (j *Job) GetLatestBuildNumber() (int, error) {
+func (j *Job) GetLatestBuildNumber(ctx context.Context) (int, error) {
 	logFilePath := path.Join(j.StoragePath, Latest)
-	contents, err := gcs.Read(ctx, BucketName, logFilePath)
+	contents, err := gcs.Read(ctx, bucketOrDefault(j.Bucket), logFilePath)
 	if err != nil {
 		return 0, err
 	}
@@ -170,69 +168,98 @@ func (j *Job) GetLatestBuildNumber() (int, error) {
 	return latestBuild, nil
 }
 
-// NewBuild gets build struct based on job info
-// No gcs operation is performed by this function
-func (j *Job) NewBuild(buildID int) *Build {
+// NewBuild gets build struct based on job info, with its start and finish time
+// read from "started.json" and "finished.json" on gcs, left nil if the files don't exist.
+// Returns an error if the files cannot be read for any other reason
+func (j *Job) NewBuild(ctx context.Context, buildID int) (*Build, error) {
 	build := Build{
-		Bucket:      BucketName,
+		Bucket:      bucketOrDefault(j.Bucket),
 		JobName:     j.Name,
 		StoragePath: path.Join(j.StoragePath, strconv.Itoa(buildID)),
 		BuildID:     buildID,
 	}
 
-	if startTime, err := build.GetStartTime(); nil == err {
+	startTime, err := build.GetStartTime(ctx)
+	if nil == err {
 		build.StartTime = &startTime
+	} else if !gcs.IsNotExist(err) {
+		return nil, err
 	}
-	if finishTime, err := build.GetFinishTime(); nil == err {
+	finishTime, err := build.GetFinishTime(ctx)
+	if nil == err {
 		build.FinishTime = &finishTime
+	} else if !gcs.IsNotExist(err) {
+		return nil, err
 	}
-	return &build
+	return &build, nil
 }
 
 // GetFinishedBuilds gets all builds that have finished,
 // by looking at existence of "finished.json" file
-func (j *Job) GetFinishedBuilds() []Build {
+func (j *Job) GetFinishedBuilds(ctx context.Context) ([]Build, error) {
 	var finishedBuilds []Build
-	builds := j.GetBuilds()
+	builds, err := j.GetBuilds(ctx)
+	if err != nil {
+		return nil, err
+	}
 	for _, build := range builds {
-		if true == build.IsFinished() {
+		finished, err := build.IsFinished(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if finished {
 			finishedBuilds = append(finishedBuilds, build)
 		}
 	}
-	return finishedBuilds
+	return finishedBuilds, nil
 }
 
 // GetBuilds gets all builds from this job on gcs, precomputes start/finish time of builds
 // by parsing "Started.json" and "Finished.json" on gcs, could be very expensive if there are
 // large number of builds
-func (j *Job) GetBuilds() []Build {
+func (j *Job) GetBuilds(ctx context.Context) ([]Build, error) {
 	var builds []Build
-	for _, ID := range j.GetBuildIDs() {
-		builds = append(builds, *j.NewBuild(ID))
+	buildIDs, err := j.GetBuildIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ID := range buildIDs {
+		build, err := j.NewBuild(ctx, ID)
+		if err != nil {
+			return nil, err
+		}
+		builds = append(builds, *build)
 	}
-	return builds
+	return builds, nil
 }
 
 // GetBuildIDs gets all build IDs from this job on gcs, scans all direct child of gcs directory
-// for job, keeps the ones that can be parsed as integer
-func (j *Job) GetBuildIDs() []int {
+// for job, keeps the ones that can be parsed as integer.
+// Returns an error if gcs cannot be listed, rather than the build IDs listed so far, or if ctx is done
+func (j *Job) GetBuildIDs(ctx context.Context) ([]int, error) {
 	var buildIDs []int
-	gcsBuildPaths := gcs.ListDirectChildren(ctx, j.Bucket, j.StoragePath)
+	gcsBuildPaths, err := gcs.ListDirectChildren(ctx, bucketOrDefault(j.Bucket), j.StoragePath, nil)
+	if err != nil {
+		return nil, err
+	}
 	for _, gcsBuildPath := range gcsBuildPaths {
 		if buildID, err := getBuildIDFromBuildPath(gcsBuildPath); nil == err {
 			buildIDs = append(buildIDs, buildID)
 		}
 	}
-	return buildIDs
+	return buildIDs, nil
 }
 
 // GetLatestBuilds get latest builds from gcs, sort by start time from newest to oldest,
 // will return count number of builds
-func (j *Job) GetLatestBuilds(count int) []Build {
+func (j *Job) GetLatestBuilds(ctx context.Context, count int) ([]Build, error) {
 	// The timestamp of gcs directories are not usable,
 	// as they are all set to '0001-01-01 00:00:00 +0000 UTC',
 	// so use 'started.json' creation date for latest builds
-	builds := j.GetFinishedBuilds()
+	builds, err := j.GetFinishedBuilds(ctx)
+	if err != nil {
+		return nil, err
+	}
 	sort.Slice(builds, func(i, j int) bool {
 		if nil == builds[i].StartTime {
 			return false
@@ -243,26 +270,28 @@ func (j *Job) GetLatestBuilds(count int) []Build {
 		return *builds[i].StartTime > *builds[j].StartTime
 	})
 	if len(builds) < count {
-		return builds
+		return builds, nil
 	}
-	return builds[:count]
+	return builds[:count], nil
 }
 
-// IsStarted check if build has started by looking at "started.json" file
-func (b *Build) IsStarted() bool {
-	return gcs.Exists(ctx, BucketName, path.Join(b.StoragePath, StartedJSON))
+// IsStarted check if build has started by looking at "started.json" file,
+// returns an error if gcs cannot be checked
+func (b *Build) IsStarted(ctx context.Context) (bool, error) {
+	return gcs.Exists(ctx, bucketOrDefault(b.Bucket), path.Join(b.StoragePath, StartedJSON))
 }
 
-// IsFinished check if build has finished by looking at "finished.json" file
-func (b *Build) IsFinished() bool {
-	return gcs.Exists(ctx, BucketName, path.Join(b.StoragePath, FinishedJSON))
+// IsFinished check if build has finished by looking at "finished.json" file,
+// returns an error if gcs cannot be checked
+func (b *Build) IsFinished(ctx context.Context) (bool, error) {
+	return gcs.Exists(ctx, bucketOrDefault(b.Bucket), path.Join(b.StoragePath, FinishedJSON))
 }
 
 // GetStartTime gets started timestamp of a build,
 // returning -1 if the build didn't start or if it failed to get the timestamp
-func (b *Build) GetStartTime() (int64, error) {
+func (b *Build) GetStartTime(ctx context.Context) (int64, error) {
 	var started Started
-	if err := unmarshalJSONFile(path.Join(b.StoragePath, StartedJSON), &started); nil != err {
+	if err := unmarshalJSONFile(ctx, bucketOrDefault(b.Bucket), path.Join(b.StoragePath, StartedJSON), &started); nil != err {
 		return -1, err
 	}
 	return started.Timestamp, nil
@@ -270,17 +299,17 @@ func (b *Build) GetStartTime() (int64, error) {
 
 // GetFinishTime gets finished timestamp of a build,
 // returning -1 if the build didn't finish or if it failed to get the timestamp
-func (b *Build) GetFinishTime() (int64, error) {
+func (b *Build) GetFinishTime(ctx context.Context) (int64, error) {
 	var finished Finished
-	if err := unmarshalJSONFile(path.Join(b.StoragePath, FinishedJSON), &finished); nil != err {
+	if err := unmarshalJSONFile(ctx, bucketOrDefault(b.Bucket), path.Join(b.StoragePath, FinishedJSON), &finished); nil != err {
 		return -1, err
 	}
 	return finished.Timestamp, nil
 }
 
 // GetArtifacts gets gcs path for all artifacts of current build
-func (b *Build) GetArtifacts() []string {
-	return gcs.ListChildrenFiles(ctx, BucketName, b.GetArtifactsDir())
+func (b *Build) GetArtifacts(ctx context.Context) ([]string, error) {
+	return gcs.ListChildrenFiles(ctx, bucketOrDefault(b.Bucket), b.GetArtifactsDir(), nil)
 }
 
 // GetArtifactsDir gets gcs path for artifacts of current build
@@ -295,16 +324,16 @@ func (b *Build) GetBuildLogPath() string {
 
 // ReadFile reads given file of current build,
 // relPath is the file path relative to build directory
-func (b *Build) ReadFile(relPath string) ([]byte, error) {
-	return gcs.Read(ctx, BucketName, path.Join(b.StoragePath, relPath))
+func (b *Build) ReadFile(ctx context.Context, relPath string) ([]byte, error) {
+	return gcs.Read(ctx, bucketOrDefault(b.Bucket), path.Join(b.StoragePath, relPath))
 }
 
 // ParseLog parses the build log and returns the lines where the checkLog func does not return an empty slice,
 // checkLog function should take in the log statement and return a part from that statement that should be in the log output.
-func (b *Build) ParseLog(checkLog func(s []string) *string) ([]string, error) {
+func (b *Build) ParseLog(ctx context.Context, checkLog func(s []string) *string) ([]string, error) {
 	var logs []string
 
-	f, err := gcs.NewReader(ctx, b.Bucket, b.GetBuildLogPath())
+	f, err := gcs.NewReader(ctx, bucketOrDefault(b.Bucket), b.GetBuildLogPath())
 	if err != nil {
 		return logs, err
 	}
@@ -318,16 +347,24 @@ func (b *Build) ParseLog(checkLog func(s []string) *string) ([]string, error) {
 	return logs, nil
 }
 
+// bucketOrDefault returns the given bucket, or the knative bucket if it's not set
+func bucketOrDefault(bucket string) string {
+	if bucket == "" {
+		return BucketName
+	}
+	return bucket
+}
+
 // getBuildIDFromBuildPath digests gcs build path and return last portion of path
 func getBuildIDFromBuildPath(buildPath string) (int, error) {
 	_, buildIDStr := path.Split(strings.TrimRight(buildPath, " /"))
 	return strconv.Atoi(buildIDStr)
 }
 
-// unmarshalJSONFile reads a file from gcs, parses it with xml and write to v.
+// unmarshalJSONFile reads a file from the given gcs bucket, parses it with xml and write to v.
 // v must be an arbitrary struct, slice, or string.
-func unmarshalJSONFile(storagePath string, v interface{}) error {
-	contents, err := gcs.Read(ctx, BucketName, storagePath)
+func unmarshalJSONFile(ctx context.Context, bucket, storagePath string, v interface{}) error {
+	contents, err := gcs.Read(ctx, bucket, storagePath)
 	if nil != err {
 		return err
 	}
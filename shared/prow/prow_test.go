@@ -19,8 +19,14 @@ limitations under the License.
 package prow
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"reflect"
 	"testing"
+
+	"github.com/knative/test-infra/shared/gcs"
 )
 
 const (
@@ -84,3 +90,110 @@ func TestGetArtifacts(t *testing.T) {
 	// Set it to the original value
 	os.Setenv("ARTIFACTS", dir)
 }
+
+func TestGetLatestBuilds(t *testing.T) {
+	storage := gcs.NewMemStorage()
+	gcs.SetStorage(storage)
+	defer gcs.SetStorage(nil)
+	// Build 3 started last but isn't finished, build 4 has no started.json
+	for ID, started := range map[int]int64{1: 100, 2: 300, 3: 400, 4: -1, 10: 200} {
+		buildPath := fmt.Sprintf("logs/%s/%d/", testJobName, ID)
+		if started >= 0 {
+			storage.Write(BucketName, buildPath+StartedJSON, []byte(fmt.Sprintf(`{"timestamp": %d}`, started)))
+		}
+		if ID != 3 {
+			storage.Write(BucketName, buildPath+FinishedJSON, []byte(`{"passed": true}`))
+		}
+	}
+	storage.Write(BucketName, fmt.Sprintf("logs/%s/%s", testJobName, Latest), []byte("10\n"))
+
+	job := NewJob(testJobName, PeriodicJob, repoName, 0)
+	if exists, err := job.PathExists(context.Background()); !exists || err != nil {
+		t.Errorf("Expected the path of job %s to exist, got error %v", testJobName, err)
+	}
+	if latest, err := job.GetLatestBuildNumber(context.Background()); err != nil || latest != 10 {
+		t.Errorf("Expected latest build 10, got %d, error %v", latest, err)
+	}
+	builds, err := job.GetLatestBuilds(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Cannot get latest builds: %v", err)
+	}
+	var IDs []int
+	for _, build := range builds {
+		IDs = append(IDs, build.BuildID)
+	}
+	if expected := []int{2, 10, 1}; !reflect.DeepEqual(IDs, expected) {
+		t.Errorf("Expected latest finished builds %v, got %v", expected, IDs)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if builds, err := job.GetLatestBuilds(canceled, 3); err != context.Canceled {
+		t.Errorf("Expected the listing to stop with the canceled context, got builds %v, error %v", builds, err)
+	}
+}
+
+// failingStorage is a storage failing to list files
+type failingStorage struct {
+	*gcs.MemStorage
+}
+
+func (fs failingStorage) ListDirectChildren(ctx context.Context, bucketName, storagePath string, opts *gcs.ListOptions) ([]string, error) {
+	return nil, errors.New("listing failed")
+}
+
+func (fs failingStorage) ListChildrenFiles(ctx context.Context, bucketName, storagePath string, opts *gcs.ListOptions) ([]string, error) {
+	return nil, errors.New("listing failed")
+}
+
+func TestListingErrors(t *testing.T) {
+	storage := gcs.NewMemStorage()
+	storage.Write(BucketName, fmt.Sprintf("logs/%s/1/%s", testJobName, FinishedJSON), []byte(`{"passed": true}`))
+	gcs.SetStorage(failingStorage{storage})
+	defer gcs.SetStorage(nil)
+
+	job := NewJob(testJobName, PeriodicJob, repoName, 0)
+	if builds, err := job.GetLatestBuilds(context.Background(), 1); err == nil {
+		t.Errorf("Expected an error rather than builds %v", builds)
+	}
+	build, err := job.NewBuild(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Cannot get build: %v", err)
+	}
+	if artifacts, err := build.GetArtifacts(context.Background()); err == nil {
+		t.Errorf("Expected an error rather than artifacts %v", artifacts)
+	}
+}
+
+// unreadableStorage is a storage failing to read or check files
+type unreadableStorage struct {
+	*gcs.MemStorage
+}
+
+func (us unreadableStorage) Read(ctx context.Context, bucketName, filePath string) ([]byte, error) {
+	return nil, errors.New("read failed")
+}
+
+func (us unreadableStorage) Exists(ctx context.Context, bucketName, storagePath string) (bool, error) {
+	return false, errors.New("check failed")
+}
+
+func TestReadErrors(t *testing.T) {
+	storage := gcs.NewMemStorage()
+	storage.Write(BucketName, fmt.Sprintf("logs/%s/1/%s", testJobName, FinishedJSON), []byte(`{"passed": true}`))
+	gcs.SetStorage(unreadableStorage{storage})
+	defer gcs.SetStorage(nil)
+
+	// Builds that can't be read must not be taken as unfinished builds
+	job := NewJob(testJobName, PeriodicJob, repoName, 0)
+	if build, err := job.NewBuild(context.Background(), 1); err == nil {
+		t.Errorf("Expected an error rather than build %+v", build)
+	}
+	if builds, err := job.GetLatestBuilds(context.Background(), 1); err == nil {
+		t.Errorf("Expected an error rather than builds %v", builds)
+	}
+	build := Build{StoragePath: fmt.Sprintf("logs/%s/1", testJobName)}
+	if finished, err := build.IsFinished(context.Background()); err == nil {
+		t.Errorf("Expected an error rather than finished %v", finished)
+	}
+}
@@ -115,9 +115,18 @@ type GithubIssue struct {
 	client ghutil.GithubOperations
 }
 
-// Setup creates the necessary setup to make calls to work with github issues
-func Setup(githubToken string) (*GithubIssue, error) {
-	ghc, err := ghutil.NewGithubClient(githubToken)
+// Setup creates the necessary setup to make calls to work with github issues,
+// authenticating as the Github App configured in githubApp if set, with githubToken otherwise.
+// In dry run mode, changes to issues are logged instead of being sent to Github
+func Setup(githubToken, githubApp string, dryrun bool) (*GithubIssue, error) {
+	// Issues and comments are listed again for each flaky test, cache them so that unchanged ones don't use rate limit
+	var ghc *ghutil.GithubClient
+	var err error
+	if githubApp != "" {
+		ghc, err = ghutil.NewCachedGithubAppClient(githubApp, ghutil.NewMemoryCacheStorage())
+	} else {
+		ghc, err = ghutil.NewCachedGithubClient(githubToken, ghutil.NewMemoryCacheStorage())
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Cannot authenticate to github: %v", err)
 	}
@@ -126,7 +135,11 @@ func Setup(githubToken string) (*GithubIssue, error) {
 	if nil != err {
 		return nil, fmt.Errorf("Cannot get username: %v", err)
 	}
-	return &GithubIssue{user: ghUser, client: ghc}, nil
+	var client ghutil.GithubOperations = ghc
+	if dryrun {
+		client = ghutil.NewDryRunGithubClient(ghc)
+	}
+	return &GithubIssue{user: ghUser, client: client}, nil
 }
 
 // The Repo field of an github Issue could be empty, use URL is more reliable
@@ -200,7 +213,7 @@ func (gi *GithubIssue) prependComment(oldComment, newComment string) string {
 
 // updateIssue adds comments to an existing issue, close an issue if test passed both in previous day and today,
 // reopens the issue if test becomes flaky while issue is closed.
-func (gi *GithubIssue) updateIssue(fi *flakyIssue, newComment string, ts *TestStat, dryrun bool) error {
+func (gi *GithubIssue) updateIssue(fi *flakyIssue, newComment string, ts *TestStat) error {
 	issue := fi.issue
 	passedLastTime := false
 	latestStatus := regexp.MustCompile(reLastestStatus).FindStringSubmatch(fi.comment.GetBody())
@@ -217,12 +230,7 @@ func (gi *GithubIssue) updateIssue(fi *flakyIssue, newComment string, ts *TestSt
 
 	// Update comment unless test passed and issue closed
 	if !ts.isPassed() || issue.GetState() == string(ghutil.IssueOpenState) {
-		if err := run(
-			"updating comment",
-			func() error {
-				return gi.client.EditComment(org, getRepoFromIssue(issue), *fi.comment.ID, gi.prependComment(*fi.comment.Body, newComment))
-			},
-			dryrun); nil != err {
+		if err := gi.client.EditComment(org, getRepoFromIssue(issue), *fi.comment.ID, gi.prependComment(*fi.comment.Body, newComment)); nil != err {
 			return fmt.Errorf("failed updating comments for issue '%s': '%v'", *issue.URL, err)
 		}
 	}
@@ -230,34 +238,24 @@ func (gi *GithubIssue) updateIssue(fi *flakyIssue, newComment string, ts *TestSt
 	if ts.isPassed() { // close open issue if the test passed twice consecutively
 		if issue.GetState() == string(ghutil.IssueOpenState) {
 			if passedLastTime {
-				if err := run(
-					"closing issue",
-					func() error {
-						closeErr := gi.client.CloseIssue(org, getRepoFromIssue(issue), *issue.Number)
-						if nil == closeErr {
-							closeComment := "Closing issue: this test has passed in latest 2 scans"
-							_, closeErr = gi.client.CreateComment(org, getRepoFromIssue(issue), *issue.Number, closeComment)
-						}
-						return closeErr
-					},
-					dryrun); nil != err {
+				err := gi.client.CloseIssue(org, getRepoFromIssue(issue), *issue.Number)
+				if nil == err {
+					closeComment := "Closing issue: this test has passed in latest 2 scans"
+					_, err = gi.client.CreateComment(org, getRepoFromIssue(issue), *issue.Number, closeComment)
+				}
+				if nil != err {
 					return fmt.Errorf("failed closing issue '%s': '%v'", *issue.URL, err)
 				}
 			}
 		}
 	} else if ts.isFlaky() { // reopen closed issue if test found flaky
 		if issue.GetState() == string(ghutil.IssueCloseState) {
-			if err := run(
-				"reopening issue",
-				func() error {
-					openErr := gi.client.ReopenIssue(org, getRepoFromIssue(issue), *issue.Number)
-					if nil == openErr {
-						openComment := "Reopening issue: this test is flaky"
-						_, openErr = gi.client.CreateComment(org, getRepoFromIssue(issue), *issue.Number, openComment)
-					}
-					return openErr
-				},
-				dryrun); nil != err {
+			err := gi.client.ReopenIssue(org, getRepoFromIssue(issue), *issue.Number)
+			if nil == err {
+				openComment := "Reopening issue: this test is flaky"
+				_, err = gi.client.CreateComment(org, getRepoFromIssue(issue), *issue.Number, openComment)
+			}
+			if nil != err {
 				return fmt.Errorf("failed reopen issue: '%s'", *issue.URL)
 			}
 		}
@@ -266,38 +264,17 @@ func (gi *GithubIssue) updateIssue(fi *flakyIssue, newComment string, ts *TestSt
 }
 
 // createNewIssue creates an issue, adds flaky label and adds comment.
-func (gi *GithubIssue) createNewIssue(org, repoForIssue, title, body string, comment string, dryrun bool) error {
-	var newIssue *github.Issue
-	if err := run(
-		"creating issue",
-		func() error {
-			var err error
-			newIssue, err = gi.client.CreateIssue(org, repoForIssue, title, body)
-			return err
-		},
-		dryrun,
-	); nil != err {
+func (gi *GithubIssue) createNewIssue(org, repoForIssue, title, body string, comment string) error {
+	newIssue, err := gi.client.CreateIssue(org, repoForIssue, title, body)
+	if nil != err {
 		return fmt.Errorf("failed creating issue '%s' in repo '%s'", title, repoForIssue)
 	}
 	var addIdentityErrs []error // clean up issue if any error occurred during adding identity, see below
-	if err := run(
-		"adding comment",
-		func() error {
-			_, err := gi.client.CreateComment(org, repoForIssue, *newIssue.Number, comment)
-			return err
-		},
-		dryrun,
-	); nil != err {
+	if _, err := gi.client.CreateComment(org, repoForIssue, *newIssue.Number, comment); nil != err {
 		addIdentityErrs = append(addIdentityErrs, fmt.Errorf("failed adding comment to issue '%s', '%v'", *newIssue.URL, err))
 	}
 	if nil == combineErrors(addIdentityErrs) {
-		if err := run(
-			"adding flaky label",
-			func() error {
-				return gi.client.AddLabelsToIssue(org, repoForIssue, *newIssue.Number, []string{flakyLabel})
-			},
-			dryrun,
-		); nil != err {
+		if err := gi.client.AddLabelsToIssue(org, repoForIssue, *newIssue.Number, []string{flakyLabel}); nil != err {
 			addIdentityErrs = append(addIdentityErrs, fmt.Errorf("failed adding '%s' label to issue '%s', '%v'", flakyLabel, *newIssue.URL, err))
 		}
 	}
@@ -306,21 +283,11 @@ func (gi *GithubIssue) createNewIssue(org, repoForIssue, title, body string, com
 	// this issue will be invalid, and it's very likely that the same issue will be created the next time around.
 	// So cleanup issue if failed adding identity, by removing flaky label and closing issue
 	if nil != combineErrors(addIdentityErrs) {
-		if err := run(
-			"cleaning up invalid issue",
-			func() error {
-				var gErr []error
-				if rlErr := gi.client.RemoveLabelForIssue(org, repoForIssue, *newIssue.Number, flakyLabel); nil != rlErr {
-					gErr = append(gErr, rlErr)
-				}
-				if cErr := gi.client.CloseIssue(org, repoForIssue, *newIssue.Number); nil != cErr {
-					gErr = append(gErr, cErr)
-				}
-				return combineErrors(gErr)
-			},
-			dryrun,
-		); nil != err {
-			addIdentityErrs = append(addIdentityErrs, err)
+		if rlErr := gi.client.RemoveLabelForIssue(org, repoForIssue, *newIssue.Number, flakyLabel); nil != rlErr {
+			addIdentityErrs = append(addIdentityErrs, rlErr)
+		}
+		if cErr := gi.client.CloseIssue(org, repoForIssue, *newIssue.Number); nil != cErr {
+			addIdentityErrs = append(addIdentityErrs, cErr)
 		}
 	}
 	return combineErrors(addIdentityErrs)
@@ -423,7 +390,7 @@ func (gi *GithubIssue) getFlakyIssues() (map[string][]*flakyIssue, error) {
 // processGithubIssueForRepo reads RepoData and existing issues, and create/close/reopen/comment on issues.
 // The function returns a slice of messages containing performed actions, and a slice of error messages,
 // these can later on be printed as summary at the end of run
-func (gi *GithubIssue) processGithubIssueForRepo(rd *RepoData, flakyIssuesMap map[string][]*flakyIssue, dryrun bool) ([]string, error) {
+func (gi *GithubIssue) processGithubIssueForRepo(rd *RepoData, flakyIssuesMap map[string][]*flakyIssue) ([]string, error) {
 	var messages []string
 	var errs []error
 
@@ -448,7 +415,7 @@ func (gi *GithubIssue) processGithubIssueForRepo(rd *RepoData, flakyIssuesMap ma
 		log.Println(message)
 		return []string{message}, gi.createNewIssue(org, repoForIssue, title,
 			fmt.Sprintf(issueBodyTemplate, identity, rd.Config.Repo, fmt.Sprintf(testIdentifierPattern, identity)),
-			comment, dryrun)
+			comment)
 	}
 
 	// Update/Create issues for flaky/used-to-be-flaky tests
@@ -469,7 +436,7 @@ func (gi *GithubIssue) processGithubIssueForRepo(rd *RepoData, flakyIssuesMap ma
 				message := fmt.Sprintf("Updating issue '%s' for '%s'", *existIssue.issue.URL, *existIssue.identity)
 				log.Println(message)
 				messages = append(messages, message)
-				if err := gi.updateIssue(existIssue, comment, ts, dryrun); nil != err {
+				if err := gi.updateIssue(existIssue, comment, ts); nil != err {
 					log.Println(err)
 					errs = append(errs, err)
 				}
@@ -483,7 +450,7 @@ func (gi *GithubIssue) processGithubIssueForRepo(rd *RepoData, flakyIssuesMap ma
 			messages = append(messages, message)
 			if err := gi.createNewIssue(org, repoForIssue, title,
 				fmt.Sprintf(issueBodyTemplate, testFullName, rd.Config.Repo, fmt.Sprintf(testIdentifierPattern, identity)),
-				comment, dryrun); nil != err {
+				comment); nil != err {
 				log.Println(err)
 				errs = append(errs, err)
 			}
@@ -493,7 +460,7 @@ func (gi *GithubIssue) processGithubIssueForRepo(rd *RepoData, flakyIssuesMap ma
 }
 
 // analyze all results, figure out flaky tests and processing existing auto:flaky issues
-func (gi *GithubIssue) processGithubIssues(repoDataAll []*RepoData, dryrun bool) error {
+func (gi *GithubIssue) processGithubIssues(repoDataAll []*RepoData) error {
 	// map repo to jobs, and jobs to messages
 	messagesMap := make(map[string]map[string][]string)
 	// map repo to jobs, and jobs to errors
@@ -507,7 +474,7 @@ func (gi *GithubIssue) processGithubIssues(repoDataAll []*RepoData, dryrun bool)
 	}
 
 	for _, rd := range repoDataAll {
-		messages, err := gi.processGithubIssueForRepo(rd, flakyGHIssuesMap, dryrun)
+		messages, err := gi.processGithubIssueForRepo(rd, flakyGHIssuesMap)
 		if _, ok := messagesMap[rd.Config.Repo]; !ok {
 			messagesMap[rd.Config.Repo] = make(map[string][]string)
 		}
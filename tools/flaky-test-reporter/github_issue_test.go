@@ -60,7 +60,6 @@ var (
 	fakeUser   = &github.User{
 		ID: &fakeUserID,
 	}
-	dryrun = false
 )
 
 func getFakeGithubIssue() *GithubIssue {
@@ -121,7 +120,7 @@ func TestCreateIssue(t *testing.T) {
 	for _, d := range datas {
 		fgi := getFakeGithubIssue()
 		repoData := createRepoData(d.passed, d.flaky, d.failed, d.notenoughdata, d.issueRepo, int64(0))
-		fgi.processGithubIssueForRepo(repoData, make(map[string][]*flakyIssue), dryrun)
+		fgi.processGithubIssueForRepo(repoData, make(map[string][]*flakyIssue))
 		issues, _ := fgi.client.ListIssuesByRepo(fakeOrg, fakeRepo, []string{})
 		if len(issues) != d.wantIssues {
 			t.Fatalf("2%% tests failed, got %d issues, want %d issue", len(issues), d.wantIssues)
@@ -133,12 +132,12 @@ func TestExistingIssue(t *testing.T) {
 	fgi := getFakeGithubIssue()
 	repoData := createRepoData(200, 2, 0, 0, fakeRepo, int64(0))
 	flakyIssuesMap, _ := fgi.getFlakyIssues()
-	fgi.processGithubIssueForRepo(repoData, flakyIssuesMap, dryrun)
+	fgi.processGithubIssueForRepo(repoData, flakyIssuesMap)
 	existIssues, _ := fgi.client.ListIssuesByRepo(fakeOrg, fakeRepo, []string{})
 	flakyIssuesMap, _ = fgi.getFlakyIssues()
 
 	*repoData.LastBuildStartTime++
-	fgi.processGithubIssueForRepo(repoData, flakyIssuesMap, dryrun)
+	fgi.processGithubIssueForRepo(repoData, flakyIssuesMap)
 	issues, _ := fgi.client.ListIssuesByRepo(fakeOrg, fakeRepo, []string{})
 	if len(existIssues) != len(issues) {
 		t.Fatalf("issues already exists, got %d new issues, want 0 new issues", len(issues)-len(existIssues))
@@ -191,7 +190,7 @@ func TestUpdateIssue(t *testing.T) {
 			comment: comment,
 		}
 
-		gotErr := fgi.updateIssue(&fi, "new", &data.ts, dryrun)
+		gotErr := fgi.updateIssue(&fi, "new", &data.ts)
 		if nil == data.wantErr {
 			if nil != gotErr {
 				t.Fatalf("update %v, got err: '%v', want err: '%v'", data, gotErr, data.wantErr)
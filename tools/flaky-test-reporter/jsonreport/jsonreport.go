@@ -17,6 +17,7 @@ limitations under the License.
 package jsonreport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -63,19 +64,26 @@ func (r *Report) writeToArtifactsDir() error {
 // GetFlakyTestReport collects flaky test reports from the given buildID and repo.
 // Use repo = "" to get reports from all repositories, and buildID = -1 to get the
 // most recent report
-func GetFlakyTestReport(repo string, buildID int) ([]Report, error) {
+func GetFlakyTestReport(ctx context.Context, repo string, buildID int) ([]Report, error) {
 	job := prow.NewJob(jobName, prow.PeriodicJob, "", 0)
 	var err error
 	if buildID == -1 {
-		buildID, err = getLatestValidBuild(job, repo)
+		buildID, err = getLatestValidBuild(ctx, job, repo)
 		if err != nil {
 			return nil, err
 		}
 	}
-	build := job.NewBuild(buildID)
+	build, err := job.NewBuild(ctx, buildID)
+	if err != nil {
+		return nil, err
+	}
+	reportPaths, err := getReportPaths(ctx, build, repo)
+	if err != nil {
+		return nil, err
+	}
 	var reports []Report
-	for _, filepath := range getReportPaths(build, repo) {
-		report, err := readJSONReport(build, filepath)
+	for _, filepath := range reportPaths {
+		report, err := readJSONReport(ctx, build, filepath)
 		if err != nil {
 			return nil, err
 		}
@@ -86,26 +94,43 @@ func GetFlakyTestReport(repo string, buildID int) ([]Report, error) {
 
 // getLatestValidBuild inexpensively sorts and finds the most recent JSON report.
 // Assumes sequential build IDs are sequential in time.
-func getLatestValidBuild(job *prow.Job, repo string) (int, error) {
+func getLatestValidBuild(ctx context.Context, job *prow.Job, repo string) (int, error) {
 	// check latest build first, before looking to older builds
-	if buildID, err := job.GetLatestBuildNumber(); err == nil {
-		build := job.NewBuild(buildID)
-		if reports := getReportPaths(build, repo); len(reports) != 0 {
+	if buildID, err := job.GetLatestBuildNumber(ctx); err == nil {
+		build, err := job.NewBuild(ctx, buildID)
+		if err != nil {
+			return 0, err
+		}
+		reports, err := getReportPaths(ctx, build, repo)
+		if err != nil {
+			return 0, err
+		}
+		if len(reports) != 0 {
 			return buildID, nil
 		}
 	}
 	// look at older builds
 	maxElapsedTime, _ := time.ParseDuration(fmt.Sprintf("%dh", maxAge*24))
-	buildIDs := job.GetBuildIDs()
+	buildIDs, err := job.GetBuildIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
 	sort.Sort(sort.Reverse(sort.IntSlice(buildIDs)))
 	for _, buildID := range buildIDs {
-		build := job.NewBuild(buildID)
+		build, err := job.NewBuild(ctx, buildID)
+		if err != nil {
+			return 0, err
+		}
 		// check if reports exist for this build
-		if reports := getReportPaths(build, repo); len(reports) == 0 {
+		reports, err := getReportPaths(ctx, build, repo)
+		if err != nil {
+			return 0, err
+		}
+		if len(reports) == 0 {
 			continue
 		}
 		// check if this report is too old
-		startTimeInt, err := build.GetStartTime()
+		startTimeInt, err := build.GetStartTime(ctx)
 		if err != nil {
 			continue
 		}
@@ -120,21 +145,25 @@ func getLatestValidBuild(job *prow.Job, repo string) (int, error) {
 
 // getReportPaths searches build artifacts for reports from the given repo, returning
 // the path to any matching files. Use repo = "" to get all reports from all repos.
-func getReportPaths(build *prow.Build, repo string) []string {
+func getReportPaths(ctx context.Context, build *prow.Build, repo string) ([]string, error) {
 	var matches []string
 	suffix := path.Join(repo, filename)
-	for _, artifact := range build.GetArtifacts() {
+	artifacts, err := build.GetArtifacts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, artifact := range artifacts {
 		if strings.HasSuffix(artifact, suffix) {
 			matches = append(matches, strings.TrimPrefix(artifact, build.StoragePath))
 		}
 	}
-	return matches
+	return matches, nil
 }
 
 // readJSONReport builds a repo-specific report object from a given json file path.
-func readJSONReport(build *prow.Build, filename string) (*Report, error) {
+func readJSONReport(ctx context.Context, build *prow.Build, filename string) (*Report, error) {
 	report := &Report{}
-	contents, err := build.ReadFile(filename)
+	contents, err := build.ReadFile(ctx, filename)
 	if err != nil {
 		return nil, err
 	}
@@ -21,6 +21,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -34,6 +35,7 @@ import (
 func main() {
 	serviceAccount := flag.String("service-account", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), "JSON key file for GCS service account")
 	githubAccount := flag.String("github-account", "", "Token file for Github authentication")
+	githubApp := flag.String("github-app", "", "Github App config file for Github authentication, instead of --github-account")
 	slackAccount := flag.String("slack-account", "", "slack secret file for authenticating with Slack")
 	configPath := flag.String("configfile", "./config.yaml", "Config file for overriding default config file")
 	dryrun := flag.Bool("dry-run", false, "dry run switch")
@@ -55,7 +57,7 @@ func main() {
 	if err := prow.Initialize(*serviceAccount); nil != err { // Explicit authenticate with gcs Client
 		log.Fatalf("Failed authenticating GCS: '%v'", err)
 	}
-	ghi, err := Setup(*githubAccount)
+	ghi, err := Setup(*githubAccount, *githubApp, *dryrun)
 	if err != nil {
 		log.Fatalf("Cannot setup github: %v", err)
 	}
@@ -64,6 +66,7 @@ func main() {
 		log.Fatalf("Failed authenticating Slack: '%v'", err)
 	}
 
+	ctx := context.Background()
 	var repoDataAll []*RepoData
 	// Clean up local artifacts directory, this will be used later for artifacts uploads
 	err = os.RemoveAll(prow.GetLocalArtifactsDir()) // this function returns nil if path not found
@@ -73,7 +76,7 @@ func main() {
 	var jobErrs []error
 	for _, jc := range cfg.JobConfigs {
 		log.Printf("collecting results for job '%s' in repo '%s'\n", jc.Name, jc.Repo)
-		rd, err := collectTestResultsForRepo(jc)
+		rd, err := collectTestResultsForRepo(ctx, jc)
 		if nil != err {
 			err = fmt.Errorf("WARNING: error collecting results for job '%s' in repo '%s': %v", jc.Name, jc.Repo, err)
 			log.Printf("%v", err)
@@ -93,7 +96,7 @@ func main() {
 	// so any errors returned are github opeations error, which in most cases wouldn't happen, but in case it
 	// happens, it should fail the job after Slack notification
 	jobErr := combineErrors(jobErrs)
-	githubErr := ghi.processGithubIssues(repoDataAll, *dryrun)
+	githubErr := ghi.processGithubIssues(repoDataAll)
 	slackErr := sendSlackNotifications(repoDataAll, slackClient, ghi, *dryrun)
 	jsonErr := writeFlakyTestsToJSON(repoDataAll, *dryrun)
 	if nil != jobErr {
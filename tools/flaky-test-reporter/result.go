@@ -19,6 +19,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -160,15 +161,19 @@ func addSuiteToRepoData(suite *junit.TestSuite, buildID int, rd *RepoData) {
 
 // getCombinedResultsForBuild gets all junit results from a build,
 // and converts each one into a junit TestSuites struct
-func getCombinedResultsForBuild(build *prow.Build) ([]*junit.TestSuites, error) {
+func getCombinedResultsForBuild(ctx context.Context, build *prow.Build) ([]*junit.TestSuites, error) {
 	var allSuites []*junit.TestSuites
-	for _, artifact := range build.GetArtifacts() {
+	artifacts, err := build.GetArtifacts(ctx)
+	if nil != err {
+		return nil, err
+	}
+	for _, artifact := range artifacts {
 		_, fileName := filepath.Split(artifact)
 		if !strings.HasPrefix(fileName, "junit_") || !strings.HasSuffix(fileName, ".xml") {
 			continue
 		}
 		relPath, _ := filepath.Rel(build.StoragePath, artifact)
-		contents, err := build.ReadFile(relPath)
+		contents, err := build.ReadFile(ctx, relPath)
 		if nil != err {
 			return nil, err
 		}
@@ -183,13 +188,20 @@ func getCombinedResultsForBuild(build *prow.Build) ([]*junit.TestSuites, error)
 
 // collectTestResultsForRepo collects test results, build IDs from all builds,
 // as well as LastBuildStartTime, and stores them in RepoData
-func collectTestResultsForRepo(jc config.JobConfig) (*RepoData, error) {
+func collectTestResultsForRepo(ctx context.Context, jc config.JobConfig) (*RepoData, error) {
 	rd := &RepoData{Config: jc}
 	job := prow.NewJob(jc.Name, jc.Type, jc.Repo, 0)
-	if !job.PathExists() {
+	exists, err := job.PathExists(ctx)
+	if nil != err {
+		return nil, err
+	}
+	if !exists {
 		return rd, fmt.Errorf("job path not exist '%s'", jc.Name)
 	}
-	builds := getLatestFinishedBuilds(job, buildsCount)
+	builds, err := getLatestFinishedBuilds(ctx, job, buildsCount)
+	if nil != err {
+		return nil, err
+	}
 
 	log.Printf("latest builds: ")
 	for i, build := range builds {
@@ -198,7 +210,7 @@ func collectTestResultsForRepo(jc config.JobConfig) (*RepoData, error) {
 		if 0 == i { // This is the latest build as builds are sorted by start time in descending order
 			rd.LastBuildStartTime = build.StartTime
 		}
-		combinedResults, err := getCombinedResultsForBuild(&build)
+		combinedResults, err := getCombinedResultsForBuild(ctx, &build)
 		if nil != err {
 			return nil, err
 		}
@@ -239,15 +251,21 @@ func intSliceContains(its []int, target int) bool {
 // getLatestFinishedBuilds is an inexpensive way of listing latest finished builds, in comparing to
 // the GetLatestBuilds function from prow package, as it doesn't precompute start/finish time before sorting.
 // This function takes the assumption that build IDs are always incremental integers, it would fail if it doesn't
-func getLatestFinishedBuilds(job *prow.Job, count int) []prow.Build {
+func getLatestFinishedBuilds(ctx context.Context, job *prow.Job, count int) ([]prow.Build, error) {
 	var builds []prow.Build
-	buildIDs := job.GetBuildIDs()
+	buildIDs, err := job.GetBuildIDs(ctx)
+	if nil != err {
+		return nil, err
+	}
 	sort.Sort(sort.Reverse(sort.IntSlice(buildIDs)))
 	for _, buildID := range buildIDs {
 		if len(builds) >= count {
 			break
 		}
-		build := job.NewBuild(buildID)
+		build, err := job.NewBuild(ctx, buildID)
+		if nil != err {
+			return nil, err
+		}
 		if nil != build.FinishTime {
 			if nil == build.StartTime {
 				log.Fatalf("Failed parsing start time for finished build '%s'", build.StoragePath)
@@ -260,5 +278,5 @@ func getLatestFinishedBuilds(job *prow.Job, count int) []prow.Build {
 	}) {
 		log.Fatalf("Error: found build with smaller buildID started later than one with larger buildID")
 	}
-	return builds
+	return builds, nil
 }
@@ -31,8 +31,15 @@ type GithubClient struct {
 	*ghutil.GithubClient
 }
 
-func NewGithubClient(githubAccount string) (*GithubClient, error) {
-	ghc, err := ghutil.NewGithubClient(githubAccount)
+// NewGithubClient authenticates as the Github App configured in githubApp if set, with the token in githubAccount otherwise
+func NewGithubClient(githubAccount, githubApp string) (*GithubClient, error) {
+	var ghc *ghutil.GithubClient
+	var err error
+	if githubApp != "" {
+		ghc, err = ghutil.NewGithubAppClient(githubApp)
+	} else {
+		ghc, err = ghutil.NewGithubClient(githubAccount)
+	}
 	log.Printf("temporary - otherwise compiler will yell about ghc being an unused var: %v\n", ghc)
 	return &GithubClient{ghc}, err
 }
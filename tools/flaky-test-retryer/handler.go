@@ -37,9 +37,9 @@ type HandlerClient struct {
 	github *GithubClient
 }
 
-func NewHandlerClient(githubAccount string) (*HandlerClient, error) {
+func NewHandlerClient(githubAccount, githubApp string) (*HandlerClient, error) {
 	ctx := context.Background()
-	githubClient, err := NewGithubClient(githubAccount)
+	githubClient, err := NewGithubClient(githubAccount, githubApp)
 	if err != nil {
 		return nil, err
 	}
@@ -20,6 +20,8 @@ limitations under the License.
 package main
 
 import (
+	"context"
+
 	"github.com/knative/test-infra/tools/flaky-test-reporter/jsonreport"
 )
 
@@ -33,7 +35,7 @@ func InitLogParser(serviceAccount string) error {
 //       almost exactly the same thing.
 func getReportRepos() ([]string, error) {
 	var repos []string
-	reports, err := jsonreport.GetFlakyTestReport("", -1)
+	reports, err := jsonreport.GetFlakyTestReport(context.Background(), "", -1)
 	if err == nil && len(reports) > 0 {
 		for _, r := range reports {
 			repos = append(repos, r.Repo)
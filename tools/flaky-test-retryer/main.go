@@ -34,13 +34,14 @@ const (
 func main() {
 	serviceAccount := flag.String("service-account", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), "JSON key file for GCS service account")
 	githubAccount := flag.String("github-account", "", "Token file for Github authentication")
+	githubApp := flag.String("github-app", "", "Github App config file for Github authentication, instead of --github-account")
 	flag.Parse()
 
 	if err := InitLogParser(*serviceAccount); nil != err {
 		log.Fatalf("Failed authenticating GCS: '%v'", err)
 	}
 
-	handler, err := NewHandlerClient(*githubAccount)
+	handler, err := NewHandlerClient(*githubAccount, *githubApp)
 	if err != nil {
 		log.Fatalf("Coud not create Pub/Sub client: '%v'", err)
 	}
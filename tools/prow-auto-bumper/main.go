@@ -28,6 +28,7 @@ import (
 
 func main() {
 	githubAccount := flag.String("github-account", "", "Token file for Github authentication")
+	githubApp := flag.String("github-app", "", "Github App config file for Github authentication, instead of --github-account")
 	gitUserID := flag.String("git-userid", "", "The github ID of user for hosting fork, i.e. Github ID of bot")
 	gitUserName := flag.String("git-username", "", "The username to use on the git commit. Requires --git-email")
 	gitEmail := flag.String("git-email", "", "The email to use on the git commit. Requires --git-username")
@@ -38,7 +39,13 @@ func main() {
 		log.Println("Running in [dry run mode]")
 	}
 
-	gc, err := ghutil.NewGithubClient(*githubAccount)
+	var gc *ghutil.GithubClient
+	var err error
+	if *githubApp != "" {
+		gc, err = ghutil.NewGithubAppClient(*githubApp)
+	} else {
+		gc, err = ghutil.NewGithubClient(*githubAccount)
+	}
 	if nil != err {
 		log.Fatalf("cannot authenticate to github: %v", err)
 	}
@@ -61,7 +68,12 @@ func main() {
 		email:    *gitEmail,
 	}
 
-	gcw := &GHClientWrapper{gc}
+	var ghc ghutil.GithubOperations = gc
+	if *dryrun {
+		// Pull requests are logged instead of being created or edited
+		ghc = ghutil.NewDryRunGithubClient(gc)
+	}
+	gcw := &GHClientWrapper{ghc}
 	bestVersion, err := retryGetBestVersion(gcw, srcGI)
 	if nil != err {
 		log.Fatalf("cannot get best version from %s/%s: '%v'", srcGI.org, srcGI.repo, err)
@@ -147,25 +147,15 @@ func createOrUpdatePR(gcw *GHClientWrapper, pv *PRVersions, gi gitInfo, extraMsg
 	}
 	if nil != existPR {
 		log.Printf("Found open PR '%d'", *existPR.Number)
-		return run(
-			fmt.Sprintf("Updating PR '%d', title: '%s', body: '%s'", *existPR.Number, title, body),
-			func() error {
-				if _, err := gcw.EditPullRequest(gi.org, gi.repo, *existPR.Number, title, body); nil != err {
-					return fmt.Errorf("failed updating pullrequest: '%v'", err)
-				}
-				return nil
-			},
-			dryrun,
-		)
+		log.Printf("Updating PR '%d', title: '%s', body: '%s'", *existPR.Number, title, body)
+		if _, err := gcw.EditPullRequest(gi.org, gi.repo, *existPR.Number, title, body); nil != err {
+			return fmt.Errorf("failed updating pullrequest: '%v'", err)
+		}
+		return nil
 	}
-	return run(
-		fmt.Sprintf("Creating PR, title: '%s', body: '%s'", title, body),
-		func() error {
-			if _, err := gcw.CreatePullRequest(gi.org, gi.repo, gi.getHeadRef(), gi.base, title, body); nil != err {
-				return fmt.Errorf("failed creating pullrequest: '%v'", err)
-			}
-			return nil
-		},
-		dryrun,
-	)
+	log.Printf("Creating PR, title: '%s', body: '%s'", title, body)
+	if _, err := gcw.CreatePullRequest(gi.org, gi.repo, gi.getHeadRef(), gi.base, title, body); nil != err {
+		return fmt.Errorf("failed creating pullrequest: '%v'", err)
+	}
+	return nil
 }